@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -14,8 +15,10 @@ import (
 	"time"
 
 	"office_find_item/internal/app"
+	"office_find_item/internal/budget"
+	"office_find_item/internal/fswalk"
+	"office_find_item/internal/sandbox"
 	"office_find_item/internal/winutil"
-
 )
 
 func main() {
@@ -131,6 +134,7 @@ func main() {
 		fmt.Fprintln(out, "用法:")
 		fmt.Fprintln(out, "  ofind.exe -ui")
 		fmt.Fprintln(out, "  ofind.exe -roots \"D:\\Docs;E:\\Work\" -q \"合同编号：A-001\" [-workers 8] [-open 1]")
+		fmt.Fprintln(out, "  git ls-files -z | ofind.exe -files-from - -q \"合同编号\"")
 		fmt.Fprintln(out)
 		fmt.Fprintln(out, "参数:")
 		flag.PrintDefaults()
@@ -169,7 +173,7 @@ func main() {
 		switch a {
 		case "-ui":
 			isUI = true
-		case "-worker", "-daemon":
+		case "-worker", "-daemon", "-extract-worker":
 			isInternal = true
 		}
 	}
@@ -185,11 +189,77 @@ func main() {
 		query3  = flag.String("q3", "", "Query 3：要查找的字符串（交集）")
 		workers = flag.Int("workers", 0, "并发工作线程数（默认=CPU核心数）")
 		openIdx = flag.Int("open", 0, "搜索结束后打开第N个结果（从1开始），0表示不打开")
+		pdfBack = flag.String("pdf-backends", "", "本次查询的 PDF 后端链：ifilter/pdftotext/purego 逗号分隔，或 all、purego-only 等；默认按 OFIND_PDF_BACKENDS")
 		worker  = flag.Bool("worker", false, "内部使用：作为子进程执行搜索并输出 JSON Lines")
 		daemon  = flag.Bool("daemon", false, "内部使用：常驻索引+缓存进程（stdin 控制，stdout JSON Lines）")
+		exWork  = flag.Bool("extract-worker", false, "内部使用：提取沙箱子进程（stdin 请求，stdout 响应，均为 JSON Lines）")
+		exts    = flag.String("ext", "", "只搜索这些扩展名，逗号分隔（如 docx,pdf）")
+		minSize = flag.String("min-size", "", "只搜索不小于该大小的文件（如 100K、10MB）")
+		maxSize = flag.String("max-size", "", "只搜索不大于该大小的文件（如 50MB、1.5GB）")
+		newer   = flag.String("newer", "", "只搜索在此之后修改的文件：日期（2024-03-31）、距今时长（30d、2w、12h）或 today/week/month/quarter/year")
+		older   = flag.String("older", "", "只搜索在此之前修改的文件，格式同 -newer")
+		listIn  = flag.String("files-from", "", "不遍历根目录，只搜索该文件列表中的文件（每行一个路径，或 NUL 分隔如 find -print0）；- 表示标准输入")
+		order   = flag.String("schedule", "", "本次查询的处理顺序：pinned/cheap/recent 逗号分隔（如 cheap,recent）；默认按 OFIND_SCHEDULE，未设置时按遍历顺序")
+		depth   = flag.Int("max-depth", 0, "最大遍历深度：1 只搜根目录下的文件，2 再加一层子目录；0 不限")
+		follow  = flag.Bool("follow-links", false, "进入指向目录的符号链接与目录联接（junction），自动避免循环")
+		oneFS   = flag.Bool("one-file-system", false, "不进入与根目录不在同一文件系统（卷）上的目录，如挂载的网络盘")
+		bg      = flag.Bool("background", false, "后台模式：降低优先级并限速（OFIND_BG_READ_MBPS、OFIND_BG_FILES_PER_SEC），用于整盘/文件服务器扫描")
+		qList   = flag.Bool("quarantine-list", false, "列出隔离列表（曾导致超时、内存超限或崩溃而被跳过的文件）")
+		qClear  = flag.String("quarantine-clear", "", "从隔离列表移除指定文件路径；all 表示清空")
 	)
+	var includes, excludes stringList
+	flag.Var(&includes, "include", "只搜索匹配的路径（glob，如 \"D:\\Projects\\*\\docs\"、\"*.pdf\"），可重复")
+	flag.Var(&excludes, "exclude", "跳过匹配的目录/文件（glob，如 \"**/node_modules/**\"），可重复")
 	flag.Parse()
 
+	if len(includes) > 0 || len(excludes) > 0 {
+		if _, err := fswalk.NewFilter(includes, excludes, false, false); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		// 通过环境变量传给 worker/daemon 子进程，与已有的 OFIND_INCLUDE/OFIND_EXCLUDE 合并
+		appendEnvList("OFIND_INCLUDE", includes)
+		appendEnvList("OFIND_EXCLUDE", excludes)
+	}
+
+	meta, err := parseMeta(*exts, *minSize, *maxSize, *newer, *older)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// 遍历选项通过环境变量传给 worker/daemon 子进程（见 fswalk.OptionsFromEnv）
+	if *depth > 0 {
+		_ = os.Setenv("OFIND_MAX_DEPTH", strconv.Itoa(*depth))
+	}
+	if *follow {
+		_ = os.Setenv("OFIND_FOLLOW_LINKS", "1")
+	}
+	if *oneFS {
+		_ = os.Setenv("OFIND_ONE_FILE_SYSTEM", "1")
+	}
+
+	if *bg {
+		// 通过环境变量传给 worker/daemon 子进程
+		_ = os.Setenv("OFIND_BACKGROUND", "1")
+	}
+
+	if *exWork {
+		if err := sandbox.Serve(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *qList || *qClear != "" {
+		if err := app.RunQuarantine(*qList, *qClear); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *ui {
 		if runtime.GOOS != "windows" {
 			fmt.Fprintln(os.Stderr, "UI 仅支持 Windows")
@@ -208,9 +278,13 @@ func main() {
 			os.Exit(2)
 		}
 		if err := app.RunWorker(app.CLIOptions{
-			Roots:   *roots,
-			Query:   *query,
-			Workers: *workers,
+			Roots:       *roots,
+			Query:       *query,
+			Workers:     *workers,
+			PDFBackends: *pdfBack,
+			Meta:        meta,
+			FilesFrom:   *listIn,
+			Schedule:    *order,
 		}); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
@@ -237,34 +311,72 @@ func main() {
 	}
 
 	if err := app.RunCLI(app.CLIOptions{
-		Roots:   *roots,
-		Query:   *query,
-		Query2:  *query2,
-		Query3:  *query3,
-		Workers: *workers,
-		OpenIdx: *openIdx,
+		Roots:       *roots,
+		Query:       *query,
+		Query2:      *query2,
+		Query3:      *query3,
+		Workers:     *workers,
+		OpenIdx:     *openIdx,
+		PDFBackends: *pdfBack,
+		Meta:        meta,
+		FilesFrom:   *listIn,
+		Schedule:    *order,
 	}); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
-func setProcessMemoryLimits() {
-	// Allow override via env (MiB). Use 0 or negative to disable.
-	if v := strings.TrimSpace(os.Getenv("OFIND_MEM_LIMIT_MB")); v != "" {
-		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
-			if n > 0 {
-				debug.SetMemoryLimit(n * 1024 * 1024)
-			}
-			return
-		}
+// parseMeta 解析 -ext、-min-size/-max-size、-newer/-older。
+func parseMeta(exts, minSize, maxSize, newer, older string) (fswalk.Meta, error) {
+	m := fswalk.Meta{Exts: fswalk.ParseExts(exts)}
+	var err error
+	if m.MinSize, err = fswalk.ParseSize(minSize); err != nil {
+		return m, fmt.Errorf("-min-size: %w", err)
+	}
+	if m.MaxSize, err = fswalk.ParseSize(maxSize); err != nil {
+		return m, fmt.Errorf("-max-size: %w", err)
+	}
+	if m.MaxSize > 0 && m.MinSize > m.MaxSize {
+		return m, errors.New("-min-size 大于 -max-size")
+	}
+	now := time.Now()
+	if m.Newer, err = fswalk.ParseTime(newer, now); err != nil {
+		return m, fmt.Errorf("-newer: %w", err)
+	}
+	if m.Older, err = fswalk.ParseTime(older, now); err != nil {
+		return m, fmt.Errorf("-older: %w", err)
+	}
+	if !m.Newer.IsZero() && !m.Older.IsZero() && !m.Newer.Before(m.Older) {
+		return m, errors.New("-newer 不早于 -older，没有文件能同时满足")
 	}
+	return m, nil
+}
+
+// stringList 为可重复的字符串参数。
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ";") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func appendEnvList(key string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	list := fswalk.SplitList(os.Getenv(key))
+	_ = os.Setenv(key, strings.Join(append(list, values...), ";"))
+}
 
-	// Default limits: keep conservative to avoid OOM on large scans.
+func setProcessMemoryLimits() {
+	// GC soft limit follows the memory budget (OFIND_MEMORY_BUDGET_MB, see internal/budget); 0 disables.
+	if soft := budget.SoftLimit(); soft > 0 {
+		debug.SetMemoryLimit(soft)
+	}
 	if runtime.GOARCH == "386" {
-		debug.SetMemoryLimit(1400 * 1024 * 1024)
 		debug.SetGCPercent(50)
-		return
 	}
-	debug.SetMemoryLimit(4096 * 1024 * 1024)
 }
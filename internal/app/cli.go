@@ -6,9 +6,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 
+	"office_find_item/internal/extract"
+	"office_find_item/internal/fswalk"
 	"office_find_item/internal/winutil"
 )
 
@@ -19,6 +22,14 @@ type CLIOptions struct {
 	Query3  string
 	Workers int
 	OpenIdx int
+	// PDFBackends 为本次查询的 PDF 后端链（如 "pdftotext,purego"、"purego-only"）
+	PDFBackends string
+	// Meta 为文件元数据条件（-ext、-min-size/-max-size、-newer/-older），遍历时即过滤
+	Meta fswalk.Meta
+	// FilesFrom 非空时不遍历根目录，只搜索该文件列表（"-" 为标准输入）中的文件，见 fswalk.ReadPaths
+	FilesFrom string
+	// Schedule 为本次查询的处理顺序（-schedule，如 "pinned,cheap,recent"），空表示按 OFIND_SCHEDULE，默认遍历顺序
+	Schedule string
 }
 
 func RunCLI(opts CLIOptions) error {
@@ -29,21 +40,34 @@ func RunCLI(opts CLIOptions) error {
 		return errors.New("缺少查询参数：-q/-q2/-q3 至少一个")
 	}
 
+	if err := extract.ValidatePDFBackends(opts.PDFBackends); err != nil {
+		return err
+	}
+
 	roots := parseRoots(opts.Roots)
 	if len(roots) == 0 {
 		roots = winutil.DefaultSearchRoots()
 	}
+	// 每个根启动一个 daemon：重叠的根（D:\ 与 D:\Docs）合并，避免同一目录扫描两遍
+	roots = fswalk.CanonicalRoots(roots, fswalk.OptionsFromEnv())
 
-	for i := range roots {
-		abs, err := filepath.Abs(roots[i])
-		if err == nil {
-			roots[i] = abs
+	var files fswalk.Paths
+	if opts.FilesFrom != "" {
+		src, closeList, err := fswalk.OpenPaths(opts.FilesFrom)
+		if err != nil {
+			return err
 		}
+		defer closeList()
+		files = src
 	}
 
 	exePath, _ := os.Executable()
 
-	fmt.Printf("Roots: %s\n", strings.Join(roots, "; "))
+	if files != nil {
+		fmt.Printf("Files: %s\n", opts.FilesFrom)
+	} else {
+		fmt.Printf("Roots: %s\n", strings.Join(roots, "; "))
+	}
 	if q1 != "" {
 		fmt.Printf("Query: %s\n", q1)
 	}
@@ -72,9 +96,18 @@ func RunCLI(opts CLIOptions) error {
 		procMu.Unlock()
 	}()
 
-	doneNeed := 0
-	for _, root := range roots {
+	var meta *fswalk.Meta
+	if !opts.Meta.Empty() {
+		meta = &opts.Meta
+	}
+	queryID := uint64(1)
+	query := daemonCmd{Cmd: "setQuery", Query: q1, Query2: q2, Query3: q3, QueryID: queryID, ContextLen: 30, MaxSnippets: 3, PDFBackends: opts.PDFBackends, Meta: meta, Schedule: opts.Schedule}
+	startDaemon := func(root string) *daemonProcess {
 		dproc, err := startDaemonProcess(exePath, root, opts.Workers, enablePureGoPDF, func(out daemonOut) {
+			if out.Type == "done" {
+				outCh <- out // done 决定何时结束等待，不能丢
+				return
+			}
 			select {
 			case outCh <- out:
 			default:
@@ -82,56 +115,115 @@ func RunCLI(opts CLIOptions) error {
 			}
 		})
 		if err != nil {
-			continue
+			return nil
 		}
 		procMu.Lock()
 		procs = append(procs, dproc)
 		procMu.Unlock()
-		doneNeed++
-	}
-	if doneNeed == 0 {
-		return errors.New("无法启动 daemon 子进程（roots 为空或启动失败）")
+		return dproc
 	}
 
-	queryID := uint64(1)
-	procMu.Lock()
-	for _, p := range procs {
-		_ = p.SetQuery(q1, q2, q3, queryID, 30, 3)
+	doneNeed := 0
+	// listDone 在文件列表发送完毕后给出启动的 daemon 数（done 事件要等这么多个）
+	var listDone chan filesFed
+	if files != nil {
+		listDone = make(chan filesFed, 1)
+		go func() {
+			listDone <- feedFiles(files, query, startDaemon)
+		}()
+	} else {
+		for _, root := range roots {
+			dproc := startDaemon(root)
+			if dproc == nil {
+				continue
+			}
+			cmd := query
+			cmd.SkipDirs = otherRoots(roots, root)
+			_ = dproc.send(cmd)
+			doneNeed++
+		}
+		if doneNeed == 0 {
+			return errors.New("无法启动 daemon 子进程（roots 为空或启动失败）")
+		}
 	}
-	procMu.Unlock()
 
-	// 收集 & 去重
+	// 收集 & 去重（Windows 上路径不区分大小写）
+	seen := map[string]string{}
 	path2snips := map[string][]string{}
 	ordered := make([]string, 0, 256)
+	encrypted := map[string]bool{}
+	truncated := map[string]string{}
+	failed := make([]daemonOut, 0)
+	var deferred uint64
 	doneGot := 0
 
-	for doneGot < doneNeed {
-		out := <-outCh
+	var fed filesFed
+	for listDone != nil || doneGot < doneNeed {
+		var out daemonOut
+		select {
+		case out = <-outCh:
+		case fed = <-listDone:
+			listDone, doneNeed = nil, fed.daemons
+			continue
+		}
 		if out.QueryID != queryID {
 			continue
 		}
 		switch out.Type {
 		case "result":
-			if _, ok := path2snips[out.Path]; !ok {
-				ordered = append(ordered, out.Path)
+			key := resultKey(out.Path)
+			p, ok := seen[key]
+			if !ok {
+				p = out.Path
+				seen[key] = p
+				ordered = append(ordered, p)
 			}
-			path2snips[out.Path] = out.Snippets
+			path2snips[p] = out.Snippets
+			encrypted[p] = out.Encrypted
+			truncated[p] = out.Truncated
+		case "error":
+			failed = append(failed, out)
 		case "done":
 			doneGot++
+			if out.Message != "" {
+				fmt.Fprintln(os.Stderr, out.Message)
+			}
+			deferred += out.Deferred
 		}
 	}
 
+	if fed.err != nil {
+		return fmt.Errorf("读取文件列表 %s: %w", opts.FilesFrom, fed.err)
+	}
+	if files != nil && fed.files == 0 {
+		return errors.New("文件列表为空：" + opts.FilesFrom)
+	}
+	if files != nil && fed.daemons == 0 {
+		return errors.New("无法启动 daemon 子进程")
+	}
+
+	defer printFailedSummary(failed)
+	if deferred > 0 {
+		fmt.Fprintf(os.Stderr, "内存预算不足，%d 个文件延后处理（OFIND_MEMORY_BUDGET_MB）\n", deferred)
+	}
+
 	if len(ordered) == 0 {
 		fmt.Println("No matches.")
 		return nil
 	}
 
 	w := bufio.NewWriter(os.Stdout)
-	defer w.Flush()
 	for i, p := range ordered {
 		snips := strings.Join(path2snips[p], "  |  ")
+		if truncated[p] != "" {
+			snips = "[已截断] " + snips
+		}
+		if encrypted[p] {
+			snips = "[已解密] " + snips
+		}
 		fmt.Fprintf(w, "%d\t%s\t%s\n", i+1, p, snips)
 	}
+	_ = w.Flush()
 
 	if opts.OpenIdx > 0 {
 		idx := opts.OpenIdx - 1
@@ -144,6 +236,135 @@ func RunCLI(opts CLIOptions) error {
 	return nil
 }
 
+// statusLabels 为 error 事件原因代码的中文说明，也决定汇总中的顺序。
+var statusLabels = []struct {
+	status extract.Status
+	label  string
+}{
+	{extract.StatusTimeout, "超时"},
+	{extract.StatusEncrypted, "加密"},
+	{extract.StatusRefused, "超出安全预算"},
+	{extract.StatusTooLarge, "过大"},
+	{extract.StatusCorrupt, "损坏"},
+	{extract.StatusCrashed, "提取进程崩溃"},
+	{extract.StatusOutOfMemory, "内存超限"},
+	{extract.StatusUnsupported, "不支持的格式"},
+	{extract.StatusBackendMissing, "缺少提取后端"},
+	{extract.StatusAccessDenied, "无权限"},
+	{extract.StatusNotFound, "文件不存在"},
+	{extract.StatusQuarantined, "已隔离"},
+	{extract.StatusError, "出错"},
+}
+
+func statusLabel(s extract.Status) string {
+	for _, l := range statusLabels {
+		if l.status == s {
+			return l.label
+		}
+	}
+	return string(s)
+}
+
+// printFailedSummary 在结束时把无法读取的文件按原因汇总到 stderr，避免被误认为“没有命中”。
+func printFailedSummary(failed []daemonOut) {
+	if len(failed) == 0 {
+		return
+	}
+	counts := map[extract.Status]int{}
+	for _, out := range failed {
+		counts[out.Status]++
+	}
+	parts := make([]string, 0, len(counts))
+	for _, l := range statusLabels {
+		if n := counts[l.status]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", l.label, n))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "\n无法读取 %d 个文件（%s）：\n", len(failed), strings.Join(parts, "，"))
+	for _, out := range failed {
+		fmt.Fprintf(os.Stderr, "  [%s] %s（%s）\n", statusLabel(out.Status), out.Path, out.Message)
+	}
+}
+
+// resultKey 为结果路径的去重键。
+func resultKey(p string) string {
+	if runtime.GOOS == "windows" {
+		return strings.ToLower(p)
+	}
+	return p
+}
+
+// filesBatch 为 -files-from 每条 addFiles 命令携带的文件数。
+const filesBatch = 512
+
+// filesFed 为 feedFiles 的结果：读到的文件数、启动的 daemon 数与读取列表的错误。
+type filesFed struct {
+	files   int
+	daemons int
+	err     error
+}
+
+// feedFiles 边读文件列表边分批发给 daemon（setQuery 带 FilesFollow，之后为 addFiles），不把整个列表读进内存。
+// 文件按所在的卷（盘符或 UNC 共享）分组，每个卷一个 daemon，首次遇到时启动；start 返回 nil 表示启动失败，
+// 该卷的文件被跳过。相对路径按当前目录解析。
+func feedFiles(src fswalk.Paths, query daemonCmd, start func(root string) *daemonProcess) filesFed {
+	type group struct {
+		proc    *daemonProcess
+		pending []string
+	}
+	query.FilesFollow = true
+	groups := map[string]*group{}
+	var order []string
+	var fed filesFed
+	flush := func(g *group, last bool) {
+		if g.proc != nil && (len(g.pending) > 0 || last) {
+			_ = g.proc.send(daemonCmd{Cmd: "addFiles", QueryID: query.QueryID, Files: g.pending, Last: last})
+		}
+		g.pending = nil
+	}
+	fed.err = src(func(p string) error {
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		root := strings.ToUpper(filepath.VolumeName(p)) + string(filepath.Separator)
+		g := groups[root]
+		if g == nil {
+			g = &group{proc: start(root)}
+			if g.proc != nil {
+				_ = g.proc.send(query)
+				fed.daemons++
+			}
+			groups[root] = g
+			order = append(order, root)
+		}
+		fed.files++
+		if g.proc == nil {
+			return nil
+		}
+		g.pending = append(g.pending, p)
+		if len(g.pending) >= filesBatch {
+			flush(g, false)
+		}
+		return nil
+	})
+	for _, root := range order {
+		flush(groups[root], true)
+	}
+	return fed
+}
+
+// otherRoots 返回 roots 中 root 以外的根，交给 root 的 daemon 在遍历时跳过（见 daemonCmd.SkipDirs）。
+// roots 已经过 fswalk.CanonicalRoots：仍互相嵌套的根（如设置了 -max-depth）由各自的 daemon 遍历，外层不再重复。
+func otherRoots(roots []string, root string) []string {
+	out := make([]string, 0, len(roots))
+	for _, r := range roots {
+		if r != root {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
 func parseRoots(s string) []string {
 	s = strings.TrimSpace(s)
 	if s == "" {
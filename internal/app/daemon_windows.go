@@ -7,20 +7,26 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
-	"runtime/debug"
-	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"office_find_item/internal/budget"
 	"office_find_item/internal/extract"
+	"office_find_item/internal/fswalk"
+	"office_find_item/internal/quarantine"
+	"office_find_item/internal/sandbox"
+	"office_find_item/internal/sched"
+	"office_find_item/internal/search"
+	"office_find_item/internal/throttle"
 	"office_find_item/internal/winutil"
 )
 
@@ -32,6 +38,25 @@ type daemonCmd struct {
 	QueryID     uint64 `json:"queryId"`
 	ContextLen  int    `json:"contextLen"`
 	MaxSnippets int    `json:"maxSnippets"`
+	// PDFBackends 为本次查询的 PDF 后端链（如 "pdftotext,purego"），空表示按环境变量/默认
+	PDFBackends string `json:"pdfBackends,omitempty"`
+	// Meta 为文件元数据条件（扩展名、大小、修改时间），nil 表示不限
+	Meta *fswalk.Meta `json:"meta,omitempty"`
+	// Files 非空时不遍历根目录，只搜索这些文件（-files-from）；addFiles 命令中为本批文件
+	Files []string `json:"files,omitempty"`
+	// FilesFollow 为 true 时不遍历根目录，文件列表随后以 addFiles 命令分批发送（最后一批 Last 为 true），
+	// 长列表不必整个放进一行命令
+	FilesFollow bool `json:"filesFollow,omitempty"`
+	// Last 表示 addFiles 为本次查询的最后一批文件
+	Last bool `json:"last,omitempty"`
+	// RunID 标识一次查询（同一客户端发给各 daemon 的相同），隔离列表据此每次查询只消耗一次跳过次数
+	RunID string `json:"runId,omitempty"`
+	// Errors 为 true 时无法读取的文件以 error 事件（带 Status）报告；旧调用方不设置，只收到 skipped 事件
+	Errors bool `json:"errors,omitempty"`
+	// Schedule 为本次查询的处理顺序（sched.ParseKeys），空表示按 OFIND_SCHEDULE（默认遍历顺序）
+	Schedule string `json:"schedule,omitempty"`
+	// SkipDirs 为本次查询的其它根（fswalk.Options.SkipDirs）：位于本 daemon 根之下、未能合并的根由各自的 daemon 遍历
+	SkipDirs []string `json:"skipDirs,omitempty"`
 }
 
 type daemonOut struct {
@@ -43,26 +68,93 @@ type daemonOut struct {
 	Extension string   `json:"extension,omitempty"`
 	Size      int64    `json:"size,omitempty"`
 	ModTime   int64    `json:"modTime,omitempty"`
+	// Backend 为产生正文的提取后端；多个关键词用到不同后端时以逗号分隔
+	Backend string `json:"backend,omitempty"`
+	// Encrypted 表示文件是加密的：result 中为已解密，error（Status 为 "encrypted"）中为仍无法打开
+	Encrypted bool `json:"encrypted,omitempty"`
+	// Truncated 非空表示只搜索了部分内容（超出 zip/XML 安全预算、页数上限等），内容为原因
+	Truncated string `json:"truncated,omitempty"`
+	// Status 为 error 事件的原因代码（extract.Status，如 "timeout"、"corrupt"、"backend_missing"），Message 为详情
+	Status extract.Status `json:"status,omitempty"`
+	// Deferred 为 done 事件中因内存预算不足而延后处理的文件数
+	Deferred uint64 `json:"deferred,omitempty"`
 }
 
-var daemonSupportedExt = map[string]struct{}{
-	".txt":  {},
-	".md":   {},
-	".log":  {},
-	".csv":  {},
-	".json": {},
-	".xml":  {},
-	".ini":  {},
-	".yaml": {},
-	".yml":  {},
-	".doc":  {},
-	".docx": {},
-	".xls":  {},
-	".xlsx": {},
-	".ppt":  {},
-	".pptx": {},
-	".pdf":  {},
-	".vsdx": {},
+// skippedOut 为只认 skipped 的旧调用方生成 error 事件的替代（两者只发其一，见 fileErrorOut）：
+// Message 为原因（"timeout"、"encrypted"），或 "refused: 详情"、"error: 详情"。
+func skippedOut(queryID uint64, path, ext string, err error) daemonOut {
+	out := daemonOut{Type: "skipped", QueryID: queryID, Path: path, Extension: ext}
+	switch reason := search.SkipReason(err); reason {
+	case "encrypted":
+		out.Message, out.Encrypted = reason, true
+	case "timeout":
+		out.Message = reason
+	default:
+		out.Message = reason + ": " + err.Error()
+	}
+	return out
+}
+
+// errorOut 生成无法读取的文件的 error 事件，与“没有命中”区分。
+func errorOut(queryID uint64, path, ext string, err error) daemonOut {
+	status := extract.StatusOf(err)
+	return daemonOut{
+		Type:      "error",
+		QueryID:   queryID,
+		Path:      path,
+		Extension: ext,
+		Status:    status,
+		Message:   err.Error(),
+		Encrypted: status == extract.StatusEncrypted,
+	}
+}
+
+// fileFeed 接收一次查询分批发来的文件（setQuery 的 FilesFollow + addFiles），交给 fswalk.WalkPaths。
+type fileFeed struct {
+	queryID uint64
+	ctx     context.Context
+	ch      chan []string
+}
+
+// add 交出一批文件；处理跟不上时阻塞（命令循环随之暂停读取，客户端写入也就放慢），查询取消时丢弃。
+func (f *fileFeed) add(files []string, last bool) {
+	if len(files) > 0 {
+		select {
+		case f.ch <- files:
+		case <-f.ctx.Done():
+		}
+	}
+	if last {
+		close(f.ch)
+	}
+}
+
+func (f *fileFeed) paths() fswalk.Paths {
+	return func(yield func(string) error) error {
+		for {
+			select {
+			case batch, ok := <-f.ch:
+				if !ok {
+					return nil
+				}
+				for _, p := range batch {
+					if err := yield(p); err != nil {
+						return err
+					}
+				}
+			case <-f.ctx.Done():
+				return f.ctx.Err()
+			}
+		}
+	}
+}
+
+// fileErrorOut 生成无法读取的文件的事件：cmd.Errors 时为 error 事件，否则为兼容的 skipped 事件。
+func fileErrorOut(cmd daemonCmd, path, ext string, err error) daemonOut {
+	if cmd.Errors {
+		return errorOut(cmd.QueryID, path, ext, err)
+	}
+	return skippedOut(cmd.QueryID, path, ext, err)
 }
 
 func RunDaemon(opts CLIOptions) error {
@@ -90,6 +182,25 @@ func RunDaemon(opts CLIOptions) error {
 	)
 
 	debugEnabled := os.Getenv("OFIND_DEBUG_CONSOLE") == "1" || os.Getenv("OFIND_DEBUG") == "1"
+
+	// 隔离列表：曾导致超时、内存超限或崩溃的文件在之后若干次查询中跳过。
+	// 正在处理的文件定期写入 journal，daemon 崩溃后由父进程据此隔离（见 startDaemonProcess）。
+	var qstore *quarantine.Store
+	if quarantine.Enabled() {
+		if s, err := quarantine.Open(quarantine.DefaultPath()); err == nil {
+			qstore = s
+			defer qstore.CloseJournal()
+			go func() {
+				ticker := time.NewTicker(500 * time.Millisecond)
+				defer ticker.Stop()
+				for range ticker.C {
+					_ = qstore.WriteJournal()
+				}
+			}()
+		} else if debugEnabled {
+			log.Printf("[QUARANTINE] open failed: %v", err)
+		}
+	}
 	type currentWork struct {
 		Path  string
 		Start time.Time
@@ -98,13 +209,22 @@ func RunDaemon(opts CLIOptions) error {
 	cur.Store(currentWork{})
 	var processed uint64
 
-	startQueryMonitor := func(ctx context.Context, cmd daemonCmd, cancel context.CancelFunc) {
+	// 内存预算：按文件开销放行提取，超出时延后处理而不是取消查询（见 internal/budget）
+	mem := budget.Default()
+
+	// 后台模式（OFIND_BACKGROUND=1）：降低优先级，按文件数/读取量限速，前台活跃时进一步放慢
+	var thr *throttle.Throttle
+	if throttle.Enabled() {
+		if err := throttle.LowerPriority(); err != nil && debugEnabled {
+			log.Printf("[BACKGROUND] lower priority failed: %v", err)
+		}
+		thr = throttle.New(throttle.LimitsFromEnv())
+	}
+
+	startQueryMonitor := func(ctx context.Context, cmd daemonCmd) {
 		go func() {
-			maxAlloc := maxAllocBytes()
-			// 如果用户明确设置OFIND_MAX_ALLOC_MB=0，则不启动内存监控
-			if maxAlloc == 0 {
-				return
-			}
+			limit := mem.Total()
+			recorded := false
 			ticker := time.NewTicker(2 * time.Second)
 			defer ticker.Stop()
 			var lastIO winutil.ProcessIOCounters
@@ -119,16 +239,14 @@ func RunDaemon(opts CLIOptions) error {
 				var m runtime.MemStats
 				runtime.ReadMemStats(&m)
 
-				// 内存硬限制：始终生效，无论是否启用调试模式
-				if m.Alloc > maxAlloc {
-					log.Printf("[HARD-LIMIT] PID=%d | QueryID=%d | Alloc=%.2f MiB | Limit=%.2f MiB | Action=cancel",
+				// 超出内存预算：预算管理器暂停放行新文件，查询继续（每次查询记录一次）。
+				// 不据此隔离文件：提取多在沙箱子进程中进行，本进程的堆说明不了是哪个文件；
+				// 隔离只依据确凿的证据（子进程超出内存上限或崩溃、daemon 崩溃时的 journal）
+				if limit > 0 && int64(m.Alloc) > limit && !recorded {
+					recorded = true
+					log.Printf("[BUDGET] PID=%d | QueryID=%d | Alloc=%.2f MiB | Budget=%.2f MiB | Action=defer",
 						os.Getpid(), cmd.QueryID,
-						float64(m.Alloc)/1024/1024, float64(maxAlloc)/1024/1024)
-					debug.FreeOSMemory()
-					if cancel != nil {
-						cancel()
-					}
-					return
+						float64(m.Alloc)/1024/1024, float64(limit)/1024/1024)
 				}
 
 				// 仅在调试模式下输出详细监控信息
@@ -180,7 +298,10 @@ func RunDaemon(opts CLIOptions) error {
 		}()
 	}
 
+	// feed 为当前查询的分批文件列表，只在命令循环中读写
+	var feed *fileFeed
 	startSearch := func(cmd daemonCmd) {
+		feed = nil
 		termsRaw := []string{strings.TrimSpace(cmd.Query), strings.TrimSpace(cmd.Query2), strings.TrimSpace(cmd.Query3)}
 		terms := make([]string, 0, 3)
 		for _, t := range termsRaw {
@@ -198,15 +319,32 @@ func RunDaemon(opts CLIOptions) error {
 		ctx, cxl := context.WithCancel(context.Background())
 		cancel = cxl
 		searchMu.Unlock()
+		if err := extract.ValidatePDFBackends(cmd.PDFBackends); err != nil {
+			emit(daemonOut{Type: "status", QueryID: cmd.QueryID, Message: err.Error()})
+			emit(daemonOut{Type: "done", QueryID: cmd.QueryID})
+			return
+		}
+		ctx = extract.WithPDFBackends(ctx, cmd.PDFBackends)
 
 		if len(terms) == 0 {
 			emit(daemonOut{Type: "status", QueryID: cmd.QueryID, Message: "idle"})
 			return
 		}
 
+		if cmd.FilesFollow {
+			feed = &fileFeed{queryID: cmd.QueryID, ctx: ctx, ch: make(chan []string, 4)}
+		}
+		files := feed
+
 		atomic.StoreUint64(&processed, 0)
 		cur.Store(currentWork{})
-		startQueryMonitor(ctx, cmd, cxl)
+		startQueryMonitor(ctx, cmd)
+		var deferred uint64
+		// 隔离列表按查询消耗跳过次数；旧客户端不发 RunID 时用本进程与 queryId 组成
+		run := cmd.RunID
+		if run == "" {
+			run = fmt.Sprintf("%d-%d", os.Getpid(), cmd.QueryID)
+		}
 
 		workers := opts.Workers
 		if workers <= 0 {
@@ -235,7 +373,10 @@ func RunDaemon(opts CLIOptions) error {
 			maxTotal = 12
 		}
 
-		jobs := make(chan string, workers*4)
+		// 单个文件（所有关键词合计）的处理时限，避免一个异常文件占住 worker 整个查询
+		fileTimeout := extract.FileTimeout()
+
+		jobs := make(chan string)
 		wg := sync.WaitGroup{}
 		wg.Add(workers)
 		for i := 0; i < workers; i++ {
@@ -245,6 +386,9 @@ func RunDaemon(opts CLIOptions) error {
 					if ctx.Err() != nil {
 						return
 					}
+					if err := thr.Wait(ctx); err != nil {
+						return
+					}
 					atomic.AddUint64(&processed, 1)
 					startAt := time.Now()
 					cur.Store(currentWork{Path: p, Start: startAt})
@@ -264,9 +408,55 @@ func RunDaemon(opts CLIOptions) error {
 						}
 					}
 
+					needContent := false
+					for _, m := range matchedInName {
+						needContent = needContent || !m
+					}
+					if needContent {
+						if e, skip := qstore.Check(p, run); skip {
+							msg := fmt.Sprintf("已隔离（%s，剩余 %d 次查询）", e.Reason, e.SkipRuns)
+							emit(fileErrorOut(cmd, p, ext, extract.ErrorFromStatus(extract.StatusQuarantined, msg)))
+							continue
+						}
+					}
+
 					// 流式处理：每个词只取首次命中 + 上下文（FileFindFirst），命中即停该词扫描。
 					allMatch := true
 					snipsOut := make([]string, 0, maxTotal)
+					backends := make([]string, 0, 1)
+					encrypted := false
+					truncated := ""
+					// 按估算开销申请内存预算；放不下时等待（不计入单文件时限），让便宜的文件先处理
+					free := func() {}
+					// 格式只解析一次，预算估算、沙箱路由与每个关键词的提取共用
+					format := ""
+					if needContent {
+						format = extract.FileFormat(p)
+						if !extract.SupportedExt(ext) && cmd.Meta != nil && cmd.Meta.HasExt(ext) {
+							format = extract.SniffFormat(p)
+						}
+						var size int64
+						if st, err := os.Stat(p); err == nil {
+							size = st.Size()
+						}
+						cost := budget.Estimate(format, size)
+						if sandbox.Routed(format) {
+							cost = 0 // 在子进程内提取，内存由子进程上限约束
+						}
+						rel, waited, err := mem.Acquire(ctx, cost)
+						if err != nil {
+							return
+						}
+						free = rel
+						if waited {
+							atomic.AddUint64(&deferred, 1)
+						}
+					}
+					// 超时后提取可能仍在后台运行、占着内存：预算等它结束才归还
+					rctx, release := extract.WithRelease(ctx, free)
+
+					tctx, tcancel := extract.WithFileTimeout(rctx, fileTimeout)
+					qstore.Begin(p)
 					for i, t := range terms {
 						if matchedInName[i] {
 							nameSnips := extract.FindSnippets(fileName, t, contextLen, maxSnips)
@@ -279,11 +469,23 @@ func RunDaemon(opts CLIOptions) error {
 							continue
 						}
 
-						found, snip, err := extract.FileFindFirst(ctx, p, t, contextLen)
+						fctx, trace := extract.WithTrace(tctx)
+						found, snip, err := sandbox.FindFirstAs(fctx, format, p, t, contextLen)
 						if err != nil {
 							if debugEnabled {
 								log.Printf("[ERROR] FileFindFirst failed for %s: %v", p, err)
 							}
+							if ctx.Err() == nil {
+								emit(fileErrorOut(cmd, p, ext, err))
+								switch {
+								case errors.Is(err, extract.ErrTimeout):
+									qstore.Record(p, quarantine.ReasonTimeout)
+								case errors.Is(err, sandbox.ErrHelperMemory):
+									qstore.Record(p, quarantine.ReasonMemory)
+								case errors.Is(err, sandbox.ErrHelperCrashed):
+									qstore.Record(p, quarantine.ReasonCrash)
+								}
+							}
 							allMatch = false
 							break
 						}
@@ -294,7 +496,20 @@ func RunDaemon(opts CLIOptions) error {
 						if snip != "" && len(snipsOut) < maxTotal {
 							snipsOut = append(snipsOut, snip)
 						}
+						if trace.Backend != "" && !containsString(backends, trace.Backend) {
+							backends = append(backends, trace.Backend)
+						}
+						encrypted = encrypted || trace.Encrypted
+						if trace.Truncated != "" && truncated == "" {
+							truncated = trace.Truncated
+							if debugEnabled {
+								log.Printf("[TRUNCATED] %s: %s", p, truncated)
+							}
+						}
 					}
+					tcancel()
+					qstore.End(p)
+					release()
 
 					if !allMatch || len(snipsOut) == 0 {
 						if debugEnabled {
@@ -323,6 +538,9 @@ func RunDaemon(opts CLIOptions) error {
 						Extension: ext,
 						Size:      size,
 						ModTime:   modTime,
+						Backend:   strings.Join(backends, ","),
+						Encrypted: encrypted,
+						Truncated: truncated,
 					})
 
 					if debugEnabled {
@@ -337,35 +555,55 @@ func RunDaemon(opts CLIOptions) error {
 		}
 
 		// 启动流式遍历：边遍历边搜索，解决卡顿和内存占用问题。
+		// 开启内容识别时，未知扩展名（含无扩展名）的文件也交给提取器按文件头判断格式。
+		sniffUnknown := extract.SniffUnknownEnabled()
+		// 开启调度（OFIND_SCHEDULE 或查询的 Schedule）时遍历结果经调度器排序后交给 worker，避免树前部的大文件挡住其它结果
+		policy := sched.PolicyFromEnv()
+		if cmd.Schedule != "" {
+			policy.Keys = sched.ParseKeys(cmd.Schedule)
+		}
+		walked := make(chan sched.Job, workers*4)
+		go sched.Run(ctx, policy, walked, jobs)
 		go func() {
-			defer close(jobs)
-			_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-				if ctx.Err() != nil {
-					return filepath.SkipAll
-				}
-				if err != nil {
-					return nil
-				}
-				if d.IsDir() {
+			defer close(walked)
+			// 并发列目录（OFIND_WALK_READERS），无法读取的目录以 error 事件报告
+			walkOpts := fswalk.OptionsFromEnv()
+			walkOpts.SkipDirs = cmd.SkipDirs
+			walkOpts.OnDirError = func(dir string, err error) {
+				emit(errorOut(cmd.QueryID, dir, "", err))
+			}
+			visit := func(path string, d fs.DirEntry) error {
+				ext := strings.ToLower(filepath.Ext(d.Name()))
+				// -ext 显式指定的扩展名即使未注册也交给提取器（按文件头识别）
+				if !extract.SupportedExt(ext) && !sniffUnknown && (cmd.Meta == nil || !cmd.Meta.HasExt(ext)) {
 					return nil
 				}
-				ext := strings.ToLower(filepath.Ext(d.Name()))
-				if _, ok := daemonSupportedExt[ext]; !ok {
+				if cmd.Meta != nil && !cmd.Meta.Match(d) {
 					return nil
 				}
 				select {
-				case jobs <- path:
+				case walked <- policy.NewJob(path, d):
 				case <-ctx.Done():
-					return filepath.SkipAll
+					return ctx.Err()
 				}
 				return nil
-			})
+			}
+			if files != nil {
+				_ = fswalk.WalkPaths(ctx, files.paths(), walkOpts, visit)
+				return
+			}
+			if len(cmd.Files) > 0 {
+				_ = fswalk.WalkPaths(ctx, fswalk.PathList(cmd.Files), walkOpts, visit)
+				return
+			}
+			_ = fswalk.Walk(ctx, []string{root}, walkOpts, visit)
 		}()
 
 		go func() {
 			wg.Wait()
 			cur.Store(currentWork{})
-			emit(daemonOut{Type: "done", QueryID: cmd.QueryID})
+			_ = qstore.Save()
+			emit(daemonOut{Type: "done", QueryID: cmd.QueryID, Deferred: atomic.LoadUint64(&deferred)})
 		}()
 	}
 
@@ -384,6 +622,13 @@ func RunDaemon(opts CLIOptions) error {
 		switch cmd.Cmd {
 		case "setQuery":
 			startSearch(cmd)
+		case "addFiles":
+			if feed != nil && feed.queryID == cmd.QueryID {
+				feed.add(cmd.Files, cmd.Last)
+				if cmd.Last {
+					feed = nil
+				}
+			}
 		}
 	}
 }
@@ -409,23 +654,11 @@ func bytesTrimSpace(b []byte) []byte {
 	return b[i:j]
 }
 
-func maxAllocBytes() uint64 {
-	if v := strings.TrimSpace(os.Getenv("OFIND_MAX_ALLOC_MB")); v != "" {
-		if n, err := strconv.ParseUint(v, 10, 64); err == nil {
-			if n == 0 {
-				return 0 // 用户明确禁用内存限制
-			}
-			// 添加合理上限（16384 MiB = 16GB），避免设置过大值导致问题
-			const maxLimit = 16384
-			if n > maxLimit {
-				n = maxLimit
-			}
-			return n * 1024 * 1024
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
 		}
-		// 解析失败时使用默认值
-	}
-	if runtime.GOARCH == "386" {
-		return 1200 * 1024 * 1024
 	}
-	return 4096 * 1024 * 1024
+	return false
 }
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"office_find_item/internal/quarantine"
+)
+
+// quarantineAfterExit 在 daemon 退出后处理其 journal：意外退出（崩溃）时把正在处理的文件记入隔离列表，
+// 正常关闭时删除 journal。返回新隔离的文件数。
+func quarantineAfterExit(pid int, crashed bool) int {
+	if !quarantine.Enabled() {
+		return 0
+	}
+	path := quarantine.DefaultPath()
+	if !crashed {
+		quarantine.RemoveJournal(path, pid)
+		return 0
+	}
+	s, err := quarantine.Open(path)
+	if err != nil {
+		quarantine.RemoveJournal(path, pid)
+		return 0
+	}
+	n, _ := s.AdoptJournal(pid)
+	_ = s.Save()
+	return n
+}
+
+// RunQuarantine 列出（list）或清除（clear 为文件路径，"all" 表示全部）隔离列表。
+func RunQuarantine(list bool, clear string) error {
+	path := quarantine.DefaultPath()
+	s, err := quarantine.Open(path)
+	if err != nil {
+		return err
+	}
+	if clear = strings.TrimSpace(clear); clear != "" {
+		target := clear
+		if strings.EqualFold(clear, "all") {
+			target = ""
+		}
+		n := s.Clear(target)
+		if err := s.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("已从隔离列表移除 %d 个文件\n", n)
+	}
+	if !list {
+		return nil
+	}
+	entries := s.Entries()
+	fmt.Printf("隔离列表：%s（%d 条）\n", path, len(entries))
+	for _, e := range entries {
+		state := "记录中"
+		if e.Quarantined() {
+			state = fmt.Sprintf("隔离中，剩余 %d 次查询", e.SkipRuns)
+		}
+		stale := ""
+		if st, err := os.Stat(e.Path); err != nil || st.Size() != e.Size || st.ModTime().UnixNano() != e.ModTime {
+			stale = "（文件已改动，下次遇到时自动移除）"
+		}
+		fmt.Printf("%s\t%s\t%s × %d\t%s%s\n", e.At.Format(time.DateTime), state, e.Reason, e.Strikes, e.Path, stale)
+	}
+	return nil
+}
@@ -7,15 +7,18 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
-	"runtime"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
+	"office_find_item/internal/budget"
+	"office_find_item/internal/fswalk"
 	"office_find_item/internal/search"
 )
 
@@ -26,6 +29,9 @@ type daemonProcess struct {
 
 	mu     sync.Mutex
 	closed bool
+	// query 为最近一次 setQuery 的 queryId，queryDone 表示已收到它的 done 事件
+	query     uint64
+	queryDone bool
 }
 
 func (p *daemonProcess) Close() {
@@ -47,7 +53,12 @@ func (p *daemonProcess) Close() {
 	}
 }
 
-func (p *daemonProcess) SetQuery(query string, query2 string, query3 string, queryID uint64, contextLen int, maxSnippets int) error {
+func (p *daemonProcess) SetQuery(query string, query2 string, query3 string, queryID uint64, contextLen int, maxSnippets int, pdfBackends string, meta *fswalk.Meta) error {
+	return p.send(daemonCmd{Cmd: "setQuery", Query: query, Query2: query2, Query3: query3, QueryID: queryID, ContextLen: contextLen, MaxSnippets: maxSnippets, PDFBackends: pdfBackends, Meta: meta})
+}
+
+// send 向 daemon 发送一条命令（CLI 需要设置 SetQuery 之外的字段时直接使用）。
+func (p *daemonProcess) send(cmd daemonCmd) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if p.closed {
@@ -56,13 +67,22 @@ func (p *daemonProcess) SetQuery(query string, query2 string, query3 string, que
 	if p.stdin == nil {
 		return errors.New("daemon stdin 不可用")
 	}
-	cmd := daemonCmd{Cmd: "setQuery", Query: query, Query2: query2, Query3: query3, QueryID: queryID, ContextLen: contextLen, MaxSnippets: maxSnippets}
+	if cmd.Cmd == "setQuery" {
+		p.query, p.queryDone = cmd.QueryID, false
+		cmd.Errors = true
+		if cmd.RunID == "" {
+			cmd.RunID = clientSession + "-" + strconv.FormatUint(cmd.QueryID, 10)
+		}
+	}
 	b, _ := json.Marshal(cmd)
 	b = append(b, '\n')
 	_, err := p.stdin.Write(b)
 	return err
 }
 
+// clientSession 区分不同的客户端进程（queryId 在每个进程里都从头计数），与 queryId 组成 RunID。
+var clientSession = strconv.FormatInt(time.Now().UnixNano(), 36)
+
 type subprocGroup struct {
 	mu   sync.Mutex
 	cmds []*exec.Cmd
@@ -180,6 +200,13 @@ func startDaemonProcess(exePath string, root string, workers int, enablePureGoPD
 			line := s.Bytes()
 			var out daemonOut
 			if err := json.Unmarshal(line, &out); err == nil {
+				if out.Type == "done" {
+					p.mu.Lock()
+					if out.QueryID == p.query {
+						p.queryDone = true
+					}
+					p.mu.Unlock()
+				}
 				onOut(out)
 			} else {
 				// 尝试捕获非 JSON 输出（如 panic 或 log），转发给 UI 以便于排查问题
@@ -190,25 +217,30 @@ func startDaemonProcess(exePath string, root string, workers int, enablePureGoPD
 			}
 		}
 		_ = cmd.Wait()
+		p.mu.Lock()
+		crashed := !p.closed
+		query, pending := p.query, p.query != 0 && !p.queryDone
+		p.mu.Unlock()
 		p.Close()
+		if n := quarantineAfterExit(cmd.Process.Pid, crashed); n > 0 {
+			onOut(daemonOut{Type: "status", Message: fmt.Sprintf("daemon（%s）异常退出，已隔离 %d 个正在处理的文件", root, n)})
+		}
+		if crashed && pending {
+			// 异常退出时不会再有 done：补发一个，等待所有 daemon 结束的一方（CLI、UI）不会一直等下去
+			onOut(daemonOut{Type: "done", QueryID: query, Message: fmt.Sprintf("daemon（%s）异常退出，结果可能不完整", root)})
+		}
 	}()
 
 	return p, nil
 }
 
+// ensureMemoryEnv 把内存预算（含兼容的旧变量解析结果）传给 daemon，GC 软上限随之设置。
 func ensureMemoryEnv(env []string) []string {
-	limitMB := defaultMemLimitMB()
-	limitStr := strconv.FormatInt(limitMB, 10)
-	env = ensureEnv(env, "OFIND_MEM_LIMIT_MB", limitStr)
-	env = ensureEnv(env, "GOMEMLIMIT", limitStr+"MiB")
-	return env
-}
-
-func defaultMemLimitMB() int64 {
-	if runtime.GOARCH == "386" {
-		return 1400
+	env = ensureEnv(env, "OFIND_MEMORY_BUDGET_MB", strconv.FormatInt(budget.LimitMB(), 10))
+	if soft := budget.SoftLimit(); soft > 0 {
+		env = ensureEnv(env, "GOMEMLIMIT", strconv.FormatInt(soft>>20, 10)+"MiB")
 	}
-	return 4096
+	return env
 }
 
 func ensureEnv(env []string, key string, value string) []string {
@@ -16,6 +16,7 @@ import (
 	"github.com/lxn/walk"
 	"github.com/lxn/walk/declarative"
 	"office_find_item/internal/extract"
+	"office_find_item/internal/fswalk"
 	"office_find_item/internal/winutil"
 )
 
@@ -128,7 +129,7 @@ func RunUI() error {
 
 		daemonMu.Lock()
 		for _, d := range daemons {
-			_ = d.SetQuery("", "", "", myGen, 30, 1)
+			_ = d.SetQuery("", "", "", myGen, 30, 1, "", nil)
 		}
 		daemonMu.Unlock()
 		clearSelection()
@@ -182,15 +183,8 @@ func RunUI() error {
 		debounceMu.Unlock()
 
 		exePath, _ := os.Executable()
-		rawParts := strings.Split(roots, ";")
-		parts := make([]string, 0, len(rawParts))
-		for _, p := range rawParts {
-			p = strings.TrimSpace(p)
-			if p == "" {
-				continue
-			}
-			parts = append(parts, p)
-		}
+		// 每个根一个 daemon：重叠的根（D:\ 与 D:\Docs）合并，避免同一目录扫描两遍
+		parts := fswalk.CanonicalRoots(strings.Split(roots, ";"), fswalk.OptionsFromEnv())
 		nextKey := strings.Join(parts, ";")
 		enablePureGoPDF := false
 		if pdfPureGoCB != nil {
@@ -221,6 +215,14 @@ func RunUI() error {
 				if atomic.LoadUint32(&uiClosed) != 0 {
 					return
 				}
+				if out.Type == "done" {
+					// done 决定搜索状态何时结束，不能丢
+					select {
+					case resultCh <- out:
+					case <-closeCh:
+					}
+					return
+				}
 				select {
 				case resultCh <- out:
 				default:
@@ -232,8 +234,8 @@ func RunUI() error {
 			}
 		}
 		// send query to all
-		for _, d := range daemons {
-			_ = d.SetQuery(q1, q2, q3, myGen, 30, 1)
+		for root, d := range daemons {
+			_ = d.send(daemonCmd{Cmd: "setQuery", Query: q1, Query2: q2, Query3: q3, QueryID: myGen, ContextLen: 30, MaxSnippets: 1, SkipDirs: otherRoots(parts, root)})
 		}
 		daemonMu.Unlock()
 	}
@@ -403,6 +405,8 @@ func RunUI() error {
 	go func() {
 		const maxBufferItems = 20000
 		buffer := make([]daemonOut, 0, 2048)
+		// 当前查询无法读取的文件数（error 事件），只在 UI 线程中读写
+		var failed, failedGen uint64
 		// 小批量刷新：避免一次性处理太多导致 UI 线程长时间阻塞（表现为白屏/无响应）
 		ticker := time.NewTicker(80 * time.Millisecond)
 		defer ticker.Stop()
@@ -415,7 +419,7 @@ func RunUI() error {
 				if atomic.LoadUint32(&uiClosed) != 0 {
 					continue
 				}
-				if len(buffer) < maxBufferItems {
+				if len(buffer) < maxBufferItems || out.Type == "done" {
 					buffer = append(buffer, out)
 				} else {
 					// buffer 满了，丢弃后续数据以防 32 位内存爆掉
@@ -445,10 +449,14 @@ func RunUI() error {
 					debounceMu.Lock()
 					curGen := gen
 					debounceMu.Unlock()
+					if failedGen != curGen {
+						failed, failedGen = 0, curGen
+					}
 
 					rowsToAdd := make([]ResultRow, 0, 256)
 					var lastStatusMsg string
 					var isDone bool
+					var deferred uint64
 					start := time.Now()
 					const maxAppendPerTick = 200
 					const maxUITick = 25 * time.Millisecond
@@ -473,12 +481,16 @@ func RunUI() error {
 								Size:      formatSize(out.Size),
 								ModTime:   time.Unix(out.ModTime, 0).Format("2006-01-02 15:04"),
 							})
+						case "error":
+							// 超时、加密、损坏等无法读取的文件：计数显示在状态栏，避免被当作“没有命中”
+							failed++
 						case "status":
 							if out.Message != "" {
 								lastStatusMsg = out.Message
 							}
 						case "done":
 							isDone = true
+							deferred += out.Deferred
 						}
 					}
 
@@ -495,12 +507,18 @@ func RunUI() error {
 					}
 
 					// 更新状态栏
-					if isDone {
-						setStatus(fmt.Sprintf("Done. Matches: %d", model.RowCount()))
+					failedNote := ""
+					if failed > 0 {
+						failedNote = fmt.Sprintf("（无法读取 %d 个文件）", failed)
+					}
+					if isDone && deferred > 0 {
+						setStatus(fmt.Sprintf("Done. Matches: %d%s（内存预算不足，%d 个文件延后处理）", model.RowCount(), failedNote, deferred))
+					} else if isDone {
+						setStatus(fmt.Sprintf("Done. Matches: %d%s", model.RowCount(), failedNote))
 					} else if lastStatusMsg != "" {
 						setStatus(lastStatusMsg)
 					} else if len(rowsToAdd) > 0 {
-						setStatus(fmt.Sprintf("Matches: %d", model.RowCount()))
+						setStatus(fmt.Sprintf("Matches: %d%s", model.RowCount(), failedNote))
 					}
 				})
 			}
@@ -548,7 +566,7 @@ func RunUI() error {
 
 		daemonMu.Lock()
 		for _, d := range daemons {
-			_ = d.SetQuery("", "", "", myGen, 30, 1)
+			_ = d.SetQuery("", "", "", myGen, 30, 1, "", nil)
 		}
 		daemonMu.Unlock()
 
@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
-	"path/filepath"
 	"strings"
 
+	"office_find_item/internal/fswalk"
+	"office_find_item/internal/sched"
 	"office_find_item/internal/search"
+	"office_find_item/internal/throttle"
 )
 
 // RunWorker 用于 UI 进程启动的子进程：
@@ -19,23 +21,34 @@ func RunWorker(opts CLIOptions) error {
 		return errors.New("query 为空")
 	}
 
-	roots := parseRoots(opts.Roots)
-	if len(roots) == 0 {
-		return errors.New("roots 为空")
-	}
 	// Worker 模式建议单 root；这里仍按传入列表处理。
-	for i := range roots {
-		abs, err := filepath.Abs(strings.TrimSpace(roots[i]))
-		if err == nil {
-			roots[i] = abs
+	roots := parseRoots(opts.Roots)
+	var files fswalk.Paths
+	if opts.FilesFrom != "" {
+		src, closeList, err := fswalk.OpenPaths(opts.FilesFrom)
+		if err != nil {
+			return err
 		}
+		defer closeList()
+		files = src
+	} else if len(roots) == 0 {
+		return errors.New("roots 为空")
 	}
 
 	cfg := search.Config{
-		Roots:      roots,
-		Query:      query,
-		Workers:    opts.Workers,
-		ContextLen: 30,
+		Roots:       roots,
+		Files:       files,
+		Query:       query,
+		Workers:     opts.Workers,
+		ContextLen:  30,
+		PDFBackends: opts.PDFBackends,
+		Meta:        opts.Meta,
+		Background:  throttle.Enabled(),
+	}
+	if opts.Schedule != "" {
+		policy := sched.PolicyFromEnv()
+		policy.Keys = sched.ParseKeys(opts.Schedule)
+		cfg.Schedule = &policy
 	}
 
 	enc := json.NewEncoder(os.Stdout)
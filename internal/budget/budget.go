@@ -0,0 +1,225 @@
+package budget
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 内存预算：按格式与文件大小估算每个文件提取时的内存开销，只在估算总和不超过预算时放行；
+// 放不下的文件按到达顺序排队，在预算归还时放行，而不是像以前那样在内存超限时取消整次查询
+// （便宜文件优先由 internal/sched 的调度顺序负责）。实测堆超过预算时暂停放行新文件，直到回落
+// （至少保证有一个文件在处理，避免饿死）。
+// 预算只衡量本进程的堆：交给沙箱子进程（internal/sandbox）提取的文件由子进程自己的内存上限约束，调用方不计入。
+
+const mib = 1 << 20
+
+// LimitMB 返回内存预算（MiB）：OFIND_MEMORY_BUDGET_MB，0 表示不限制。
+// 未设置时兼容旧变量 OFIND_MAX_ALLOC_MB、OFIND_MEM_LIMIT_MB；默认 32 位 1200 MiB、64 位 4096 MiB。
+func LimitMB() int64 {
+	for _, key := range []string{"OFIND_MEMORY_BUDGET_MB", "OFIND_MAX_ALLOC_MB", "OFIND_MEM_LIMIT_MB"} {
+		if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				if n <= 0 {
+					return 0
+				}
+				if n > 16384 { // 最大 16GB
+					n = 16384
+				}
+				return n
+			}
+		}
+	}
+	if runtime.GOARCH == "386" {
+		return 1200
+	}
+	return 4096
+}
+
+// SoftLimit 返回给 GC 的软内存上限（字节，用于 debug.SetMemoryLimit/GOMEMLIMIT）：预算之上留约 1/6 余量；
+// 预算为 0 时返回 0（不设置）。
+func SoftLimit() int64 {
+	n := LimitMB()
+	return (n + n/6) * mib
+}
+
+// Estimate 估算提取一个文件的内存开销（字节）。format 为 extract 的格式名（text/ooxml/pdf/rtf/html/ifilter）。
+func Estimate(format string, size int64) int64 {
+	if size < 0 {
+		size = 0
+	}
+	switch format {
+	case "text":
+		// 按块流式读取，与文件大小基本无关
+		return 2 * mib
+	case "ooxml":
+		// 按 entry 流式解析，受 zip 安全预算约束
+		return 8*mib + size/2
+	case "pdf":
+		// 纯 Go 解析会把 xref/对象流读入内存，开销最大
+		return 32*mib + size*4
+	case "rtf", "html":
+		return 4*mib + size*2
+	case "ifilter":
+		return 16*mib + size
+	default:
+		return 4*mib + size
+	}
+}
+
+// Manager 按估算开销放行提取任务。
+type Manager struct {
+	total int64
+
+	mu      sync.Mutex
+	used    int64
+	running int
+	// queue 为等待中的任务，按到达顺序放行：排在前面的贵文件不会被后来的便宜文件一直挤占
+	queue []*waiter
+
+	pressure atomic.Bool
+	deferred atomic.Uint64
+}
+
+type waiter struct {
+	cost  int64
+	ready chan struct{} // 放行时关闭
+}
+
+// NewManager 创建总预算为 total 字节的管理器；total <= 0 表示不限制。
+func NewManager(total int64) *Manager {
+	return &Manager{total: total}
+}
+
+var (
+	defaultOnce sync.Once
+	defaultMgr  *Manager
+)
+
+// Default 返回按 LimitMB 配置的进程级管理器，并启动堆使用监控。
+func Default() *Manager {
+	defaultOnce.Do(func() {
+		defaultMgr = NewManager(LimitMB() * mib)
+		if defaultMgr.total > 0 {
+			go defaultMgr.watch(time.Second)
+		}
+	})
+	return defaultMgr
+}
+
+// Total 返回总预算（字节），0 表示不限制。
+func (m *Manager) Total() int64 { return m.total }
+
+// Deferred 返回累计因预算不足而等待过的任务数。
+func (m *Manager) Deferred() uint64 { return m.deferred.Load() }
+
+// Acquire 为开销为 cost 的任务申请预算，放不下或已有任务在等待时排队，按到达顺序在预算归还时放行。
+// 返回的 release 必须调用一次；deferred 表示任务曾因预算不足等待。ctx 结束时返回 ctx.Err()。
+func (m *Manager) Acquire(ctx context.Context, cost int64) (release func(), deferred bool, err error) {
+	if m == nil || m.total <= 0 {
+		return func() {}, false, nil
+	}
+	if cost > m.total {
+		cost = m.total
+	}
+	m.mu.Lock()
+	if len(m.queue) == 0 && m.fits(cost) {
+		m.admit(cost)
+		m.mu.Unlock()
+		return m.releaseFunc(cost), false, nil
+	}
+	w := &waiter{cost: cost, ready: make(chan struct{})}
+	m.queue = append(m.queue, w)
+	m.mu.Unlock()
+	m.deferred.Add(1)
+
+	select {
+	case <-w.ready:
+		return m.releaseFunc(cost), true, nil
+	case <-ctx.Done():
+	}
+	m.mu.Lock()
+	select {
+	case <-w.ready:
+		// 取消与放行同时发生：已记入预算，归还
+		m.mu.Unlock()
+		m.release(cost)
+		return func() {}, true, ctx.Err()
+	default:
+	}
+	for i, q := range m.queue {
+		if q == w {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			break
+		}
+	}
+	// 离开的若是队首，后面的任务可能已经放得下
+	m.admitWaiting()
+	m.mu.Unlock()
+	return func() {}, true, ctx.Err()
+}
+
+// fits 判断开销为 cost 的任务现在能否放行（调用方持有 mu）。没有任务在处理时总是放行，避免饿死。
+func (m *Manager) fits(cost int64) bool {
+	return m.running == 0 || (!m.pressure.Load() && m.used+cost <= m.total)
+}
+
+func (m *Manager) admit(cost int64) {
+	m.used += cost
+	m.running++
+}
+
+// admitWaiting 按顺序放行队首放得下的任务，遇到放不下的即停（调用方持有 mu）。
+func (m *Manager) admitWaiting() {
+	for len(m.queue) > 0 && m.fits(m.queue[0].cost) {
+		w := m.queue[0]
+		m.queue[0] = nil
+		m.queue = m.queue[1:]
+		m.admit(w.cost)
+		close(w.ready)
+	}
+}
+
+func (m *Manager) releaseFunc(cost int64) func() {
+	var once sync.Once
+	return func() { once.Do(func() { m.release(cost) }) }
+}
+
+func (m *Manager) release(cost int64) {
+	m.mu.Lock()
+	m.used -= cost
+	m.running--
+	m.admitWaiting()
+	m.mu.Unlock()
+}
+
+// watch 定期检查实际堆使用：超过预算时暂停放行并尝试归还内存给系统。
+func (m *Manager) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		over := int64(ms.HeapAlloc) > m.total
+		if over {
+			debug.FreeOSMemory()
+		}
+		if m.pressure.Swap(over) && !over {
+			// 堆回落：放行暂停期间排队的任务
+			m.mu.Lock()
+			m.admitWaiting()
+			m.mu.Unlock()
+		}
+	}
+}
+
+// OverBudget 表示实测堆使用当前超过预算（新任务暂停放行）。
+func (m *Manager) OverBudget() bool {
+	return m != nil && m.pressure.Load()
+}
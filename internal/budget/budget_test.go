@@ -0,0 +1,120 @@
+package budget
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestManager_WaitersAdmittedInOrder(t *testing.T) {
+	m := NewManager(100)
+	releaseA, deferred, err := m.Acquire(context.Background(), 60)
+	if err != nil || deferred {
+		t.Fatalf("first acquire: deferred=%v err=%v", deferred, err)
+	}
+
+	acquire := func(cost int64) chan func() {
+		ch := make(chan func(), 1)
+		go func() {
+			release, deferred, err := m.Acquire(context.Background(), cost)
+			if err != nil || !deferred {
+				t.Errorf("acquire %d: deferred=%v err=%v", cost, deferred, err)
+			}
+			ch <- release
+		}()
+		return ch
+	}
+	waitQueued := func(n int) {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			m.mu.Lock()
+			q := len(m.queue)
+			m.mu.Unlock()
+			if q == n {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("queue length %d, want %d", q, n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// 贵的文件放不下，排队；之后到达的便宜文件虽然放得下，也排在它后面，不会把它饿死
+	big := acquire(80)
+	waitQueued(1)
+	cheap := acquire(30)
+	waitQueued(2)
+	select {
+	case <-big:
+		t.Fatal("expensive job admitted over budget")
+	case <-cheap:
+		t.Fatal("cheap job overtook the waiting expensive job")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 归还后按到达顺序放行：先贵的，便宜的要等贵的归还
+	releaseA()
+	releaseBig := <-big
+	select {
+	case <-cheap:
+		t.Fatal("cheap job admitted over budget")
+	case <-time.After(50 * time.Millisecond):
+	}
+	releaseBig()
+	(<-cheap)()
+	if m.Deferred() != 2 {
+		t.Fatalf("Deferred() = %d, want 2", m.Deferred())
+	}
+}
+
+func TestManager_CanceledWaiterLeavesQueue(t *testing.T) {
+	m := NewManager(100)
+	releaseA, _, _ := m.Acquire(context.Background(), 60)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := m.Acquire(ctx, 80); err == nil {
+		t.Fatal("expensive job admitted over budget")
+	}
+	// 取消的队首离开后，后面的任务不再被它挡住
+	release, deferred, err := m.Acquire(context.Background(), 30)
+	if err != nil || deferred {
+		t.Fatalf("acquire after cancel: deferred=%v err=%v", deferred, err)
+	}
+	release()
+	releaseA()
+}
+
+func TestManager_OversizedJobRunsAlone(t *testing.T) {
+	m := NewManager(100)
+	release, deferred, err := m.Acquire(context.Background(), 1000)
+	if err != nil || deferred {
+		t.Fatalf("oversized acquire: deferred=%v err=%v", deferred, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := m.Acquire(ctx, 1); err == nil {
+		t.Fatal("second job admitted while oversized job holds the whole budget")
+	}
+	release()
+	release() // 重复调用无副作用
+	if _, _, err := m.Acquire(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLimitMB_LegacyVariables(t *testing.T) {
+	t.Setenv("OFIND_MEMORY_BUDGET_MB", "")
+	t.Setenv("OFIND_MAX_ALLOC_MB", "")
+	t.Setenv("OFIND_MEM_LIMIT_MB", "900")
+	if got := LimitMB(); got != 900 {
+		t.Fatalf("LimitMB() = %d, want legacy 900", got)
+	}
+	t.Setenv("OFIND_MEMORY_BUDGET_MB", "0")
+	if got := LimitMB(); got != 0 {
+		t.Fatalf("LimitMB() = %d, want 0 (disabled)", got)
+	}
+	if SoftLimit() != 0 {
+		t.Fatal("SoftLimit should be 0 when budget disabled")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io"
 	"strings"
+	"unicode/utf8"
 )
 
 func stringsTrimSpace(s string) string { return strings.TrimSpace(s) }
@@ -36,3 +37,14 @@ func readAllLimit(r io.Reader, limit int64) ([]byte, error) {
 		}
 	}
 }
+
+// truncateUTF8 把 s 截断到最多 n 字节，不切断多字节字符。
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
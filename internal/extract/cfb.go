@@ -0,0 +1,263 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// 复合文档（CFB，[MS-CFB]）的最小只读实现：用于加密 OOXML 的 EncryptionInfo/EncryptedPackage 等流。
+// 只解析 FAT/DIFAT/MiniFAT 与目录，按名称读取整个流；所有扇区链都做长度与循环保护。
+
+const (
+	cfbEndOfChain = 0xFFFFFFFE
+	cfbFreeSect   = 0xFFFFFFFF
+	cfbNoStream   = 0xFFFFFFFF
+
+	cfbTypeStorage = 1
+	cfbTypeStream  = 2
+	cfbTypeRoot    = 5
+)
+
+var errCFBMalformed = fmt.Errorf("%w: 复合文档（CFB）结构损坏", ErrCorrupt)
+
+type cfbEntry struct {
+	Name  string
+	typ   byte
+	left  uint32
+	right uint32
+	child uint32
+	start uint32
+	Size  int64
+}
+
+type cfbFile struct {
+	r          io.ReaderAt
+	size       int64
+	sectorSize int64
+	miniCutoff int64
+	fat        []uint32
+	miniFat    []uint32
+	entries    []cfbEntry
+	miniStream []byte // 根目录项的流，按需读取
+}
+
+// openCFB 解析文件头、FAT 与目录。
+func openCFB(r io.ReaderAt, size int64) (*cfbFile, error) {
+	hdr := make([]byte, 512)
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(hdr, cfbSignature) {
+		return nil, errCFBMalformed
+	}
+	shift := binary.LittleEndian.Uint16(hdr[0x1E:])
+	if shift != 9 && shift != 12 {
+		return nil, errCFBMalformed
+	}
+	c := &cfbFile{
+		r:          r,
+		size:       size,
+		sectorSize: 1 << shift,
+		miniCutoff: int64(binary.LittleEndian.Uint32(hdr[0x38:])),
+	}
+	numFat := binary.LittleEndian.Uint32(hdr[0x2C:])
+	firstDir := binary.LittleEndian.Uint32(hdr[0x30:])
+	firstMiniFat := binary.LittleEndian.Uint32(hdr[0x3C:])
+	firstDifat := binary.LittleEndian.Uint32(hdr[0x44:])
+	maxSectors := uint32(size / c.sectorSize)
+	if numFat > maxSectors {
+		return nil, errCFBMalformed
+	}
+
+	// DIFAT：头部 109 项，之后是 DIFAT 扇区链（每扇区最后 4 字节指向下一扇区）。
+	fatSectors := make([]uint32, 0, numFat)
+	for i := 0; i < 109 && uint32(len(fatSectors)) < numFat; i++ {
+		fatSectors = append(fatSectors, binary.LittleEndian.Uint32(hdr[0x4C+4*i:]))
+	}
+	buf := make([]byte, c.sectorSize)
+	for sec, n := firstDifat, uint32(0); uint32(len(fatSectors)) < numFat; n++ {
+		if sec >= maxSectors || n > maxSectors {
+			return nil, errCFBMalformed
+		}
+		if err := c.readSector(sec, buf); err != nil {
+			return nil, err
+		}
+		per := int(c.sectorSize/4) - 1
+		for i := 0; i < per && uint32(len(fatSectors)) < numFat; i++ {
+			fatSectors = append(fatSectors, binary.LittleEndian.Uint32(buf[4*i:]))
+		}
+		sec = binary.LittleEndian.Uint32(buf[c.sectorSize-4:])
+	}
+	c.fat = make([]uint32, 0, int64(numFat)*c.sectorSize/4)
+	for _, sec := range fatSectors {
+		if sec >= maxSectors {
+			return nil, errCFBMalformed
+		}
+		if err := c.readSector(sec, buf); err != nil {
+			return nil, err
+		}
+		for i := int64(0); i < c.sectorSize; i += 4 {
+			c.fat = append(c.fat, binary.LittleEndian.Uint32(buf[i:]))
+		}
+	}
+
+	dir, err := c.readChain(firstDir, -1)
+	if err != nil {
+		return nil, err
+	}
+	for off := 0; off+128 <= len(dir); off += 128 {
+		d := dir[off : off+128]
+		nameLen := int(binary.LittleEndian.Uint16(d[0x40:]))
+		if nameLen > 64 {
+			nameLen = 64
+		}
+		u := make([]uint16, 0, 32)
+		for i := 0; i+1 < nameLen; i += 2 {
+			if v := binary.LittleEndian.Uint16(d[i:]); v != 0 {
+				u = append(u, v)
+			}
+		}
+		size := int64(binary.LittleEndian.Uint64(d[0x78:]))
+		if shift == 9 {
+			size &= 0xFFFFFFFF // v3 文件只有低 32 位有效
+		}
+		c.entries = append(c.entries, cfbEntry{
+			Name:  string(utf16.Decode(u)),
+			typ:   d[0x42],
+			left:  binary.LittleEndian.Uint32(d[0x44:]),
+			right: binary.LittleEndian.Uint32(d[0x48:]),
+			child: binary.LittleEndian.Uint32(d[0x4C:]),
+			start: binary.LittleEndian.Uint32(d[0x74:]),
+			Size:  size,
+		})
+	}
+	if len(c.entries) == 0 || c.entries[0].typ != cfbTypeRoot {
+		return nil, errCFBMalformed
+	}
+	if firstMiniFat != cfbEndOfChain && firstMiniFat != cfbFreeSect {
+		mf, err := c.readChain(firstMiniFat, -1)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i+4 <= len(mf); i += 4 {
+			c.miniFat = append(c.miniFat, binary.LittleEndian.Uint32(mf[i:]))
+		}
+	}
+	return c, nil
+}
+
+func (c *cfbFile) readSector(sec uint32, buf []byte) error {
+	_, err := c.r.ReadAt(buf, (int64(sec)+1)*c.sectorSize)
+	if errors.Is(err, io.EOF) {
+		return errCFBMalformed
+	}
+	return err
+}
+
+// readChain 读取从 start 开始的普通扇区链；size>=0 时截断到 size 字节。
+func (c *cfbFile) readChain(start uint32, size int64) ([]byte, error) {
+	var out []byte
+	if size >= 0 {
+		out = make([]byte, 0, size)
+	}
+	buf := make([]byte, c.sectorSize)
+	for sec, n := start, 0; sec != cfbEndOfChain; n++ {
+		if int(sec) >= len(c.fat) || n > len(c.fat) {
+			return nil, errCFBMalformed
+		}
+		if err := c.readSector(sec, buf); err != nil {
+			return nil, err
+		}
+		out = append(out, buf...)
+		if size >= 0 && int64(len(out)) >= size {
+			return out[:size], nil
+		}
+		sec = c.fat[sec]
+	}
+	if size >= 0 && int64(len(out)) < size {
+		return nil, errCFBMalformed
+	}
+	return out, nil
+}
+
+func (c *cfbFile) readMiniChain(start uint32, size int64) ([]byte, error) {
+	if c.miniStream == nil {
+		root := c.entries[0]
+		ms, err := c.readChain(root.start, root.Size)
+		if err != nil {
+			return nil, err
+		}
+		c.miniStream = ms
+	}
+	const miniSize = 64
+	out := make([]byte, 0, size)
+	for sec, n := start, 0; int64(len(out)) < size; n++ {
+		if sec == cfbEndOfChain || int(sec) >= len(c.miniFat) || n > len(c.miniFat) {
+			return nil, errCFBMalformed
+		}
+		off := int64(sec) * miniSize
+		if off+miniSize > int64(len(c.miniStream)) {
+			return nil, errCFBMalformed
+		}
+		out = append(out, c.miniStream[off:off+miniSize]...)
+		sec = c.miniFat[sec]
+	}
+	return out[:size], nil
+}
+
+// children 返回 storage（目录项下标 idx）的直接子项下标（遍历其红黑树）。
+func (c *cfbFile) children(idx int) []int {
+	var out []int
+	stack := []uint32{c.entries[idx].child}
+	seen := map[uint32]bool{}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if i == cfbNoStream || int(i) >= len(c.entries) || seen[i] {
+			continue
+		}
+		seen[i] = true
+		out = append(out, int(i))
+		stack = append(stack, c.entries[i].left, c.entries[i].right)
+	}
+	return out
+}
+
+// find 按路径（不区分大小写）查找目录项，如 find("VBA", "dir")；找不到返回 -1。
+func (c *cfbFile) find(path ...string) int {
+	cur := 0
+	for _, name := range path {
+		next := -1
+		for _, i := range c.children(cur) {
+			if strings.EqualFold(c.entries[i].Name, name) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return -1
+		}
+		cur = next
+	}
+	return cur
+}
+
+// readStream 读取目录项 idx 的完整内容；超过 max 字节时返回 ErrTooLarge。
+func (c *cfbFile) readStream(idx int, max int64) ([]byte, error) {
+	if idx < 0 || idx >= len(c.entries) || c.entries[idx].typ != cfbTypeStream {
+		return nil, fmt.Errorf("%w: 不是流", errCFBMalformed)
+	}
+	e := c.entries[idx]
+	if e.Size > max || e.Size > c.size {
+		return nil, ErrTooLarge
+	}
+	if e.Size < c.miniCutoff {
+		return c.readMiniChain(e.start, e.Size)
+	}
+	return c.readChain(e.start, e.Size)
+}
@@ -0,0 +1,209 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// buildCFB 生成一个 v3（512 字节扇区）复合文档，files 的键为路径（如 "VBA/dir"），
+// 小于 4096 字节的流放在 mini stream 中。仅供测试使用：兄弟节点以右链相连。
+func buildCFB(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	const sec = 512
+
+	type node struct {
+		name     string
+		typ      byte
+		data     []byte
+		children []int
+		start    uint32
+		right    uint32
+	}
+	nodes := []*node{{name: "Root Entry", typ: cfbTypeRoot}}
+	storages := map[string]int{"": 0}
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		parts := strings.Split(p, "/")
+		parent := 0
+		for i := range parts[:len(parts)-1] {
+			key := strings.Join(parts[:i+1], "/")
+			idx, ok := storages[key]
+			if !ok {
+				idx = len(nodes)
+				nodes = append(nodes, &node{name: parts[i], typ: cfbTypeStorage})
+				nodes[parent].children = append(nodes[parent].children, idx)
+				storages[key] = idx
+			}
+			parent = idx
+		}
+		nodes = append(nodes, &node{name: parts[len(parts)-1], typ: cfbTypeStream, data: files[p]})
+		nodes[parent].children = append(nodes[parent].children, len(nodes)-1)
+	}
+
+	var body []byte // 扇区 0 起的内容
+	var fat []uint32
+	chain := func(data []byte) uint32 {
+		if len(data) == 0 {
+			return cfbEndOfChain
+		}
+		start := uint32(len(fat))
+		n := (len(data) + sec - 1) / sec
+		for i := 0; i < n; i++ {
+			if i == n-1 {
+				fat = append(fat, cfbEndOfChain)
+			} else {
+				fat = append(fat, uint32(len(fat)+1))
+			}
+		}
+		padded := make([]byte, n*sec)
+		copy(padded, data)
+		body = append(body, padded...)
+		return start
+	}
+
+	var mini []byte
+	var miniFat []uint32
+	for _, n := range nodes {
+		if n.typ != cfbTypeStream {
+			continue
+		}
+		if len(n.data) >= 4096 {
+			n.start = chain(n.data)
+			continue
+		}
+		if len(n.data) == 0 {
+			n.start = cfbEndOfChain
+			continue
+		}
+		n.start = uint32(len(miniFat))
+		cnt := (len(n.data) + 63) / 64
+		for i := 0; i < cnt; i++ {
+			if i == cnt-1 {
+				miniFat = append(miniFat, cfbEndOfChain)
+			} else {
+				miniFat = append(miniFat, uint32(len(miniFat)+1))
+			}
+		}
+		padded := make([]byte, cnt*64)
+		copy(padded, n.data)
+		mini = append(mini, padded...)
+	}
+	nodes[0].start = chain(mini)
+	nodes[0].data = mini
+	mfBytes := make([]byte, 4*len(miniFat))
+	for i, v := range miniFat {
+		binary.LittleEndian.PutUint32(mfBytes[4*i:], v)
+	}
+	firstMiniFat := chain(mfBytes)
+
+	for _, n := range nodes {
+		for i, c := range n.children {
+			nodes[c].right = cfbNoStream
+			if i+1 < len(n.children) {
+				nodes[c].right = uint32(n.children[i+1])
+			}
+		}
+	}
+	dir := make([]byte, 128*len(nodes))
+	for i, n := range nodes {
+		d := dir[128*i:]
+		u := utf16.Encode([]rune(n.name))
+		for j, v := range u {
+			binary.LittleEndian.PutUint16(d[2*j:], v)
+		}
+		binary.LittleEndian.PutUint16(d[0x40:], uint16(2*len(u)+2))
+		d[0x42] = n.typ
+		d[0x43] = 1
+		binary.LittleEndian.PutUint32(d[0x44:], cfbNoStream)
+		binary.LittleEndian.PutUint32(d[0x48:], n.right)
+		child := uint32(cfbNoStream)
+		if len(n.children) > 0 {
+			child = uint32(n.children[0])
+		}
+		binary.LittleEndian.PutUint32(d[0x4C:], child)
+		binary.LittleEndian.PutUint32(d[0x74:], n.start)
+		binary.LittleEndian.PutUint64(d[0x78:], uint64(len(n.data)))
+	}
+	firstDir := chain(dir)
+
+	// FAT 扇区放在最后；需要的扇区数随自身增加，迭代到稳定。
+	numFat := 1
+	for (len(fat)+numFat)*4 > numFat*sec {
+		numFat++
+	}
+	fatStart := uint32(len(fat))
+	for i := 0; i < numFat; i++ {
+		fat = append(fat, 0xFFFFFFFD)
+	}
+	for len(fat)%(sec/4) != 0 {
+		fat = append(fat, cfbFreeSect)
+	}
+	for _, v := range fat {
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], v)
+		body = append(body, b[:]...)
+	}
+
+	hdr := make([]byte, sec)
+	copy(hdr, cfbSignature)
+	binary.LittleEndian.PutUint16(hdr[0x18:], 0x3E)
+	binary.LittleEndian.PutUint16(hdr[0x1A:], 3)
+	binary.LittleEndian.PutUint16(hdr[0x1C:], 0xFFFE)
+	binary.LittleEndian.PutUint16(hdr[0x1E:], 9)
+	binary.LittleEndian.PutUint16(hdr[0x20:], 6)
+	binary.LittleEndian.PutUint32(hdr[0x2C:], uint32(numFat))
+	binary.LittleEndian.PutUint32(hdr[0x30:], firstDir)
+	binary.LittleEndian.PutUint32(hdr[0x38:], 4096)
+	binary.LittleEndian.PutUint32(hdr[0x3C:], firstMiniFat)
+	binary.LittleEndian.PutUint32(hdr[0x40:], uint32(len(miniFat)))
+	binary.LittleEndian.PutUint32(hdr[0x44:], cfbEndOfChain)
+	for i := 0; i < 109; i++ {
+		v := uint32(cfbFreeSect)
+		if i < numFat {
+			v = fatStart + uint32(i)
+		}
+		binary.LittleEndian.PutUint32(hdr[0x4C+4*i:], v)
+	}
+	return append(hdr, body...)
+}
+
+func TestCFB_ReadStreams(t *testing.T) {
+	big := bytes.Repeat([]byte("0123456789"), 1000)
+	data := buildCFB(t, map[string][]byte{
+		"small":       []byte("hello mini stream"),
+		"Big":         big,
+		"VBA/dir":     []byte("nested"),
+		"VBA/Module1": bytes.Repeat([]byte{'x'}, 100),
+	})
+	c, err := openCFB(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string][]byte{
+		"small":       []byte("hello mini stream"),
+		"big":         big,
+		"vba/DIR":     []byte("nested"),
+		"VBA/Module1": bytes.Repeat([]byte{'x'}, 100),
+	}
+	for p, want := range cases {
+		idx := c.find(strings.Split(p, "/")...)
+		got, err := c.readStream(idx, 1<<20)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("%s: len=%d err=%v", p, len(got), err)
+		}
+	}
+	if c.find("missing") >= 0 {
+		t.Fatal("found missing stream")
+	}
+	if _, err := c.readStream(c.find("Big"), 10); err != ErrTooLarge {
+		t.Fatalf("err = %v, want ErrTooLarge", err)
+	}
+}
@@ -0,0 +1,465 @@
+package extract
+
+import (
+	"embed"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 旧版 Windows 工具常把 txt/csv/ini 存成 GBK、Big5 或 Shift-JIS，这里内置解码表（见
+// scripts/gen_charset_tables.py）并做统计式编码探测，不依赖联网获取的映射表。
+
+//go:embed charset_tables
+var charsetTables embed.FS
+
+type charset int
+
+const (
+	charsetUnknown charset = iota
+	charsetUTF8
+	charsetUTF16LE
+	charsetUTF16BE
+	charsetGB18030
+	charsetBig5
+	charsetShiftJIS
+)
+
+func (c charset) String() string {
+	switch c {
+	case charsetUTF8:
+		return "utf-8"
+	case charsetUTF16LE:
+		return "utf-16le"
+	case charsetUTF16BE:
+		return "utf-16be"
+	case charsetGB18030:
+		return "gb18030"
+	case charsetBig5:
+		return "big5"
+	case charsetShiftJIS:
+		return "shift_jis"
+	default:
+		return "unknown"
+	}
+}
+
+// parseCharset 解析用户配置的编码名；"auto"/空串返回 charsetUnknown（即自动探测）。
+func parseCharset(name string) (charset, bool) {
+	n := strings.ToLower(strings.TrimSpace(name))
+	n = strings.ReplaceAll(n, "_", "-")
+	switch n {
+	case "", "auto":
+		return charsetUnknown, true
+	case "utf-8", "utf8":
+		return charsetUTF8, true
+	case "utf-16", "utf-16le", "utf16le", "utf16", "unicode":
+		return charsetUTF16LE, true
+	case "utf-16be", "utf16be":
+		return charsetUTF16BE, true
+	case "gbk", "gb2312", "gb18030", "cp936", "936", "euc-cn":
+		return charsetGB18030, true
+	case "big5", "cp950", "950", "big5-hkscs":
+		return charsetBig5, true
+	case "shift-jis", "sjis", "cp932", "932", "ms932", "windows-31j":
+		return charsetShiftJIS, true
+	default:
+		return charsetUnknown, false
+	}
+}
+
+// 双字节表布局：lead 0x81..0xFE × trail 0x40..0xFE，值为码位，0 表示未定义。
+const (
+	dbcsLeadMin  = 0x81
+	dbcsLeadMax  = 0xFE
+	dbcsTrailMin = 0x40
+	dbcsTrailMax = 0xFE
+	dbcsRowLen   = dbcsTrailMax - dbcsTrailMin + 1
+)
+
+type dbcsTable []uint16
+
+func (t dbcsTable) lookup(lead, trail byte) rune {
+	if lead < dbcsLeadMin || lead > dbcsLeadMax || trail < dbcsTrailMin || trail > dbcsTrailMax {
+		return 0
+	}
+	i := int(lead-dbcsLeadMin)*dbcsRowLen + int(trail-dbcsTrailMin)
+	if i >= len(t) {
+		return 0
+	}
+	return rune(t[i])
+}
+
+type gbRange struct {
+	index uint32
+	cp    uint32
+}
+
+var (
+	charsetTablesOnce sync.Once
+	gb18030Table      dbcsTable
+	big5Table         dbcsTable
+	sjisTable         dbcsTable
+	gb18030Ranges     []gbRange
+)
+
+func loadCharsetTables() {
+	charsetTablesOnce.Do(func() {
+		gb18030Table = readDBCSTable("gb18030.bin")
+		big5Table = readDBCSTable("big5.bin")
+		sjisTable = readDBCSTable("sjis.bin")
+		b, err := charsetTables.ReadFile("charset_tables/gb18030_ranges.bin")
+		if err != nil {
+			return
+		}
+		gb18030Ranges = make([]gbRange, 0, len(b)/8)
+		for i := 0; i+8 <= len(b); i += 8 {
+			gb18030Ranges = append(gb18030Ranges, gbRange{
+				index: binary.BigEndian.Uint32(b[i:]),
+				cp:    binary.BigEndian.Uint32(b[i+4:]),
+			})
+		}
+	})
+}
+
+func readDBCSTable(name string) dbcsTable {
+	b, err := charsetTables.ReadFile("charset_tables/" + name)
+	if err != nil {
+		return nil
+	}
+	t := make(dbcsTable, len(b)/2)
+	for i := range t {
+		t[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return t
+}
+
+const (
+	gb18030BMPMaxIndex = 39419  // 0x8431A439
+	gb18030SuppBase    = 189000 // 0x90308130 的线性序号
+)
+
+func gb18030FourByte(b0, b1, b2, b3 byte) rune {
+	idx := ((uint32(b0-0x81)*10+uint32(b1-0x30))*126+uint32(b2-0x81))*10 + uint32(b3-0x30)
+	if idx <= gb18030BMPMaxIndex {
+		i := sort.Search(len(gb18030Ranges), func(i int) bool { return gb18030Ranges[i].index > idx }) - 1
+		if i < 0 {
+			return utf8.RuneError
+		}
+		return rune(gb18030Ranges[i].cp + (idx - gb18030Ranges[i].index))
+	}
+	if idx >= gb18030SuppBase && idx-gb18030SuppBase <= 0x10FFFF-0x10000 {
+		return rune(0x10000 + idx - gb18030SuppBase)
+	}
+	return utf8.RuneError
+}
+
+// 解码单个字符时的分类，供统计探测打分。
+const (
+	mbcsASCII = iota
+	mbcsCommon
+	mbcsRare
+	mbcsInvalid
+)
+
+// nextMBCSRune 解码 b 开头的一个字符。
+// size==0 表示 b 末尾是不完整的多字节序列（需要更多输入）。
+func nextMBCSRune(cs charset, b []byte) (r rune, size int, class int) {
+	c := b[0]
+	if c < 0x80 {
+		return rune(c), 1, mbcsASCII
+	}
+	switch cs {
+	case charsetGB18030:
+		if c == 0x80 || c == 0xFF {
+			return utf8.RuneError, 1, mbcsInvalid
+		}
+		if len(b) < 2 {
+			return 0, 0, mbcsInvalid
+		}
+		c1 := b[1]
+		if c1 >= 0x30 && c1 <= 0x39 {
+			if len(b) < 4 {
+				return 0, 0, mbcsInvalid
+			}
+			c2, c3 := b[2], b[3]
+			if c2 < 0x81 || c2 > 0xFE || c3 < 0x30 || c3 > 0x39 {
+				return utf8.RuneError, 1, mbcsInvalid
+			}
+			r := gb18030FourByte(c, c1, c2, c3)
+			if r == utf8.RuneError {
+				return r, 4, mbcsInvalid
+			}
+			return r, 4, mbcsRare
+		}
+		r := gb18030Table.lookup(c, c1)
+		if r == 0 || c1 == 0x7F {
+			return utf8.RuneError, 1, mbcsInvalid
+		}
+		// GB2312 一级汉字与常用标点：lead 0xA1..0xA3 / 0xB0..0xD7，trail >= 0xA1。
+		if c1 >= 0xA1 && ((c >= 0xB0 && c <= 0xD7) || (c >= 0xA1 && c <= 0xA3)) {
+			return r, 2, mbcsCommon
+		}
+		return r, 2, mbcsRare
+	case charsetBig5:
+		if c == 0x80 || c == 0xFF {
+			return utf8.RuneError, 1, mbcsInvalid
+		}
+		if len(b) < 2 {
+			return 0, 0, mbcsInvalid
+		}
+		c1 := b[1]
+		r := big5Table.lookup(c, c1)
+		if r == 0 {
+			return utf8.RuneError, 1, mbcsInvalid
+		}
+		// Big5 常用字 0xA440..0xC67E 与符号区 0xA140..0xA3BF。
+		if c >= 0xA1 && c <= 0xC6 {
+			return r, 2, mbcsCommon
+		}
+		return r, 2, mbcsRare
+	case charsetShiftJIS:
+		if c >= 0xA1 && c <= 0xDF {
+			// 半角片假名：合法但在正文中少见。
+			return rune(0xFF61 + int(c-0xA1)), 1, mbcsRare
+		}
+		if c == 0x80 || c == 0xA0 || c >= 0xFD {
+			return utf8.RuneError, 1, mbcsInvalid
+		}
+		if len(b) < 2 {
+			return 0, 0, mbcsInvalid
+		}
+		c1 := b[1]
+		r := sjisTable.lookup(c, c1)
+		if r == 0 {
+			return utf8.RuneError, 1, mbcsInvalid
+		}
+		// 全角符号/假名（0x81..0x83）与 JIS 第一水准汉字（0x88..0x9F）。
+		if (c >= 0x81 && c <= 0x83) || (c >= 0x88 && c <= 0x9F) {
+			return r, 2, mbcsCommon
+		}
+		return r, 2, mbcsRare
+	}
+	return utf8.RuneError, 1, mbcsInvalid
+}
+
+// decodeMBCS 将 b 按双字节编码解码为 UTF-8。
+// atEOF=false 时，末尾不完整的序列原样作为 rest 返回，供下一个 chunk 拼接。
+func decodeMBCS(cs charset, b []byte, atEOF bool) (string, []byte) {
+	loadCharsetTables()
+	var sb strings.Builder
+	sb.Grow(len(b) + len(b)/2)
+	i := 0
+	for i < len(b) {
+		r, size, _ := nextMBCSRune(cs, b[i:])
+		if size == 0 {
+			if !atEOF {
+				return sb.String(), b[i:]
+			}
+			sb.WriteRune(utf8.RuneError)
+			break
+		}
+		sb.WriteRune(r)
+		i += size
+	}
+	return sb.String(), nil
+}
+
+// detectCharset 依据文件开头的样本判断编码：BOM > 无 BOM 的 UTF-16 > 合法 UTF-8 > 双字节编码统计打分。
+// 无法确定时返回 charsetUnknown，调用方按 UTF-8 尽力解码。
+func detectCharset(sample []byte) charset {
+	if len(sample) >= 3 && sample[0] == 0xEF && sample[1] == 0xBB && sample[2] == 0xBF {
+		return charsetUTF8
+	}
+	if len(sample) >= 2 && sample[0] == 0xFF && sample[1] == 0xFE {
+		return charsetUTF16LE
+	}
+	if len(sample) >= 2 && sample[0] == 0xFE && sample[1] == 0xFF {
+		return charsetUTF16BE
+	}
+	// 无 BOM 的 UTF-16（Windows 导出的日志、.reg、.csv 很常见）含大量 0x00，
+	// 而 0x00 本身是合法 UTF-8，因此必须先于 UTF-8 校验判断。
+	if cs := detectBOMLessUTF16(sample); cs != charsetUnknown {
+		return cs
+	}
+	if validUTF8Prefix(sample) {
+		return charsetUTF8
+	}
+
+	loadCharsetTables()
+	best := charsetUnknown
+	bestScore := 0.0
+	for _, cs := range []charset{charsetGB18030, charsetBig5, charsetShiftJIS} {
+		s := scoreMBCS(cs, sample)
+		if s > bestScore {
+			best, bestScore = cs, s
+		}
+	}
+	// 得分过低说明样本更像二进制或其它编码，不冒险套用。
+	if bestScore < 0.3 {
+		return charsetUnknown
+	}
+	return best
+}
+
+// detectBOMLessUTF16 依据 0x00 字节的奇偶位置分布判断无 BOM 的 UTF-16LE/BE。
+// 以 ASCII 为主的 UTF-16LE 文本高字节几乎全为 0；以 CJK 为主的文本 0x00 很少，
+// 则退而检查按 UTF-16 解码后的换行符（CR/LF 码元）是否出现在对齐位置。
+func detectBOMLessUTF16(sample []byte) charset {
+	units := len(sample) / 2
+	if units < 4 {
+		return charsetUnknown
+	}
+	var zeroEven, zeroOdd, nlLE, nlBE int
+	for i := 0; i+1 < len(sample); i += 2 {
+		lo, hi := sample[i], sample[i+1]
+		if lo == 0 {
+			zeroEven++
+		}
+		if hi == 0 {
+			zeroOdd++
+		}
+		if hi == 0 && (lo == '\n' || lo == '\r') {
+			nlLE++
+		}
+		if lo == 0 && (hi == '\n' || hi == '\r') {
+			nlBE++
+		}
+	}
+	const (
+		minRatio = 0.3
+		maxNoise = 0.05
+	)
+	switch {
+	case float64(zeroOdd) >= minRatio*float64(units) && float64(zeroEven) <= maxNoise*float64(units):
+		return charsetUTF16LE
+	case float64(zeroEven) >= minRatio*float64(units) && float64(zeroOdd) <= maxNoise*float64(units):
+		return charsetUTF16BE
+	}
+	// 以 CJK 为主的文本 0x00 很少（只有换行、数字等 ASCII 码元），要求换行码元出现在 LE 对齐位置、
+	// 偶数位没有 0x00，且按 UTF-16LE 解码后代理对配对、控制字符极少。
+	if zeroEven == 0 && nlLE > 0 && nlBE == 0 && utf16LooksValid(sample, true) {
+		return charsetUTF16LE
+	}
+	return charsetUnknown
+}
+
+// utf16LooksValid 检查样本按 UTF-16 解码时代理对是否配对、且不含过多控制字符。
+func utf16LooksValid(b []byte, littleEndian bool) bool {
+	var bad, total int
+	pendingHigh := false
+	for i := 0; i+1 < len(b); i += 2 {
+		var v uint16
+		if littleEndian {
+			v = uint16(b[i]) | uint16(b[i+1])<<8
+		} else {
+			v = uint16(b[i+1]) | uint16(b[i])<<8
+		}
+		total++
+		switch {
+		case v >= 0xD800 && v <= 0xDBFF:
+			if pendingHigh {
+				bad++
+			}
+			pendingHigh = true
+			continue
+		case v >= 0xDC00 && v <= 0xDFFF:
+			if !pendingHigh {
+				bad++
+			}
+		case v < 0x20 && v != '\t' && v != '\n' && v != '\r':
+			bad++
+		}
+		pendingHigh = false
+	}
+	return total > 0 && bad*20 <= total
+}
+
+// validUTF8Prefix 判断样本是否为合法 UTF-8；样本可能在多字节字符中间截断，末尾不完整序列不计为错误。
+func validUTF8Prefix(b []byte) bool {
+	valid, _ := cutPartialUTF8(b)
+	return utf8.Valid(valid)
+}
+
+// scoreMBCS 返回 [-3, 1] 区间的得分：常用字 +1，少见字 0，非法序列 -3，按非 ASCII 字符数归一。
+func scoreMBCS(cs charset, b []byte) float64 {
+	var total, sum int
+	for i := 0; i < len(b); {
+		_, size, class := nextMBCSRune(cs, b[i:])
+		if size == 0 {
+			break
+		}
+		i += size
+		switch class {
+		case mbcsASCII:
+			continue
+		case mbcsCommon:
+			sum++
+		case mbcsInvalid:
+			sum -= 3
+		}
+		total++
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(sum) / float64(total)
+}
+
+// textEncodingOverride 返回用户为该文件强制指定的编码。
+//
+// OFIND_TEXT_ENCODING_ROOTS 按根目录覆盖（最长前缀优先），如 `D:\Old=gbk;E:\JP=shift_jis`；
+// OFIND_TEXT_ENCODING 为全局默认（auto/gbk/big5/shift_jis/utf-8/utf-16le ...）。
+func textEncodingOverride(path string) (charset, bool) {
+	if name, ok := rootScopedEnv("OFIND_TEXT_ENCODING_ROOTS", path); ok {
+		cs, ok := parseCharset(name)
+		return cs, ok && cs != charsetUnknown
+	}
+	cs, ok := parseCharset(os.Getenv("OFIND_TEXT_ENCODING"))
+	return cs, ok && cs != charsetUnknown
+}
+
+// rootScopedEnv 解析形如 `D:\Old=gbk;E:\JP=shift_jis` 的按根目录配置，返回 path 所在最长根目录的值。
+func rootScopedEnv(key string, path string) (string, bool) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return "", false
+	}
+	best := -1
+	bestVal := ""
+	for _, part := range strings.Split(v, ";") {
+		root, val, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		root = strings.TrimSpace(root)
+		if root == "" || !pathHasPrefix(path, root) {
+			continue
+		}
+		if len(root) > best {
+			best, bestVal = len(root), strings.TrimSpace(val)
+		}
+	}
+	return bestVal, best >= 0
+}
+
+// pathHasPrefix 判断 path 是否位于 root 之下（Windows 下不区分大小写）。
+func pathHasPrefix(path, root string) bool {
+	path = filepath.Clean(path)
+	root = filepath.Clean(root)
+	if filepath.Separator == '\\' {
+		path = strings.ToLower(path)
+		root = strings.ToLower(root)
+	}
+	if path == root {
+		return true
+	}
+	if !strings.HasSuffix(root, string(filepath.Separator)) {
+		root += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, root)
+}
@@ -0,0 +1,155 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+)
+
+var (
+	// "这是一个用于测试编码探测的中文文本文件，包含合同编号和客户名称。"（GBK）
+	sampleGBK = []byte("\xd5\xe2\xca\xc7\xd2\xbb\xb8\xf6\xd3\xc3\xd3\xda\xb2\xe2\xca\xd4\xb1\xe0\xc2\xeb\xcc\xbd\xb2\xe2\xb5\xc4\xd6\xd0\xce\xc4\xce\xc4\xb1\xbe\xce\xc4\xbc\xfe\xa3\xac\xb0\xfc\xba\xac\xba\xcf\xcd\xac\xb1\xe0\xba\xc5\xba\xcd\xbf\xcd\xbb\xa7\xc3\xfb\xb3\xc6\xa1\xa3")
+	// "這是一個用於測試編碼偵測的繁體中文文字檔，包含合約編號與客戶名稱。"（Big5）
+	sampleBig5 = []byte("\xb3\x6f\xac\x4f\xa4\x40\xad\xd3\xa5\xce\xa9\xf3\xb4\xfa\xb8\xd5\xbd\x73\xbd\x58\xb0\xbb\xb4\xfa\xaa\xba\xc1\x63\xc5\xe9\xa4\xa4\xa4\xe5\xa4\xe5\xa6\x72\xc0\xc9\xa1\x41\xa5\x5d\xa7\x74\xa6\x58\xac\xf9\xbd\x73\xb8\xb9\xbb\x50\xab\xc8\xa4\xe1\xa6\x57\xba\xd9\xa1\x43")
+	// "これは文字コード判定のテスト用の日本語テキストです。契約番号と顧客名を含みます。"（Shift-JIS）
+	sampleSJIS = []byte("\x82\xb1\x82\xea\x82\xcd\x95\xb6\x8e\x9a\x83\x52\x81\x5b\x83\x68\x94\xbb\x92\xe8\x82\xcc\x83\x65\x83\x58\x83\x67\x97\x70\x82\xcc\x93\xfa\x96\x7b\x8c\xea\x83\x65\x83\x4c\x83\x58\x83\x67\x82\xc5\x82\xb7\x81\x42\x8c\x5f\x96\xf1\x94\xd4\x8d\x86\x82\xc6\x8c\xda\x8b\x71\x96\xbc\x82\xf0\x8a\xdc\x82\xdd\x82\xdc\x82\xb7\x81\x42")
+)
+
+func TestDetectCharset(t *testing.T) {
+	cases := []struct {
+		name string
+		in   []byte
+		want charset
+	}{
+		{"ascii", []byte("hello, world"), charsetUTF8},
+		{"utf8", []byte("合同编号：A-001"), charsetUTF8},
+		{"gbk", sampleGBK, charsetGB18030},
+		{"big5", sampleBig5, charsetBig5},
+		{"sjis", sampleSJIS, charsetShiftJIS},
+		{"gbk-mixed", append([]byte("id=42,name="), sampleGBK...), charsetGB18030},
+	}
+	for _, c := range cases {
+		if got := detectCharset(c.in); got != c.want {
+			t.Errorf("%s: detectCharset = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDecodeMBCS(t *testing.T) {
+	cases := []struct {
+		cs   charset
+		in   []byte
+		want string
+	}{
+		{charsetGB18030, sampleGBK, "这是一个用于测试编码探测的中文文本文件，包含合同编号和客户名称。"},
+		{charsetBig5, sampleBig5, "這是一個用於測試編碼偵測的繁體中文文字檔，包含合約編號與客戶名稱。"},
+		{charsetShiftJIS, sampleSJIS, "これは文字コード判定のテスト用の日本語テキストです。契約番号と顧客名を含みます。"},
+		// GB18030 四字节：U+0080、U+FFFF、U+10000
+		{charsetGB18030, []byte("\x81\x30\x81\x30\x84\x31\xa4\x39\x90\x30\x81\x30"), "\u0080￿\U00010000"},
+	}
+	for _, c := range cases {
+		got, rest := decodeMBCS(c.cs, c.in, true)
+		if got != c.want || len(rest) != 0 {
+			t.Errorf("%v: decodeMBCS = %q (rest %d), want %q", c.cs, got, len(rest), c.want)
+		}
+	}
+}
+
+func TestTextChunkReader_SplitsMultiByteSafely(t *testing.T) {
+	data := bytes.Repeat(sampleGBK, 50)
+	// OneByteReader 迫使每个双字节字符都跨越读边界。
+	next := textChunkReader(iotest.OneByteReader(bytes.NewReader(data)), charsetGB18030)
+	var sb strings.Builder
+	for {
+		s, err := next(context.Background())
+		sb.WriteString(s)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := strings.Repeat("这是一个用于测试编码探测的中文文本文件，包含合同编号和客户名称。", 50)
+	if sb.String() != want {
+		t.Fatalf("decoded text mismatch")
+	}
+}
+
+func TestTextEncodingOverride_PerRoot(t *testing.T) {
+	t.Setenv("OFIND_TEXT_ENCODING", "")
+	t.Setenv("OFIND_TEXT_ENCODING_ROOTS", "/data=big5;/data/jp=sjis")
+	if cs, ok := textEncodingOverride("/data/jp/a.txt"); !ok || cs != charsetShiftJIS {
+		t.Fatalf("longest root should win, got %v %v", cs, ok)
+	}
+	if cs, ok := textEncodingOverride("/data/tw/a.txt"); !ok || cs != charsetBig5 {
+		t.Fatalf("got %v %v", cs, ok)
+	}
+	if _, ok := textEncodingOverride("/other/a.txt"); ok {
+		t.Fatalf("unexpected override outside roots")
+	}
+}
+
+func encodeUTF16LE(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	return b
+}
+
+func TestDetectCharset_BOMLessUTF16LE(t *testing.T) {
+	if got := detectCharset(encodeUTF16LE("Windows Registry Editor Version 5.00\r\n\r\n[HKEY_CURRENT_USER]\r\n")); got != charsetUTF16LE {
+		t.Fatalf("ascii utf-16le: got %v", got)
+	}
+	if got := detectCharset(encodeUTF16LE("合同编号甲乙双方\r\n客户名称联系地址\r\n")); got != charsetUTF16LE {
+		t.Fatalf("cjk utf-16le: got %v", got)
+	}
+}
+
+func TestTextChunkReader_UTF16SurrogateAcrossReads(t *testing.T) {
+	want := strings.Repeat("日志𠀀line\r\n", 100)
+	data := append([]byte{0xFF, 0xFE}, encodeUTF16LE(want)...)
+	next := textChunkReader(iotest.OneByteReader(bytes.NewReader(data)), charsetUnknown)
+	var sb strings.Builder
+	for {
+		s, err := next(context.Background())
+		sb.WriteString(s)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sb.String() != want {
+		t.Fatalf("decoded text mismatch: %q", sb.String()[:40])
+	}
+}
+
+func TestTextFileFindFirst_UTF16BeyondOldCap(t *testing.T) {
+	// 匹配位于 11MiB 之后：旧实现对 UTF-16 只读前 10MiB。
+	var buf bytes.Buffer
+	filler := encodeUTF16LE(strings.Repeat("x", 1023) + "\n")
+	for buf.Len() < 11*1024*1024 {
+		buf.Write(filler)
+	}
+	buf.Write(encodeUTF16LE("合同编号：A-001\r\n"))
+	path := filepath.Join(t.TempDir(), "big.log")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	found, snip, err := textFileFindFirst(context.Background(), path, "A-001", 2)
+	if err != nil || !found {
+		t.Fatalf("found=%v err=%v", found, err)
+	}
+	if snip != "号：【A-001】\r\n" {
+		t.Fatalf("unexpected snippet %q", snip)
+	}
+}
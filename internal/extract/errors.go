@@ -0,0 +1,129 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+)
+
+// 提取错误分类：各后端返回的错误都包装（%w）下列哨兵错误之一（另有 passwords.go 的 ErrEncrypted、
+// timeout.go 的 ErrTimeout、zipguard.go 的 ErrRefused），调用方用 errors.Is 或 StatusOf 区分
+// “没有命中”与“无法读取”。
+var (
+	ErrUnsupported    = errors.New("不支持或无法识别的文件格式")
+	ErrTooLarge       = errors.New("文件或提取内容超过上限")
+	ErrCorrupt        = errors.New("文件损坏或格式不正确")
+	ErrBackendMissing = errors.New("缺少可用的提取后端")
+	// ErrCrashed 表示提取进程在处理文件时异常退出，ErrOutOfMemory 表示提取进程超出内存上限被结束（见 internal/sandbox）
+	ErrCrashed     = errors.New("提取进程异常退出")
+	ErrOutOfMemory = errors.New("提取进程超出内存上限")
+	// ErrQuarantined 表示文件在隔离列表中，本次未尝试提取（由调用方而非提取器返回）
+	ErrQuarantined = errors.New("文件已隔离")
+)
+
+// Status 为单个文件的处理结果代码，用于结果展示与 daemon 协议。
+type Status string
+
+const (
+	StatusOK             Status = "ok"
+	StatusUnsupported    Status = "unsupported"
+	StatusTooLarge       Status = "too_large"
+	StatusEncrypted      Status = "encrypted"
+	StatusCorrupt        Status = "corrupt"
+	StatusBackendMissing Status = "backend_missing"
+	StatusTimeout        Status = "timeout"
+	StatusRefused        Status = "refused"
+	StatusAccessDenied   Status = "access_denied"
+	StatusNotFound       Status = "not_found"
+	StatusCanceled       Status = "canceled"
+	// StatusCrashed、StatusOutOfMemory 为沙箱提取子进程崩溃或超出内存上限（文件会被隔离）
+	StatusCrashed     Status = "crashed"
+	StatusOutOfMemory Status = "out_of_memory"
+	// StatusQuarantined 表示文件在隔离列表中（曾导致超时、内存超限或崩溃），本次未尝试提取
+	StatusQuarantined Status = "quarantined"
+	// StatusError 为未归类的错误
+	StatusError Status = "error"
+)
+
+// ErrorFromStatus 由 Status 与错误信息重建错误（如从提取子进程传回），包装对应的哨兵错误以便 errors.Is/StatusOf。
+func ErrorFromStatus(s Status, msg string) error {
+	var base error
+	switch s {
+	case StatusOK:
+		return nil
+	case StatusTimeout:
+		base = ErrTimeout
+	case StatusEncrypted:
+		base = ErrEncrypted
+	case StatusRefused:
+		base = ErrRefused
+	case StatusTooLarge:
+		base = ErrTooLarge
+	case StatusUnsupported:
+		base = ErrUnsupported
+	case StatusCorrupt:
+		base = ErrCorrupt
+	case StatusBackendMissing:
+		base = ErrBackendMissing
+	case StatusAccessDenied:
+		base = fs.ErrPermission
+	case StatusNotFound:
+		base = fs.ErrNotExist
+	case StatusCanceled:
+		base = context.Canceled
+	case StatusCrashed:
+		base = ErrCrashed
+	case StatusOutOfMemory:
+		base = ErrOutOfMemory
+	case StatusQuarantined:
+		base = ErrQuarantined
+	default:
+		return errors.New(msg)
+	}
+	return &statusError{base: base, msg: msg}
+}
+
+// statusError 保留原始信息，同时 Unwrap 到哨兵错误。
+type statusError struct {
+	base error
+	msg  string
+}
+
+func (e *statusError) Error() string { return e.msg }
+func (e *statusError) Unwrap() error { return e.base }
+
+// StatusOf 把提取错误归为 Status；err 为 nil 时返回 StatusOK。
+func StatusOf(err error) Status {
+	switch {
+	case err == nil:
+		return StatusOK
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return StatusTimeout
+	case errors.Is(err, ErrEncrypted):
+		return StatusEncrypted
+	case errors.Is(err, ErrRefused):
+		return StatusRefused
+	case errors.Is(err, ErrTooLarge):
+		return StatusTooLarge
+	case errors.Is(err, ErrUnsupported):
+		return StatusUnsupported
+	case errors.Is(err, ErrCorrupt):
+		return StatusCorrupt
+	case errors.Is(err, ErrBackendMissing):
+		return StatusBackendMissing
+	case errors.Is(err, fs.ErrPermission):
+		return StatusAccessDenied
+	case errors.Is(err, fs.ErrNotExist):
+		return StatusNotFound
+	case errors.Is(err, context.Canceled):
+		return StatusCanceled
+	case errors.Is(err, ErrCrashed):
+		return StatusCrashed
+	case errors.Is(err, ErrOutOfMemory):
+		return StatusOutOfMemory
+	case errors.Is(err, ErrQuarantined):
+		return StatusQuarantined
+	default:
+		return StatusError
+	}
+}
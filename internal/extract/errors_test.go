@@ -0,0 +1,44 @@
+package extract
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatusOf_Files(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	cases := map[string]Status{
+		write("broken.docx", []byte("PK\x03\x04 truncated zip")): StatusCorrupt,
+		write("broken.pdf", []byte("%PDF-1.4\nno xref here\n")):  StatusCorrupt,
+		write("data.xyz", []byte{0x00, 0x01, 0x02}):              StatusUnsupported,
+		filepath.Join(dir, "missing.txt"):                        StatusNotFound,
+		write("ok.txt", []byte("hello")):                         StatusOK,
+	}
+	t.Setenv("OFIND_SNIFF_UNKNOWN", "1")
+	t.Setenv("OFIND_PDFTOTEXT", "0")
+	t.Setenv("OFIND_PDF_BACKENDS", "purego")
+	for p, want := range cases {
+		_, _, err := FileFindFirst(context.Background(), p, "hello", 5)
+		if got := StatusOf(err); got != want {
+			t.Errorf("%s: status = %s (err=%v), want %s", filepath.Base(p), got, err, want)
+		}
+	}
+}
+
+func TestErrorFromStatus_RoundTrip(t *testing.T) {
+	for _, s := range []Status{StatusTimeout, StatusEncrypted, StatusRefused, StatusCorrupt, StatusCrashed, StatusOutOfMemory, StatusQuarantined} {
+		err := ErrorFromStatus(s, "详情")
+		if got := StatusOf(err); got != s || err.Error() != "详情" {
+			t.Errorf("%s: StatusOf = %s, msg = %q", s, got, err.Error())
+		}
+	}
+}
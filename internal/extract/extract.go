@@ -2,23 +2,15 @@ package extract
 
 import (
 	"context"
-	"path/filepath"
-	"strings"
 )
 
 func FileFindFirst(ctx context.Context, path string, query string, contextLen int) (found bool, snippet string, err error) {
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
-	case ".txt", ".md", ".log", ".csv", ".json", ".xml", ".ini", ".yaml", ".yml":
-		return textFileFindFirst(ctx, path, query, contextLen)
-	case ".docx", ".xlsx", ".pptx", ".vsdx":
-		return ooxmlFindFirst(ctx, path, query, contextLen)
-	case ".pdf":
-		return pdfFindFirst(ctx, path, query, contextLen)
-	default:
-		// .doc/.xls/.ppt/.pdf 等：在 Windows 下用 IFilter；非 Windows 则返回不支持
-		return ifilterFindFirst(ctx, path, query, contextLen)
-	}
+	return Default.FindFirst(ctx, path, query, contextLen)
+}
+
+// FileFindFirstAs 与 FileFindFirst 相同，但使用已由 FileFormat 解析出的格式，不再读文件头。
+func FileFindFirstAs(ctx context.Context, format string, path string, query string, contextLen int) (bool, string, error) {
+	return Default.FindFirstAs(ctx, format, path, query, contextLen)
 }
 
 func FileContains(ctx context.Context, path string, query string) (bool, error) {
@@ -27,15 +19,31 @@ func FileContains(ctx context.Context, path string, query string) (bool, error)
 }
 
 func FileFindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
-	case ".txt", ".md", ".log", ".csv", ".json", ".xml", ".ini", ".yaml", ".yml":
-		return textFileFindSnippets(ctx, path, query, contextLen, maxSnippets)
-	case ".docx", ".xlsx", ".pptx", ".vsdx":
-		return ooxmlFindSnippets(ctx, path, query, contextLen, maxSnippets)
-	case ".pdf":
-		return PDFFindSnippetsStream(ctx, path, query, contextLen, maxSnippets)
-	default:
-		return ifilterFindSnippets(ctx, path, query, contextLen, maxSnippets)
-	}
+	return Default.FindSnippets(ctx, path, query, contextLen, maxSnippets)
+}
+
+// FileStream 返回文件的流式正文；调用方负责 Close。
+func FileStream(ctx context.Context, path string) (TextStream, error) {
+	return Default.Stream(ctx, path)
+}
+
+// FileFormat 返回文件实际使用的格式名（如 "pdf"、"ooxml"，按扩展名与文件头判断），无可用格式时为空。
+func FileFormat(path string) string {
+	return Default.ResolveFormat(path)
+}
+
+// SniffFormat 与 FileFormat 相同，但未注册的扩展名也按文件头识别（不受 OFIND_SNIFF_UNKNOWN 影响），
+// 用于用户显式指定的扩展名（如 -ext out）。
+func SniffFormat(path string) string {
+	return Default.SniffFormat(path)
+}
+
+// ExtFormat 返回扩展名（小写、带点）注册的格式名（不读文件，供调度等只需粗略判断的场合）。
+func ExtFormat(ext string) string {
+	return Default.ExtFormat(ext)
+}
+
+// SupportedExt 判断遍历时是否应把该扩展名（小写、带点）交给提取器。
+func SupportedExt(ext string) bool {
+	return Default.SupportsExt(ext)
 }
@@ -1,26 +1,11 @@
 package extract
 
-import (
-	"context"
-	"errors"
-	"path/filepath"
-	"strings"
-)
+import "context"
 
 // FileExtractText extracts readable text from supported files.
 // maxBytes is a soft cap; implementations may stop early.
 func FileExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
-	case ".txt", ".md", ".log", ".csv", ".json", ".xml", ".ini", ".yaml", ".yml":
-		return textFileExtractText(ctx, path, maxBytes)
-	case ".docx", ".xlsx", ".pptx", ".vsdx":
-		return ooxmlExtractText(ctx, path, maxBytes)
-	case ".pdf":
-		return pdfExtractText(ctx, path, maxBytes)
-	default:
-		return ifilterExtractText(ctx, path, maxBytes)
-	}
+	return Default.ExtractText(ctx, path, maxBytes)
 }
 
 func maxBytesOrDefault(maxBytes int64) int64 {
@@ -29,5 +14,3 @@ func maxBytesOrDefault(maxBytes int64) int64 {
 	}
 	return maxBytes
 }
-
-var errTooLarge = errors.New("提取内容超过上限")
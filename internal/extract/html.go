@@ -0,0 +1,192 @@
+package extract
+
+import (
+	"context"
+	"html"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// HTML 文本提取：常见于 Word“另存为网页”生成、却仍以 .doc 命名的文件。
+// 在解码后的字符流上去掉标签与 script/style 内容，并反转义实体；跨 chunk 的标签/实体由状态机续接。
+
+var htmlMetaCharsetRe = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-zA-Z0-9_\-]+)`)
+
+// htmlCharset 优先使用 <meta charset> 声明，否则回退到通用文本编码判断。
+func htmlCharset(path string, head []byte) charset {
+	if cs, ok := textEncodingOverride(path); ok {
+		return cs
+	}
+	if m := htmlMetaCharsetRe.FindSubmatch(head); m != nil {
+		if cs, ok := parseCharset(string(m[1])); ok && cs != charsetUnknown {
+			return cs
+		}
+	}
+	return textCharsetFor(path, head)
+}
+
+type htmlStripper struct {
+	next nextStringChunkFunc
+
+	inTag   bool
+	tag     strings.Builder // 当前标签内容（不含尖括号），只保留开头用于识别标签名
+	skipEnd string          // 非空时跳过正文直到遇到该结束标签（如 "/script"）
+	skipBuf []rune          // 跳过期间最近读到的字符，用于匹配 "<"+skipEnd（script 内可能有 '<'）
+	entity  strings.Builder // 未闭合的 &...; 实体
+}
+
+func newHTMLStripper(next nextStringChunkFunc) *htmlStripper {
+	return &htmlStripper{next: next}
+}
+
+func (h *htmlStripper) read(ctx context.Context) (string, error) {
+	chunk, err := h.next(ctx)
+	var sb strings.Builder
+	sb.Grow(len(chunk))
+	for _, r := range chunk {
+		if h.skipEnd != "" && !h.inTag {
+			h.skipBuf = append(h.skipBuf, unicode.ToLower(r))
+			if n := len(h.skipEnd) + 1; len(h.skipBuf) > n {
+				h.skipBuf = h.skipBuf[len(h.skipBuf)-n:]
+			}
+			if string(h.skipBuf) == "<"+h.skipEnd {
+				// 剩余部分（直到 '>'）按普通标签吃掉。
+				h.inTag = true
+				h.tag.Reset()
+				h.tag.WriteString(h.skipEnd)
+				h.skipEnd = ""
+				h.skipBuf = h.skipBuf[:0]
+			}
+			continue
+		}
+		if h.inTag {
+			if r == '>' {
+				h.inTag = false
+				h.endTag(&sb)
+				continue
+			}
+			if h.tag.Len() < 64 {
+				h.tag.WriteRune(r)
+			}
+			continue
+		}
+		if r == '<' {
+			h.flushEntity(&sb)
+			h.inTag = true
+			h.tag.Reset()
+			continue
+		}
+		if h.entity.Len() > 0 {
+			h.entity.WriteRune(r)
+			if r == ';' || h.entity.Len() > 12 {
+				h.flushEntity(&sb)
+			}
+			continue
+		}
+		if r == '&' {
+			h.entity.WriteRune(r)
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	if err != nil {
+		h.flushEntity(&sb)
+	}
+	return sb.String(), err
+}
+
+func (h *htmlStripper) flushEntity(sb *strings.Builder) {
+	if h.entity.Len() == 0 {
+		return
+	}
+	sb.WriteString(html.UnescapeString(h.entity.String()))
+	h.entity.Reset()
+}
+
+// endTag 处理刚结束的标签：维护 script/style 跳过状态，块级标签输出换行以免相邻单元格文字粘连。
+func (h *htmlStripper) endTag(sb *strings.Builder) {
+	t := strings.ToLower(h.tag.String())
+	name := t
+	if i := strings.IndexAny(name, " \t\r\n/"); i > 0 {
+		name = name[:i]
+	}
+	switch name {
+	case "script", "style", "xml":
+		if !strings.HasSuffix(t, "/") {
+			h.skipEnd = "/" + name
+		}
+	case "br", "p", "/p", "div", "/div", "tr", "/tr", "li", "h1", "h2", "h3", "h4", "h5", "h6", "/table":
+		sb.WriteByte('\n')
+	case "td", "th":
+		sb.WriteByte('\t')
+	}
+}
+
+func htmlOpenStream(path string) (*os.File, nextStringChunkFunc, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	head := make([]byte, textDetectSampleBytes)
+	n, _ := io.ReadFull(f, head)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, nil, err
+	}
+	cs := htmlCharset(path, head[:n])
+	return f, newHTMLStripper(textChunkReader(f, cs)).read, nil
+}
+
+func htmlFindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	f, next, err := htmlOpenStream(path)
+	if err != nil {
+		return false, "", err
+	}
+	defer f.Close()
+	return streamFindFirst(ctx, next, query, contextLen)
+}
+
+func htmlFindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
+	f, next, err := htmlOpenStream(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return streamFindSnippets(ctx, next, query, contextLen, maxSnippets)
+}
+
+func htmlExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
+	f, next, err := htmlOpenStream(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return collectChunks(ctx, next, maxBytesOrDefault(maxBytes))
+}
+
+type htmlExtractor struct{}
+
+func (htmlExtractor) Name() string { return "html" }
+
+func (htmlExtractor) FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	return htmlFindFirst(ctx, path, query, contextLen)
+}
+
+func (htmlExtractor) FindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
+	return htmlFindSnippets(ctx, path, query, contextLen, maxSnippets)
+}
+
+func (htmlExtractor) ExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
+	return htmlExtractText(ctx, path, maxBytes)
+}
+
+func (htmlExtractor) Stream(ctx context.Context, path string) (TextStream, error) {
+	f, next, err := htmlOpenStream(path)
+	if err != nil {
+		return nil, err
+	}
+	return &funcStream{next: next, close: f.Close}, nil
+}
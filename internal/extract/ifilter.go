@@ -0,0 +1,26 @@
+package extract
+
+import (
+	"context"
+)
+
+// ifilterExtractor 交给系统 IFilter（仅 Windows；其它平台的实现返回不支持）。
+type ifilterExtractor struct{}
+
+func (ifilterExtractor) Name() string { return "ifilter" }
+
+func (ifilterExtractor) FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	return ifilterFindFirst(ctx, path, query, contextLen)
+}
+
+func (ifilterExtractor) FindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
+	return ifilterFindSnippets(ctx, path, query, contextLen, maxSnippets)
+}
+
+func (ifilterExtractor) ExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
+	return ifilterExtractText(ctx, path, maxBytes)
+}
+
+func (ifilterExtractor) Stream(ctx context.Context, path string) (TextStream, error) {
+	return ifilterOpenStream(ctx, path)
+}
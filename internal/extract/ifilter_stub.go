@@ -4,22 +4,24 @@ package extract
 
 import (
 	"context"
-	"errors"
+	"fmt"
 )
 
+var errIFilterUnavailable = fmt.Errorf("%w: 该格式需要 Windows IFilter 支持（当前非 Windows）", ErrBackendMissing)
+
 func ifilterFindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
 	_ = ctx
 	_ = path
 	_ = query
 	_ = contextLen
-	return false, "", errors.New("该格式需要 Windows IFilter 支持（当前非 Windows）")
+	return false, "", errIFilterUnavailable
 }
 
 func ifilterExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
 	_ = ctx
 	_ = path
 	_ = maxBytes
-	return "", errors.New("该格式需要 Windows IFilter 支持（当前非 Windows）")
+	return "", errIFilterUnavailable
 }
 
 func ifilterFindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
@@ -28,7 +30,13 @@ func ifilterFindSnippets(ctx context.Context, path string, query string, context
 	_ = query
 	_ = contextLen
 	_ = maxSnippets
-	return nil, errors.New("该格式需要 Windows IFilter 支持（当前非 Windows）")
+	return nil, errIFilterUnavailable
+}
+
+func ifilterOpenStream(ctx context.Context, path string) (TextStream, error) {
+	_ = ctx
+	_ = path
+	return nil, errIFilterUnavailable
 }
 
 func HasPDFIFilter() bool {
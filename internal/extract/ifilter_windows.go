@@ -6,8 +6,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"runtime"
 	"strings"
 	"syscall"
 	"unsafe"
@@ -236,15 +238,15 @@ func loadIFilter(path string) (*iFilter, error) {
 		// 常见IFilter相关错误代码
 		switch hr32 {
 		case 0x8004174B: // FILTER_E_EMBEDDING_UNAVAILABLE
-			return nil, fmt.Errorf("LoadIFilter failed with FILTER_E_EMBEDDING_UNAVAILABLE (0x%08X): file may be corrupt or unsupported", hr32)
+			return nil, fmt.Errorf("%w: LoadIFilter failed with FILTER_E_EMBEDDING_UNAVAILABLE (0x%08X): file may be corrupt or unsupported", ErrCorrupt, hr32)
 		case 0x8004170B: // FILTER_E_PASSWORD
-			return nil, fmt.Errorf("LoadIFilter failed with FILTER_E_PASSWORD (0x%08X): file requires password", hr32)
+			return nil, fmt.Errorf("%w: LoadIFilter failed with FILTER_E_PASSWORD (0x%08X): file requires password", ErrEncrypted, hr32)
 		case 0x8004170C: // FILTER_E_UNKNOWNFORMAT
-			return nil, fmt.Errorf("LoadIFilter failed with FILTER_E_UNKNOWNFORMAT (0x%08X): unknown file format", hr32)
+			return nil, fmt.Errorf("%w: LoadIFilter failed with FILTER_E_UNKNOWNFORMAT (0x%08X): unknown file format", ErrUnsupported, hr32)
 		case 0x800401E3: // MK_E_UNAVAILABLE
-			return nil, fmt.Errorf("LoadIFilter failed with MK_E_UNAVAILABLE (0x%08X): class not available, IFilter may not be registered", hr32)
+			return nil, fmt.Errorf("%w: LoadIFilter failed with MK_E_UNAVAILABLE (0x%08X): class not available, IFilter may not be registered", ErrBackendMissing, hr32)
 		case 0x80070005: // E_ACCESSDENIED
-			return nil, fmt.Errorf("LoadIFilter failed with E_ACCESSDENIED (0x%08X): access denied", hr32)
+			return nil, fmt.Errorf("%w: LoadIFilter failed with E_ACCESSDENIED (0x%08X): access denied", os.ErrPermission, hr32)
 		case 0x80004005: // E_FAIL
 			return nil, fmt.Errorf("LoadIFilter failed with E_FAIL (0x%08X): unspecified error", hr32)
 		default:
@@ -274,7 +276,7 @@ func (f *iFilter) init() error {
 		uintptr(unsafe.Pointer(&attrs)),
 	)
 	if failed(uint32(hr)) {
-		return errors.New("IFilter Init 失败")
+		return fmt.Errorf("%w: IFilter Init 失败", ErrCorrupt)
 	}
 	return nil
 }
@@ -367,6 +369,76 @@ func ifilterExtractText(ctx context.Context, path string, maxBytes int64) (strin
 	return sb.String(), nil
 }
 
+// ifilterStream 逐段返回 IFilter 的文本。COM 初始化绑定到当前线程，
+// 因此打开时锁定 OS 线程，Next/Close 必须在调用 ifilterOpenStream 的同一 goroutine 中进行。
+type ifilterStream struct {
+	flt     *iFilter
+	inChunk bool
+	done    bool
+}
+
+func ifilterOpenStream(ctx context.Context, path string) (TextStream, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	runtime.LockOSThread()
+	if err := coInitialize(); err != nil {
+		runtime.UnlockOSThread()
+		return nil, err
+	}
+	flt, err := loadIFilter(path)
+	if err != nil {
+		coUninitialize()
+		runtime.UnlockOSThread()
+		return nil, err
+	}
+	if err := flt.init(); err != nil {
+		flt.release()
+		coUninitialize()
+		runtime.UnlockOSThread()
+		return nil, err
+	}
+	return &ifilterStream{flt: flt}, nil
+}
+
+func (s *ifilterStream) Next(ctx context.Context) (string, error) {
+	for !s.done {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if !s.inChunk {
+			var chunk statChunk
+			hr := s.flt.getChunk(&chunk)
+			if hr == FILTER_E_END_OF_CHUNKS || failed(hr) {
+				s.done = true
+				break
+			}
+			s.inChunk = chunk.flags&CHUNK_TEXT != 0
+			continue
+		}
+		text, hr := s.flt.getText()
+		if hr == FILTER_E_NO_MORE_TEXT || failed(hr) {
+			s.inChunk = false
+			continue
+		}
+		if text != "" {
+			return text + " ", nil
+		}
+	}
+	return "", io.EOF
+}
+
+func (s *ifilterStream) Close() error {
+	if s.flt == nil {
+		return nil
+	}
+	s.flt.release()
+	s.flt = nil
+	coUninitialize()
+	runtime.UnlockOSThread()
+	return nil
+}
+
 func failed(hr uint32) bool {
 	return hr&0x80000000 != 0
 }
@@ -17,13 +17,13 @@ func ooxmlContains(ctx context.Context, path string, query string) (bool, error)
 		return false, errors.New("query 为空")
 	}
 
-	zr, err := zip.OpenReader(path)
+	zr, err := ooxmlOpen(ctx, path)
 	if err != nil {
 		return false, err
 	}
 	defer zr.Close()
 
-	ext := strings.ToLower(filepath.Ext(path))
+	ext := ooxmlDocExt(path, zr.Reader)
 	qb := []byte(q)
 	for _, f := range zr.File {
 		if ctx.Err() != nil {
@@ -33,11 +33,11 @@ func ooxmlContains(ctx context.Context, path string, query string) (bool, error)
 		if !ooxmlEntryInteresting(ext, name) {
 			continue
 		}
-		rc, err := f.Open()
+		rc, err := zr.guard.open(f)
 		if err != nil {
 			continue
 		}
-		ok, rerr := xmlStreamContains(ctx, rc, qb)
+		ok, rerr := xmlStreamContains(ctx, zr.guard.xmlDecoder(rc, f.Name), qb)
 		_ = rc.Close()
 		if rerr == nil && ok {
 			return true, nil
@@ -52,27 +52,33 @@ func ooxmlFindFirst(ctx context.Context, path string, query string, contextLen i
 		return false, "", errors.New("query 为空")
 	}
 
-	zr, err := zip.OpenReader(path)
+	zr, err := ooxmlOpen(ctx, path)
 	if err != nil {
 		return false, "", err
 	}
 	defer zr.Close()
 
-	ext := strings.ToLower(filepath.Ext(path))
+	ext := ooxmlDocExt(path, zr.Reader)
 	qb := []byte(q)
 	for _, f := range zr.File {
 		if ctx.Err() != nil {
 			return false, "", ctx.Err()
 		}
 		name := strings.ToLower(f.Name)
+		if ooxmlIsVBAProject(name) {
+			if snips := vbaSnippets(ooxmlVBAModules(ctx, zr.guard, f), q, contextLen, 1); len(snips) > 0 {
+				return true, snips[0], nil
+			}
+			continue
+		}
 		if !ooxmlEntryInteresting(ext, name) {
 			continue
 		}
-		rc, err := f.Open()
+		rc, err := zr.guard.open(f)
 		if err != nil {
 			continue
 		}
-		ok, snip, _ := xmlStreamFindFirst(ctx, rc, q, qb, contextLen)
+		ok, snip, _ := xmlStreamFindFirst(ctx, zr.guard.xmlDecoder(rc, f.Name), q, qb, contextLen)
 		_ = rc.Close()
 		if ok {
 			return true, snip, nil
@@ -100,12 +106,8 @@ func ooxmlEntryInteresting(ext, name string) bool {
 	}
 }
 
-func xmlStreamContains(ctx context.Context, r io.Reader, query []byte) (bool, error) {
-	// 防止巨大 XML 节点导致内存暴涨；这里只做“尽力而为”扫描。
-	const maxScanBytes = 20 * 1024 * 1024
-	r = io.LimitReader(r, maxScanBytes)
-
-	dec := xml.NewDecoder(r)
+// xmlStream* 的 dec 由 zipGuard.xmlDecoder 创建：读取量与嵌套深度受 zip 安全预算限制。
+func xmlStreamContains(ctx context.Context, dec *xml.Decoder, query []byte) (bool, error) {
 	for {
 		if ctx.Err() != nil {
 			return false, ctx.Err()
@@ -126,12 +128,7 @@ func xmlStreamContains(ctx context.Context, r io.Reader, query []byte) (bool, er
 	}
 }
 
-func xmlStreamFindFirst(ctx context.Context, r io.Reader, query string, queryBytes []byte, contextLen int) (bool, string, error) {
-	// 防止巨大 XML 节点导致内存暴涨；这里只做“尽力而为”扫描。
-	const maxScanBytes = 20 * 1024 * 1024
-	r = io.LimitReader(r, maxScanBytes)
-
-	dec := xml.NewDecoder(r)
+func xmlStreamFindFirst(ctx context.Context, dec *xml.Decoder, query string, queryBytes []byte, contextLen int) (bool, string, error) {
 	for {
 		if ctx.Err() != nil {
 			return false, "", ctx.Err()
@@ -159,13 +156,13 @@ func xmlStreamFindFirst(ctx context.Context, r io.Reader, query string, queryByt
 
 func ooxmlExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
 	maxBytes = maxBytesOrDefault(maxBytes)
-	zr, err := zip.OpenReader(path)
+	zr, err := ooxmlOpen(ctx, path)
 	if err != nil {
 		return "", err
 	}
 	defer zr.Close()
 
-	ext := strings.ToLower(filepath.Ext(path))
+	ext := ooxmlDocExt(path, zr.Reader)
 	var sb strings.Builder
 	var approx int64
 	for _, f := range zr.File {
@@ -173,10 +170,18 @@ func ooxmlExtractText(ctx context.Context, path string, maxBytes int64) (string,
 			return "", ctx.Err()
 		}
 		name := strings.ToLower(f.Name)
+		if ooxmlIsVBAProject(name) {
+			text := truncateUTF8(vbaText(ooxmlVBAModules(ctx, zr.guard, f)), int(maxBytes-approx))
+			sb.WriteString(text)
+			if approx += int64(len(text)); approx >= maxBytes {
+				break
+			}
+			continue
+		}
 		if !ooxmlEntryInteresting(ext, name) {
 			continue
 		}
-		rc, err := f.Open()
+		rc, err := zr.guard.open(f)
 		if err != nil {
 			continue
 		}
@@ -191,7 +196,7 @@ func ooxmlExtractText(ctx context.Context, path string, maxBytes int64) (string,
 		if limit < 64*1024 {
 			limit = 64 * 1024
 		}
-		dec := xml.NewDecoder(io.LimitReader(rc, limit))
+		dec := zr.guard.xmlDecoder(io.LimitReader(rc, limit), f.Name)
 		for {
 			if ctx.Err() != nil {
 				_ = rc.Close()
@@ -236,13 +241,13 @@ func ooxmlFindSnippets(ctx context.Context, path string, query string, contextLe
 		return nil, errors.New("query 为空")
 	}
 
-	zr, err := zip.OpenReader(path)
+	zr, err := ooxmlOpen(ctx, path)
 	if err != nil {
 		return nil, err
 	}
 	defer zr.Close()
 
-	ext := strings.ToLower(filepath.Ext(path))
+	ext := ooxmlDocExt(path, zr.Reader)
 	qb := []byte(q)
 	
 	allSnips := make([]string, 0, maxSnippets)
@@ -252,14 +257,21 @@ func ooxmlFindSnippets(ctx context.Context, path string, query string, contextLe
 			return nil, ctx.Err()
 		}
 		name := strings.ToLower(f.Name)
+		if ooxmlIsVBAProject(name) {
+			allSnips = append(allSnips, vbaSnippets(ooxmlVBAModules(ctx, zr.guard, f), q, contextLen, maxSnippets-len(allSnips))...)
+			if len(allSnips) >= maxSnippets {
+				return allSnips, nil
+			}
+			continue
+		}
 		if !ooxmlEntryInteresting(ext, name) {
 			continue
 		}
-		rc, err := f.Open()
+		rc, err := zr.guard.open(f)
 		if err != nil {
 			continue
 		}
-		found, err := xmlStreamFindSnippets(ctx, rc, q, qb, contextLen, maxSnippets - len(allSnips))
+		found, err := xmlStreamFindSnippets(ctx, zr.guard.xmlDecoder(rc, f.Name), q, qb, contextLen, maxSnippets - len(allSnips))
 		_ = rc.Close()
 		if err == nil && len(found) > 0 {
 			allSnips = append(allSnips, found...)
@@ -271,15 +283,10 @@ func ooxmlFindSnippets(ctx context.Context, path string, query string, contextLe
 	return allSnips, nil
 }
 
-func xmlStreamFindSnippets(ctx context.Context, r io.Reader, query string, queryBytes []byte, contextLen int, maxSnippets int) ([]string, error) {
+func xmlStreamFindSnippets(ctx context.Context, dec *xml.Decoder, query string, queryBytes []byte, contextLen int, maxSnippets int) ([]string, error) {
 	if maxSnippets <= 0 {
 		return nil, nil
 	}
-	// Limit read per XML file to avoid infinite loops or memory bombs
-	const maxScanBytes = 20 * 1024 * 1024
-	r = io.LimitReader(r, maxScanBytes)
-
-	dec := xml.NewDecoder(r)
 	snips := make([]string, 0, maxSnippets)
 
 	for {
@@ -309,3 +316,140 @@ func xmlStreamFindSnippets(ctx context.Context, r io.Reader, query string, query
 		}
 	}
 }
+
+// ooxmlDocExt 返回用于筛选 entry 的文档类型扩展名。
+// 扩展名本身不是 OOXML 时（经内容识别而来，如无扩展名或 .doc 实为 docx），按包内目录推断。
+func ooxmlDocExt(path string, zr *zip.Reader) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".docx", ".xlsx", ".pptx", ".vsdx":
+		return ext
+	case ".docm", ".dotx":
+		return ".docx"
+	case ".xlsm", ".xltx":
+		return ".xlsx"
+	case ".pptm", ".potx":
+		return ".pptx"
+	}
+	for _, f := range zr.File {
+		name := strings.ToLower(f.Name)
+		switch {
+		case strings.HasPrefix(name, "word/"):
+			return ".docx"
+		case strings.HasPrefix(name, "xl/"):
+			return ".xlsx"
+		case strings.HasPrefix(name, "ppt/"):
+			return ".pptx"
+		case strings.HasPrefix(name, "visio/"):
+			return ".vsdx"
+		}
+	}
+	return ext
+}
+
+type ooxmlExtractor struct{}
+
+func (ooxmlExtractor) Name() string { return "ooxml" }
+
+func (ooxmlExtractor) FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	return ooxmlFindFirst(ctx, path, query, contextLen)
+}
+
+func (ooxmlExtractor) FindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
+	return ooxmlFindSnippets(ctx, path, query, contextLen, maxSnippets)
+}
+
+func (ooxmlExtractor) ExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
+	return ooxmlExtractText(ctx, path, maxBytes)
+}
+
+func (ooxmlExtractor) Stream(ctx context.Context, path string) (TextStream, error) {
+	zr, err := ooxmlOpen(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	s := &ooxmlStream{zr: zr, ext: ooxmlDocExt(path, zr.Reader)}
+	return &funcStream{next: s.next, close: s.close}, nil
+}
+
+// ooxmlStream 依次读取正文相关的 entry，把 CharData 以空格分隔拼成约 32KiB 的 chunk；vbaProject.bin 输出宏源码。
+type ooxmlStream struct {
+	zr     *ooxmlPackage
+	ext    string
+	idx    int
+	rc     io.ReadCloser
+	dec    *xml.Decoder
+	macros string // 待输出的宏源码
+	done   bool
+}
+
+func (s *ooxmlStream) next(ctx context.Context) (string, error) {
+	if s.done {
+		return "", io.EOF
+	}
+	var sb strings.Builder
+	for sb.Len() < 32*1024 {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if s.macros != "" {
+			sb.WriteString(s.macros)
+			s.macros = ""
+			continue
+		}
+		if s.dec == nil {
+			if !s.openNextEntry(ctx) {
+				s.done = true
+				return sb.String(), io.EOF
+			}
+			continue
+		}
+		tok, err := s.dec.Token()
+		if err != nil {
+			_ = s.rc.Close()
+			s.rc, s.dec = nil, nil
+			continue
+		}
+		if cd, ok := tok.(xml.CharData); ok && len(cd) > 0 {
+			_, _ = sb.Write(cd)
+			sb.WriteByte(' ')
+		}
+	}
+	return sb.String(), nil
+}
+
+func (s *ooxmlStream) openNextEntry(ctx context.Context) bool {
+	for s.idx < len(s.zr.File) {
+		f := s.zr.File[s.idx]
+		s.idx++
+		name := strings.ToLower(f.Name)
+		if ooxmlIsVBAProject(name) {
+			if s.macros = vbaText(ooxmlVBAModules(ctx, s.zr.guard, f)); s.macros != "" {
+				return true // 由 next 先输出宏源码
+			}
+			continue
+		}
+		if !ooxmlEntryInteresting(s.ext, name) {
+			continue
+		}
+		rc, err := s.zr.guard.open(f)
+		if err != nil {
+			if s.zr.guard.exhausted() {
+				return false
+			}
+			continue
+		}
+		s.rc = rc
+		s.dec = s.zr.guard.xmlDecoder(rc, f.Name)
+		return true
+	}
+	return false
+}
+
+func (s *ooxmlStream) close() error {
+	if s.rc != nil {
+		_ = s.rc.Close()
+		s.rc, s.dec = nil, nil
+	}
+	return s.zr.Close()
+}
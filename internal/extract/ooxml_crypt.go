@@ -0,0 +1,509 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// 加密 OOXML（[MS-OFFCRYPTO]）：设置了打开口令的 docx/xlsx/pptx 不是 zip，而是 CFB 容器，
+// 内含 EncryptionInfo（加密参数）与 EncryptedPackage（加密后的 zip）两个流。
+// 支持 Agile（4.4，Office 2010+ 默认，AES + SHA-512 等）与 Standard（3.2/4.2，AES-ECB + SHA-1）。
+// 解密结果放在内存中，大小受 OFIND_OOXML_MAX_DECRYPT_BYTES 限制。
+
+// ooxmlDefaultPassword 为 Excel 在“只保护结构、不设打开口令”时使用的内置口令。
+const ooxmlDefaultPassword = "VelvetSweatshop"
+
+func ooxmlMaxDecryptBytes() int64 {
+	const def = 64 * 1024 * 1024
+	v := strings.TrimSpace(os.Getenv("OFIND_OOXML_MAX_DECRYPT_BYTES"))
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
+
+// ooxmlPackage 为打开后的 OOXML 包：普通 zip 或解密到内存的 zip。
+type ooxmlPackage struct {
+	*zip.Reader
+	guard *zipGuard
+	close func() error
+}
+
+func (p *ooxmlPackage) Close() error {
+	if p.close == nil {
+		return nil
+	}
+	c := p.close
+	p.close = nil
+	return c()
+}
+
+// ooxmlOpen 打开 OOXML 包并建立 zip 安全预算；不是 zip 而是加密容器时，依次尝试内置口令与口令列表解密。
+func ooxmlOpen(ctx context.Context, path string) (*ooxmlPackage, error) {
+	zr, err := zip.OpenReader(path)
+	if err == nil {
+		g, err := newZipGuard(ctx, &zr.Reader)
+		if err != nil {
+			_ = zr.Close()
+			return nil, err
+		}
+		return &ooxmlPackage{Reader: &zr.Reader, guard: g, close: zr.Close}, nil
+	}
+	data, derr := ooxmlDecryptFile(ctx, path)
+	if derr != nil {
+		if errors.Is(derr, errNotEncryptedOOXML) {
+			if errors.Is(err, zip.ErrFormat) {
+				return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+			}
+			return nil, err
+		}
+		return nil, derr
+	}
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: 解密后的包: %v", ErrCorrupt, err)
+	}
+	g, err := newZipGuard(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	traceEncrypted(ctx)
+	return &ooxmlPackage{Reader: r, guard: g}, nil
+}
+
+var errNotEncryptedOOXML = errors.New("不是加密的 OOXML")
+
+// cfbIsEncryptedOOXML 判断 CFB 文件是否为加密的 OOXML 容器（供内容识别使用）。
+func cfbIsEncryptedOOXML(f *os.File) bool {
+	st, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	c, err := openCFB(f, st.Size())
+	if err != nil {
+		return false
+	}
+	return c.find("EncryptionInfo") >= 0 && c.find("EncryptedPackage") >= 0
+}
+
+func ooxmlDecryptFile(ctx context.Context, path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	c, err := openCFB(f, st.Size())
+	if err != nil {
+		return nil, errNotEncryptedOOXML
+	}
+	infoIdx, pkgIdx := c.find("EncryptionInfo"), c.find("EncryptedPackage")
+	if infoIdx < 0 || pkgIdx < 0 {
+		return nil, errNotEncryptedOOXML
+	}
+	info, err := c.readStream(infoIdx, 1024*1024)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := parseOOXMLEncryptionInfo(info)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEncrypted, err)
+	}
+
+	var key []byte
+	for _, pw := range append([]string{ooxmlDefaultPassword}, passwordsFor(ctx)...) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if key, err = dec.keyFor(pw); err == nil {
+			break
+		}
+	}
+	if key == nil {
+		return nil, ErrEncrypted
+	}
+
+	pkg, err := c.readStream(pkgIdx, ooxmlMaxDecryptBytes()+8)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkg) < 8 {
+		return nil, errCFBMalformed
+	}
+	size := binary.LittleEndian.Uint64(pkg)
+	if size > uint64(len(pkg)-8) {
+		return nil, errCFBMalformed
+	}
+	out, err := dec.decryptPackage(key, pkg[8:])
+	if err != nil {
+		return nil, err
+	}
+	return out[:size], nil
+}
+
+// ooxmlDecryptor 为一种加密方式：校验口令得到密钥，再用密钥解密 EncryptedPackage。
+type ooxmlDecryptor interface {
+	keyFor(password string) ([]byte, error)
+	decryptPackage(key, data []byte) ([]byte, error)
+}
+
+var errWrongPassword = errors.New("口令错误")
+
+func parseOOXMLEncryptionInfo(b []byte) (ooxmlDecryptor, error) {
+	if len(b) < 8 {
+		return nil, errCFBMalformed
+	}
+	major := binary.LittleEndian.Uint16(b)
+	minor := binary.LittleEndian.Uint16(b[2:])
+	switch {
+	case major == 4 && minor == 4:
+		return parseAgileEncryption(b[8:])
+	case (major == 3 || major == 4) && minor == 2:
+		return parseStandardEncryption(b[4:])
+	}
+	return nil, fmt.Errorf("不支持的加密版本 %d.%d", major, minor)
+}
+
+func utf16LEBytes(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	out := make([]byte, 2*len(u))
+	for i, v := range u {
+		binary.LittleEndian.PutUint16(out[2*i:], v)
+	}
+	return out
+}
+
+// ---- Agile ----
+
+type agileParams struct {
+	SaltValue       string `xml:"saltValue,attr"`
+	BlockSize       int    `xml:"blockSize,attr"`
+	KeyBits         int    `xml:"keyBits,attr"`
+	HashSize        int    `xml:"hashSize,attr"`
+	CipherAlgorithm string `xml:"cipherAlgorithm,attr"`
+	CipherChaining  string `xml:"cipherChaining,attr"`
+	HashAlgorithm   string `xml:"hashAlgorithm,attr"`
+}
+
+type agileEncryption struct {
+	KeyData       agileParams `xml:"keyData"`
+	KeyEncryptors []struct {
+		URI          string `xml:"uri,attr"`
+		EncryptedKey struct {
+			agileParams
+			SpinCount                  int    `xml:"spinCount,attr"`
+			EncryptedVerifierHashInput string `xml:"encryptedVerifierHashInput,attr"`
+			EncryptedVerifierHashValue string `xml:"encryptedVerifierHashValue,attr"`
+			EncryptedKeyValue          string `xml:"encryptedKeyValue,attr"`
+		} `xml:"encryptedKey"`
+	} `xml:"keyEncryptors>keyEncryptor"`
+
+	pw                                     agileParams // 口令密钥加密器的参数
+	spin                                   int
+	keySalt, pwSalt                        []byte
+	verifierInput, verifierHash, encrypted []byte
+}
+
+var (
+	agileBlockVerifierInput = []byte{0xfe, 0xa7, 0xd2, 0x76, 0x3b, 0x4b, 0x9e, 0x79}
+	agileBlockVerifierHash  = []byte{0xd7, 0xaa, 0x0f, 0x6d, 0x30, 0x61, 0x34, 0x4e}
+	agileBlockKeyValue      = []byte{0x14, 0x6e, 0x0b, 0xe7, 0xab, 0xac, 0xd0, 0xd6}
+)
+
+func agileHash(name string) (func() hash.Hash, error) {
+	switch strings.ToUpper(strings.ReplaceAll(name, "-", "")) {
+	case "SHA512":
+		return sha512.New, nil
+	case "SHA384":
+		return sha512.New384, nil
+	case "SHA256":
+		return sha256.New, nil
+	case "SHA1":
+		return sha1.New, nil
+	}
+	return nil, fmt.Errorf("不支持的哈希算法 %q", name)
+}
+
+func parseAgileEncryption(b []byte) (*agileEncryption, error) {
+	var a agileEncryption
+	if err := xml.Unmarshal(b, &a); err != nil {
+		return nil, err
+	}
+	if kd := a.KeyData; !strings.EqualFold(kd.CipherAlgorithm, "AES") || !strings.EqualFold(kd.CipherChaining, "ChainingModeCBC") {
+		return nil, fmt.Errorf("不支持的加密算法 %s/%s", kd.CipherAlgorithm, kd.CipherChaining)
+	}
+	var err error
+	if a.keySalt, err = base64.StdEncoding.DecodeString(a.KeyData.SaltValue); err != nil {
+		return nil, err
+	}
+	for i := range a.KeyEncryptors {
+		ek := &a.KeyEncryptors[i].EncryptedKey
+		if !strings.HasSuffix(a.KeyEncryptors[i].URI, "/password") || !strings.EqualFold(ek.CipherAlgorithm, "AES") {
+			continue
+		}
+		dec := base64.StdEncoding.DecodeString
+		if a.pwSalt, err = dec(ek.SaltValue); err != nil {
+			return nil, err
+		}
+		if a.verifierInput, err = dec(ek.EncryptedVerifierHashInput); err != nil {
+			return nil, err
+		}
+		if a.verifierHash, err = dec(ek.EncryptedVerifierHashValue); err != nil {
+			return nil, err
+		}
+		if a.encrypted, err = dec(ek.EncryptedKeyValue); err != nil {
+			return nil, err
+		}
+		a.pw, a.spin = ek.agileParams, ek.SpinCount
+		return &a, nil
+	}
+	return nil, errors.New("没有基于口令的密钥加密器（可能是证书加密）")
+}
+
+// agileFit 把 b 截断或以 0x36 填充到 n 字节（[MS-OFFCRYPTO] 2.3.4.11/12）。
+func agileFit(b []byte, n int) []byte {
+	if len(b) >= n {
+		return b[:n]
+	}
+	out := make([]byte, n)
+	copy(out, b)
+	for i := len(b); i < n; i++ {
+		out[i] = 0x36
+	}
+	return out
+}
+
+func aesCBCDecrypt(key, iv, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%block.BlockSize() != 0 || len(iv) != block.BlockSize() {
+		return nil, errCFBMalformed
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	return out, nil
+}
+
+func (a *agileEncryption) keyFor(password string) ([]byte, error) {
+	p, spin := a.pw, a.spin
+	newHash, err := agileHash(p.HashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	if spin < 0 || spin > 10000000 {
+		return nil, errCFBMalformed
+	}
+	h := newHash()
+	h.Write(a.pwSalt)
+	h.Write(utf16LEBytes(password))
+	sum := h.Sum(nil)
+	var iter [4]byte
+	for i := 0; i < spin; i++ {
+		binary.LittleEndian.PutUint32(iter[:], uint32(i))
+		h.Reset()
+		h.Write(iter[:])
+		h.Write(sum)
+		sum = h.Sum(sum[:0])
+	}
+	derive := func(blockKey []byte) []byte {
+		h.Reset()
+		h.Write(sum)
+		h.Write(blockKey)
+		return agileFit(h.Sum(nil), p.KeyBits/8)
+	}
+	iv := agileFit(a.pwSalt, p.BlockSize)
+
+	input, err := aesCBCDecrypt(derive(agileBlockVerifierInput), iv, a.verifierInput)
+	if err != nil {
+		return nil, err
+	}
+	want, err := aesCBCDecrypt(derive(agileBlockVerifierHash), iv, a.verifierHash)
+	if err != nil {
+		return nil, err
+	}
+	h.Reset()
+	h.Write(input[:minInt(len(input), len(a.pwSalt))])
+	got := h.Sum(nil)
+	if len(want) < p.HashSize || p.HashSize > len(got) || !bytes.Equal(got[:p.HashSize], want[:p.HashSize]) {
+		return nil, errWrongPassword
+	}
+	key, err := aesCBCDecrypt(derive(agileBlockKeyValue), iv, a.encrypted)
+	if err != nil {
+		return nil, err
+	}
+	return key[:minInt(len(key), p.KeyBits/8)], nil
+}
+
+// decryptPackage 按 4096 字节分段解密；每段 IV = Hash(keyData.salt + 段号) 截断到 blockSize。
+func (a *agileEncryption) decryptPackage(key, data []byte) ([]byte, error) {
+	newHash, err := agileHash(a.KeyData.HashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	bs := block.BlockSize()
+	if len(data)%bs != 0 {
+		data = data[:len(data)-len(data)%bs]
+	}
+	const segment = 4096
+	out := make([]byte, len(data))
+	h := newHash()
+	var idx [4]byte
+	for off, n := 0, uint32(0); off < len(data); off, n = off+segment, n+1 {
+		end := minInt(off+segment, len(data))
+		binary.LittleEndian.PutUint32(idx[:], n)
+		h.Reset()
+		h.Write(a.keySalt)
+		h.Write(idx[:])
+		iv := agileFit(h.Sum(nil), a.KeyData.BlockSize)
+		if len(iv) != bs {
+			return nil, errCFBMalformed
+		}
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(out[off:end], data[off:end])
+	}
+	return out, nil
+}
+
+// ---- Standard ----
+
+type standardEncryption struct {
+	algID    uint32
+	keyBits  uint32
+	salt     []byte
+	verifier []byte
+	vhash    []byte
+}
+
+func parseStandardEncryption(b []byte) (*standardEncryption, error) {
+	// Flags(4) + HeaderSize(4) + EncryptionHeader + EncryptionVerifier（紧跟在 Version 之后）
+	if len(b) < 8 {
+		return nil, errCFBMalformed
+	}
+	hsize := int(binary.LittleEndian.Uint32(b[4:]))
+	b = b[8:]
+	if hsize < 32 || len(b) < hsize+4+16+16+4+32 {
+		return nil, errCFBMalformed
+	}
+	s := &standardEncryption{
+		algID:   binary.LittleEndian.Uint32(b[8:]),
+		keyBits: binary.LittleEndian.Uint32(b[16:]),
+	}
+	v := b[hsize:]
+	if binary.LittleEndian.Uint32(v) != 16 {
+		return nil, errCFBMalformed
+	}
+	s.salt = v[4:20]
+	s.verifier = v[20:36]
+	s.vhash = v[40:72]
+	switch s.algID {
+	case 0x660E, 0x660F, 0x6610: // AES-128/192/256
+	default:
+		return nil, fmt.Errorf("不支持的加密算法 0x%04X", s.algID)
+	}
+	if s.keyBits == 0 {
+		s.keyBits = 128
+	}
+	if s.keyBits != 128 && s.keyBits != 192 && s.keyBits != 256 {
+		return nil, fmt.Errorf("不支持的密钥长度 %d", s.keyBits)
+	}
+	return s, nil
+}
+
+func aesECBDecrypt(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	bs := block.BlockSize()
+	data = data[:len(data)-len(data)%bs]
+	out := make([]byte, len(data))
+	for i := 0; i < len(data); i += bs {
+		block.Decrypt(out[i:i+bs], data[i:i+bs])
+	}
+	return out, nil
+}
+
+// deriveKey 按 [MS-OFFCRYPTO] 2.3.4.7：SHA-1 迭代 50000 次，再经 0x36/0x5C 派生出 AES 密钥。
+func (s *standardEncryption) deriveKey(password string) []byte {
+	h := sha1.New()
+	h.Write(s.salt)
+	h.Write(utf16LEBytes(password))
+	sum := h.Sum(nil)
+	var iter [4]byte
+	for i := 0; i < 50000; i++ {
+		binary.LittleEndian.PutUint32(iter[:], uint32(i))
+		h.Reset()
+		h.Write(iter[:])
+		h.Write(sum)
+		sum = h.Sum(sum[:0])
+	}
+	h.Reset()
+	h.Write(sum)
+	h.Write([]byte{0, 0, 0, 0})
+	final := h.Sum(nil)
+	derive := func(pad byte) []byte {
+		buf := bytes.Repeat([]byte{pad}, 64)
+		for i := range final {
+			buf[i] ^= final[i]
+		}
+		x := sha1.Sum(buf)
+		return x[:]
+	}
+	return append(derive(0x36), derive(0x5C)...)[:s.keyBits/8]
+}
+
+func (s *standardEncryption) keyFor(password string) ([]byte, error) {
+	key := s.deriveKey(password)
+	verifier, err := aesECBDecrypt(key, s.verifier)
+	if err != nil {
+		return nil, err
+	}
+	vhash, err := aesECBDecrypt(key, s.vhash)
+	if err != nil {
+		return nil, err
+	}
+	want := sha1.Sum(verifier)
+	if !bytes.Equal(vhash[:sha1.Size], want[:]) {
+		return nil, errWrongPassword
+	}
+	return key, nil
+}
+
+func (s *standardEncryption) decryptPackage(key, data []byte) ([]byte, error) {
+	return aesECBDecrypt(key, data)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
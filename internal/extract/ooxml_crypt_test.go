@@ -0,0 +1,165 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testDocx(t *testing.T, text string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0"?><Types/>`,
+		"word/document.xml":   `<?xml version="1.0"?><w:document xmlns:w="w"><w:body><w:p><w:r><w:t>` + text + `</w:t></w:r></w:p></w:body></w:document>`,
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testAESCBC(key, iv, data []byte) []byte {
+	block, _ := aes.NewCipher(key)
+	if r := len(data) % 16; r != 0 {
+		data = append(append([]byte{}, data...), make([]byte, 16-r)...)
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	return out
+}
+
+func testAESECB(key, data []byte) []byte {
+	block, _ := aes.NewCipher(key)
+	out := make([]byte, len(data))
+	for i := 0; i < len(data); i += 16 {
+		block.Encrypt(out[i:i+16], data[i:i+16])
+	}
+	return out
+}
+
+// encryptAgile 按 Agile（AES-256 + SHA-512）加密 pkg，返回 EncryptionInfo 与 EncryptedPackage。
+func encryptAgile(password string, pkg []byte) (info, encPkg []byte) {
+	keySalt := bytes.Repeat([]byte{1}, 16)
+	pwSalt := bytes.Repeat([]byte{2}, 16)
+	secret := bytes.Repeat([]byte{3}, 32)
+	verifier := bytes.Repeat([]byte{4}, 16)
+	const spin = 1000
+
+	h := sha512.New()
+	h.Write(pwSalt)
+	h.Write(utf16LEBytes(password))
+	sum := h.Sum(nil)
+	for i := 0; i < spin; i++ {
+		var it [4]byte
+		binary.LittleEndian.PutUint32(it[:], uint32(i))
+		h.Reset()
+		h.Write(it[:])
+		h.Write(sum)
+		sum = h.Sum(sum[:0])
+	}
+	derive := func(bk []byte) []byte {
+		x := sha512.Sum512(append(append([]byte{}, sum...), bk...))
+		return x[:32]
+	}
+	vh := sha512.Sum512(verifier)
+	b64 := base64.StdEncoding.EncodeToString
+	xmlInfo := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<encryption xmlns="http://schemas.microsoft.com/office/2006/encryption" xmlns:p="http://schemas.microsoft.com/office/2006/keyEncryptor/password">
+<keyData saltSize="16" blockSize="16" keyBits="256" hashSize="64" cipherAlgorithm="AES" cipherChaining="ChainingModeCBC" hashAlgorithm="SHA512" saltValue="%s"/>
+<keyEncryptors><keyEncryptor uri="http://schemas.microsoft.com/office/2006/keyEncryptor/password">
+<p:encryptedKey spinCount="%d" saltSize="16" blockSize="16" keyBits="256" hashSize="64" cipherAlgorithm="AES" cipherChaining="ChainingModeCBC" hashAlgorithm="SHA512" saltValue="%s" encryptedVerifierHashInput="%s" encryptedVerifierHashValue="%s" encryptedKeyValue="%s"/>
+</keyEncryptor></keyEncryptors></encryption>`,
+		b64(keySalt), spin, b64(pwSalt),
+		b64(testAESCBC(derive(agileBlockVerifierInput), pwSalt, verifier)),
+		b64(testAESCBC(derive(agileBlockVerifierHash), pwSalt, vh[:])),
+		b64(testAESCBC(derive(agileBlockKeyValue), pwSalt, secret)))
+	info = append([]byte{4, 0, 4, 0, 0x40, 0, 0, 0}, xmlInfo...)
+
+	encPkg = make([]byte, 8)
+	binary.LittleEndian.PutUint64(encPkg, uint64(len(pkg)))
+	for off, n := 0, uint32(0); off < len(pkg); off, n = off+4096, n+1 {
+		var idx [4]byte
+		binary.LittleEndian.PutUint32(idx[:], n)
+		iv := sha512.Sum512(append(append([]byte{}, keySalt...), idx[:]...))
+		encPkg = append(encPkg, testAESCBC(secret, iv[:16], pkg[off:minInt(off+4096, len(pkg))])...)
+	}
+	return info, encPkg
+}
+
+// encryptStandard 按 Standard（AES-128 + SHA-1，ECB）加密 pkg。
+func encryptStandard(password string, pkg []byte) (info, encPkg []byte) {
+	s := &standardEncryption{keyBits: 128, salt: bytes.Repeat([]byte{5}, 16)}
+	key := s.deriveKey(password)
+	verifier := bytes.Repeat([]byte{6}, 16)
+	vh := sha1.Sum(verifier)
+	vhPadded := append(vh[:], make([]byte, 12)...)
+
+	le := func(v uint32) []byte {
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], v)
+		return b[:]
+	}
+	info = append(info, 4, 0, 2, 0)
+	info = append(info, le(0x24)...)
+	info = append(info, le(32)...)
+	for _, v := range []uint32{0x24, 0, 0x660E, 0x8004, 128, 0x18, 0, 0} {
+		info = append(info, le(v)...)
+	}
+	info = append(info, le(16)...)
+	info = append(info, s.salt...)
+	info = append(info, testAESECB(key, verifier)...)
+	info = append(info, le(20)...)
+	info = append(info, testAESECB(key, vhPadded)...)
+
+	padded := append(append([]byte{}, pkg...), make([]byte, (16-len(pkg)%16)%16)...)
+	encPkg = make([]byte, 8)
+	binary.LittleEndian.PutUint64(encPkg, uint64(len(pkg)))
+	encPkg = append(encPkg, testAESECB(key, padded)...)
+	return info, encPkg
+}
+
+func TestOOXMLDecrypt_AgileAndStandard(t *testing.T) {
+	dir := t.TempDir()
+	for name, enc := range map[string]func(string, []byte) ([]byte, []byte){
+		"agile.docx":    encryptAgile,
+		"standard.docx": encryptStandard,
+	} {
+		info, pkg := enc("hr-2024", testDocx(t, "工资表 合同编号A-001"))
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, buildCFB(t, map[string][]byte{"EncryptionInfo": info, "EncryptedPackage": pkg}), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Setenv("OFIND_PASSWORDS", "wrong;hr-2024")
+		ctx, trace := WithTrace(context.Background())
+		found, snip, err := FileFindFirst(ctx, path, "A-001", 2)
+		if err != nil || !found || !strings.Contains(snip, "【A-001】") || !trace.Encrypted || trace.Format != "ooxml" {
+			t.Fatalf("%s: found=%v snip=%q err=%v trace=%+v", name, found, snip, err, trace)
+		}
+
+		t.Setenv("OFIND_PASSWORDS", "wrong")
+		if _, _, err := FileFindFirst(context.Background(), path, "A-001", 2); !errors.Is(err, ErrEncrypted) {
+			t.Fatalf("%s: err = %v, want ErrEncrypted", name, err)
+		}
+	}
+}
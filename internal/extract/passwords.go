@@ -0,0 +1,90 @@
+package extract
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+)
+
+// 加密文档：很多厂商 PDF 只是用空用户口令“加密”以限制打印，可直接打开；
+// 其余情况按口令列表依次尝试。口令来源：查询指定（WithPasswords）> OFIND_PASSWORDS_FILE（每行一个）+ OFIND_PASSWORDS（以 ; 分隔）。
+
+// ErrEncrypted 表示文件已加密，且空口令与口令列表都无法打开。
+var ErrEncrypted = errors.New("文件已加密（空口令与口令列表均无法打开）")
+
+type passwordsKey struct{}
+
+// WithPasswords 为单次查询指定口令列表，优先于环境变量配置。
+func WithPasswords(ctx context.Context, passwords []string) context.Context {
+	if len(passwords) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, passwordsKey{}, passwords)
+}
+
+// passwordsFor 返回要尝试的非空口令（去重，保持顺序）；空口令总是由各后端先行尝试。
+func passwordsFor(ctx context.Context) []string {
+	if pws, ok := ctx.Value(passwordsKey{}).([]string); ok {
+		return dedupPasswords(pws)
+	}
+	pws := append([]string(nil), passwordsFromFile()...)
+	if v := os.Getenv("OFIND_PASSWORDS"); v != "" {
+		pws = append(pws, strings.Split(v, ";")...)
+	}
+	return dedupPasswords(pws)
+}
+
+var (
+	passwordsFileOnce sync.Once
+	passwordsFileList []string
+)
+
+// passwordsFromFile 返回 OFIND_PASSWORDS_FILE 中的口令；文件在进程内只读一次，不随每个文件重读。
+func passwordsFromFile() []string {
+	passwordsFileOnce.Do(func() {
+		p := strings.TrimSpace(os.Getenv("OFIND_PASSWORDS_FILE"))
+		if p == "" {
+			return
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			passwordsFileList = append(passwordsFileList, strings.TrimRight(s.Text(), "\r"))
+		}
+	})
+	return passwordsFileList
+}
+
+func dedupPasswords(in []string) []string {
+	out := make([]string, 0, len(in))
+	for _, pw := range in {
+		if pw == "" || containsPassword(out, pw) {
+			continue
+		}
+		out = append(out, pw)
+	}
+	return out
+}
+
+func containsPassword(list []string, pw string) bool {
+	for _, p := range list {
+		if p == pw {
+			return true
+		}
+	}
+	return false
+}
+
+// traceEncrypted 记录文件是加密的（但已成功用空口令或口令列表打开）。
+func traceEncrypted(ctx context.Context) {
+	if t := traceFrom(ctx); t != nil {
+		t.Encrypted = true
+	}
+}
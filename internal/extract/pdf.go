@@ -1,34 +1,23 @@
 package extract
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
-	"time"
 
 	"github.com/ledongthuc/pdf"
 )
 
-var errTooManyPages = errors.New("PDF 页数超过上限")
-
 var (
 	pdfHasIFilter     bool
 	pdfHasIFilterOnce sync.Once
-
-	// PDF并发控制
-	pdfConcurrentLimit     int
-	pdfConcurrentLimitOnce sync.Once
-	pdfSemaphore           chan struct{}
-	pdfSemaphoreOnce       sync.Once
-	activePDFTasks         int32
-	pdfMemoryLimitBytes    int64
-	pdfMemoryLimitOnce     sync.Once
 )
 
 func pdfPageWorkers() int {
@@ -63,8 +52,8 @@ func pdfMaxFileBytes() int64 {
 }
 
 func pdfMaxPages() int {
-	// PDF 页数限制，避免处理超大 PDF 时内存暴涨。
-	// 默认限制为 100 页，可通过环境变量调整。
+	// 纯 Go 后端默认最多处理的页数，避免处理超大 PDF 时内存暴涨；超出部分截断（见 pdfPureGoSpan）。
+	// 默认 100 页，可通过环境变量调整。
 	const def = 100
 	v := strings.TrimSpace(os.Getenv("OFIND_PDF_MAX_PAGES"))
 	if v == "" {
@@ -77,141 +66,6 @@ func pdfMaxPages() int {
 	return n
 }
 
-func checkPdfPages(r *pdf.Reader) error {
-	// 检查PDF页数是否超过限制
-	if r.NumPage() > pdfMaxPages() {
-		return errTooManyPages
-	}
-	return nil
-}
-
-// pdfConcurrentLimitValue 返回同时处理的PDF文件数量上限
-func pdfConcurrentLimitValue() int {
-	pdfConcurrentLimitOnce.Do(func() {
-		const def = 2 // 默认同时处理2个PDF文件
-		v := strings.TrimSpace(os.Getenv("OFIND_PDF_CONCURRENT_LIMIT"))
-		if v == "" {
-			pdfConcurrentLimit = def
-			return
-		}
-		if n, err := strconv.Atoi(v); err == nil && n > 0 {
-			if n > 10 {
-				n = 10
-			}
-			pdfConcurrentLimit = n
-		} else {
-			pdfConcurrentLimit = def
-		}
-	})
-	return pdfConcurrentLimit
-}
-
-// pdfMemoryLimitValue 返回PDF处理的内存上限（字节）
-func pdfMemoryLimitValue() int64 {
-	pdfMemoryLimitOnce.Do(func() {
-		const def = int64(2 * 1024 * 1024 * 1024) // 默认2GB
-		v := strings.TrimSpace(os.Getenv("OFIND_PDF_MEMORY_LIMIT_MB"))
-		if v == "" {
-			pdfMemoryLimitBytes = def
-			return
-		}
-		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
-			if n > 16384 { // 最大16GB
-				n = 16384
-			}
-			pdfMemoryLimitBytes = n * 1024 * 1024
-		} else {
-			pdfMemoryLimitBytes = def
-		}
-	})
-	return pdfMemoryLimitBytes
-}
-
-// isMemoryHigh 检查内存使用是否超过阈值
-func isMemoryHigh() bool {
-	limit := pdfMemoryLimitValue()
-	if limit <= 0 {
-		return false
-	}
-
-	var m runtime.MemStats
-	runtime.ReadMemStats(&m)
-	return m.Alloc > uint64(limit)
-}
-
-// getPDFSemaphore 返回全局PDF处理信号量
-func getPDFSemaphore() chan struct{} {
-	pdfSemaphoreOnce.Do(func() {
-		limit := pdfConcurrentLimitValue()
-		pdfSemaphore = make(chan struct{}, limit)
-		// 预填充信号量
-		for i := 0; i < limit; i++ {
-			pdfSemaphore <- struct{}{}
-		}
-	})
-	return pdfSemaphore
-}
-
-// acquirePDFSlot 获取PDF处理槽位
-func acquirePDFSlot(ctx context.Context) error {
-	// 检查内存使用
-	if isMemoryHigh() {
-		// 内存使用高，等待一段时间或返回错误
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(100 * time.Millisecond):
-			// 短暂等待后重试
-			if isMemoryHigh() {
-				return errors.New("内存使用过高，暂停PDF处理")
-			}
-		}
-	}
-
-	sem := getPDFSemaphore()
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-sem:
-		atomic.AddInt32(&activePDFTasks, 1)
-		return nil
-	}
-}
-
-// releasePDFSlot 释放PDF处理槽位
-func releasePDFSlot() {
-	atomic.AddInt32(&activePDFTasks, -1)
-	getPDFSemaphore() <- struct{}{}
-}
-
-// pdfOpenWithLimit 带并发限制的PDF打开函数
-func pdfOpenWithLimit(ctx context.Context, path string) (*os.File, *pdf.Reader, error) {
-	if err := acquirePDFSlot(ctx); err != nil {
-		return nil, nil, err
-	}
-	pdfMemHook("pdf:slot:acquired", path)
-
-	f, r, err := pdfOpen(path)
-	if err != nil {
-		releasePDFSlot()
-		return nil, nil, err
-	}
-	pdfMemHook("pdf:purego:NewReader_ok", path)
-
-	// 返回原始文件，但调用者需要在defer中调用releasePDFSlotOnClose
-	// 注意：调用者必须确保在文件关闭后调用releasePDFSlot()
-	// 我们在这里不自动处理，因为defer的顺序很重要
-	return f, r, nil
-}
-
-// releasePDFSlotOnClose 返回一个函数，在调用时释放PDF槽位
-// 应该在defer中使用：defer releasePDFSlotOnClose()()
-func releasePDFSlotOnClose() func() {
-	return func() {
-		releasePDFSlot()
-	}
-}
-
 func pdfPureGoFallbackEnabled() bool {
 	// 默认策略：
 	// - Windows：PDF 依赖系统 IFilter（README 说明）。纯 Go fallback 在部分 PDF 上可能导致严重内存/CPU 暴涨，
@@ -241,7 +95,44 @@ func pdfPureGoFallbackEnabled() bool {
 	return !pdfHasIFilter
 }
 
-func pdfOpen(path string) (*os.File, *pdf.Reader, error) {
+// pdfTrailerScanBytes 为判断加密时在文件头尾各读取的字节数：trailer（或交叉引用流字典）在文件末尾，
+// 线性化文件的首页 trailer 在文件开头。
+const pdfTrailerScanBytes = 64 << 10
+
+// pdfTrailerEncrypted 判断 PDF 的 trailer 是否含 /Encrypt，不依赖解析库的错误文本。
+func pdfTrailerEncrypted(r io.ReaderAt, size int64) bool {
+	n := int64(pdfTrailerScanBytes)
+	if n > size {
+		n = size
+	}
+	buf := make([]byte, n)
+	for _, off := range []int64{size - n, 0} {
+		if k, _ := r.ReadAt(buf, off); bytes.Contains(buf[:k], []byte("/Encrypt")) {
+			return true
+		}
+		if size <= n {
+			break
+		}
+	}
+	return false
+}
+
+// pdfFileEncrypted 为按路径的 pdfTrailerEncrypted；无法读取时返回 false。
+func pdfFileEncrypted(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return pdfTrailerEncrypted(f, fi.Size())
+}
+
+// pdfOpen 打开 PDF；加密文件先试空口令，再依次尝试口令列表，仍打不开时返回 ErrEncrypted。
+func pdfOpen(ctx context.Context, path string) (*os.File, *pdf.Reader, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, nil, err
@@ -251,35 +142,37 @@ func pdfOpen(path string) (*os.File, *pdf.Reader, error) {
 		_ = f.Close()
 		return nil, nil, err
 	}
-	r, err := pdf.NewReader(f, fi.Size())
+	pws := passwordsFor(ctx)
+	r, err := pdf.NewReaderEncrypted(f, fi.Size(), func() string {
+		if len(pws) == 0 {
+			return "" // 空串表示停止尝试
+		}
+		pw := pws[0]
+		pws = pws[1:]
+		return pw
+	})
 	if err != nil {
+		// 口令都不对，或加密方式不受支持（如 AES-256，ledongthuc/pdf 只返回文本错误）：按 trailer 判断是否加密
+		encrypted := err == pdf.ErrInvalidPassword || pdfTrailerEncrypted(f, fi.Size())
 		_ = f.Close()
-		return nil, nil, err
+		if encrypted {
+			return nil, nil, fmt.Errorf("%w: %v", ErrEncrypted, err)
+		}
+		return nil, nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	if r.Trailer().Key("Encrypt").Kind() != pdf.Null {
+		traceEncrypted(ctx)
 	}
 	return f, r, nil
 }
 
-func pdfFindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+// pdfPureGoFindFirst 为纯 Go 后端（ledongthuc/pdf）：按页流式匹配。
+func pdfPureGoFindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
 	q := stringsTrimSpace(query)
 	if q == "" {
 		return false, "", errors.New("query 为空")
 	}
 
-	// Windows 优先 IFilter（更节省内存，且支持真正的流式 chunk）。
-	if runtime.GOOS == "windows" {
-		pdfMemHook("pdf:IFilter:try", path)
-		found, snip, err := ifilterFindFirst(ctx, path, q, contextLen)
-		if err == nil {
-			pdfMemHook("pdf:IFilter:ok", path)
-			return found, snip, nil
-		}
-		// 未勾选内置 PDF：IFilter 失败后优先 Poppler pdftotext 子进程。
-		if !pdfPureGoFallbackEnabled() {
-			pdfMemHook("pdf:pdftotext:findFirst", path)
-			return pdftotextFindFirst(ctx, path, q, contextLen)
-		}
-	}
-
 	// 注意：pdf.Open 可能比较耗时，应关注 ctx 是否已取消
 	if ctx.Err() != nil {
 		return false, "", ctx.Err()
@@ -288,58 +181,29 @@ func pdfFindFirst(ctx context.Context, path string, query string, contextLen int
 	// 纯 Go fallback：对大文件做上限保护，避免极端内存暴涨。
 	if st, err := os.Stat(path); err == nil {
 		if st.Size() > pdfMaxFileBytes() {
-			return false, "", errTooLarge
+			return false, "", ErrTooLarge
 		}
 	}
 
 	pdfMemHook("pdf:purego:findFirst_begin", path)
-	f, r, err := pdfOpenWithLimit(ctx, path)
+	f, r, err := pdfOpen(ctx, path)
 	if err != nil {
 		return false, "", err
 	}
 	defer func() { pdfMemHook("pdf:purego:findFirst_exit", path) }()
-	defer releasePDFSlotOnClose()()
 	defer f.Close()
 
 	if ctx.Err() != nil {
 		return false, "", ctx.Err()
 	}
 
-	if err := checkPdfPages(r); err != nil {
-		return false, "", err
-	}
-	pages := r.NumPage()
-	pdfMemHook("pdf:purego:findFirst_pages="+strconv.Itoa(pages), path)
-	fonts := make(map[string]*pdf.Font)
-	nextPage := 1
-	next := func(ctx context.Context) (string, error) {
-		if nextPage > pages {
-			return "", io.EOF
-		}
-		if ctx.Err() != nil {
-			return "", ctx.Err()
-		}
-		p := r.Page(nextPage)
-		curPage := nextPage
-		nextPage++
-		for _, name := range p.Fonts() {
-			if _, ok := fonts[name]; ok {
-				continue
-			}
-			f := p.Font(name)
-			fonts[name] = &f
-		}
-		text, err := p.GetPlainText(fonts)
-		if err == nil {
-			pdfMemHookPage(path, curPage, len(text))
-		}
-		return text, err
-	}
-	return streamFindFirst(ctx, next, q, contextLen)
+	first, last := pdfPureGoSpan(ctx, r)
+	pdfMemHook("pdf:purego:findFirst_pages="+strconv.Itoa(r.NumPage()), path)
+	return streamFindFirst(ctx, newPdfPureGoPages(path, r, first, last).Next, q, contextLen)
 }
 
-// pdfFindSnippetsStream collects up to maxSnippets snippets without extracting the full text.
-func pdfFindSnippetsStream(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
+// pdfPureGoFindSnippets collects up to maxSnippets snippets without extracting the full text.
+func pdfPureGoFindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
 	q := stringsTrimSpace(query)
 	if q == "" {
 		return nil, errors.New("query 为空")
@@ -348,139 +212,79 @@ func pdfFindSnippetsStream(ctx context.Context, path string, query string, conte
 		maxSnippets = 1
 	}
 
-	// Windows 优先 IFilter：更节省内存，且流式返回 chunk。
-	if runtime.GOOS == "windows" {
-		pdfMemHook("pdf:IFilter:try_snippets", path)
-		snips, err := ifilterFindSnippets(ctx, path, q, contextLen, maxSnippets)
-		if err == nil {
-			pdfMemHook("pdf:IFilter:ok_snippets", path)
-			return snips, nil
-		}
-		if !pdfPureGoFallbackEnabled() {
-			pdfMemHook("pdf:pdftotext:snippets", path)
-			return pdftotextFindSnippets(ctx, path, q, contextLen, maxSnippets)
-		}
-	}
-
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
 
 	if st, err := os.Stat(path); err == nil {
 		if st.Size() > pdfMaxFileBytes() {
-			return nil, errTooLarge
+			return nil, ErrTooLarge
 		}
 	}
 
 	pdfMemHook("pdf:purego:snippets_begin", path)
-	f, r, err := pdfOpenWithLimit(ctx, path)
+	f, r, err := pdfOpen(ctx, path)
 	if err != nil {
 		return nil, err
 	}
 	defer func() { pdfMemHook("pdf:purego:snippets_exit", path) }()
-	defer releasePDFSlotOnClose()()
 	defer f.Close()
 
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
 
-	if err := checkPdfPages(r); err != nil {
-		return nil, err
-	}
-	pages := r.NumPage()
-	pdfMemHook("pdf:purego:snippets_pages="+strconv.Itoa(pages), path)
-	fonts := make(map[string]*pdf.Font)
-	nextPage := 1
-	next := func(ctx context.Context) (string, error) {
-		if nextPage > pages {
-			return "", io.EOF
-		}
-		if ctx.Err() != nil {
-			return "", ctx.Err()
-		}
-		p := r.Page(nextPage)
-		curPage := nextPage
-		nextPage++
-		for _, name := range p.Fonts() {
-			if _, ok := fonts[name]; ok {
-				continue
-			}
-			f := p.Font(name)
-			fonts[name] = &f
-		}
-		text, err := p.GetPlainText(fonts)
-		if err == nil {
-			pdfMemHookPage(path, curPage, len(text))
-		}
-		return text, err
-	}
-	return streamFindSnippets(ctx, next, q, contextLen, maxSnippets)
+	first, last := pdfPureGoSpan(ctx, r)
+	pdfMemHook("pdf:purego:snippets_pages="+strconv.Itoa(r.NumPage()), path)
+	return streamFindSnippets(ctx, newPdfPureGoPages(path, r, first, last).Next, q, contextLen, maxSnippets)
 }
 
 // PDFFindSnippetsStream is an exported wrapper for streaming PDF snippet search.
 func PDFFindSnippetsStream(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
-	return pdfFindSnippetsStream(ctx, path, query, contextLen, maxSnippets)
+	return Default.FindSnippets(ctx, path, query, contextLen, maxSnippets)
 }
 
-func pdfExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
+func pdfPureGoExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
 	if ctx.Err() != nil {
 		return "", ctx.Err()
 	}
 	maxBytes = maxBytesOrDefault(maxBytes)
 
-	// Windows 优先 IFilter：避免纯 Go PDF 解析导致的内存暴涨。
-	if runtime.GOOS == "windows" {
-		pdfMemHook("pdf:IFilter:try_extract", path)
-		if text, err := ifilterExtractText(ctx, path, maxBytes); err == nil {
-			pdfMemHook("pdf:IFilter:ok_extract", path)
-			return text, nil
-		}
-		if !pdfPureGoFallbackEnabled() {
-			pdfMemHook("pdf:pdftotext:extract", path)
-			return pdftotextExtractText(ctx, path, maxBytes)
-		}
-	}
-
 	// 纯 Go fallback：对大文件做上限保护，避免极端内存暴涨。
 	if st, err := os.Stat(path); err == nil {
 		if st.Size() > pdfMaxFileBytes() {
-			return "", errTooLarge
+			return "", ErrTooLarge
 		}
 	}
 
 	pdfMemHook("pdf:purego:extract_begin", path)
-	f, r, err := pdfOpenWithLimit(ctx, path)
+	f, r, err := pdfOpen(ctx, path)
 	if err != nil {
 		return "", err
 	}
 	defer func() { pdfMemHook("pdf:purego:extract_exit", path) }()
-	defer releasePDFSlotOnClose()()
 	defer f.Close()
 
 	if ctx.Err() != nil {
 		return "", ctx.Err()
 	}
 
+	first, last := pdfPureGoSpan(ctx, r)
 	workers := pdfPageWorkers()
 	if workers <= 1 {
-		return pdfExtractTextSequential(ctx, path, r, maxBytes)
+		return pdfExtractTextSequential(ctx, path, r, first, last, maxBytes)
 	}
 	pdfMemHook("pdf:purego:extract_parallel_workers="+strconv.Itoa(workers), path)
-	return pdfExtractTextParallel(ctx, path, r, maxBytes, workers)
+	return pdfExtractTextParallel(ctx, path, r, first, last, maxBytes, workers)
 }
 
-func pdfExtractTextSequential(ctx context.Context, path string, r *pdf.Reader, maxBytes int64) (string, error) {
+func pdfExtractTextSequential(ctx context.Context, path string, r *pdf.Reader, first, last int, maxBytes int64) (string, error) {
 	var sb strings.Builder
 	var approx int64
 
-	if err := checkPdfPages(r); err != nil {
-		return "", err
-	}
-	pages := r.NumPage()
-	pdfMemHook("pdf:purego:extract_seq_pages="+strconv.Itoa(pages), path)
+	pdfMemHook("pdf:purego:extract_seq_pages="+strconv.Itoa(last-first+1), path)
 	fonts := make(map[string]*pdf.Font)
-	for i := 1; i <= pages; i++ {
+	for i := first; i <= last; i++ {
 		if ctx.Err() != nil {
 			return "", ctx.Err()
 		}
@@ -508,6 +312,9 @@ func pdfExtractTextSequential(ctx context.Context, path string, r *pdf.Reader, m
 		if int64(len(text)) > remaining {
 			text = text[:remaining]
 		}
+		if sb.Len() > 0 {
+			sb.WriteByte('\n') // 页间分隔，命中不跨页
+		}
 		sb.WriteString(text)
 		approx += int64(len(text))
 		if approx >= maxBytes {
@@ -517,19 +324,15 @@ func pdfExtractTextSequential(ctx context.Context, path string, r *pdf.Reader, m
 	return sb.String(), nil
 }
 
-func pdfExtractTextParallel(ctx context.Context, path string, r *pdf.Reader, maxBytes int64, workers int) (string, error) {
+func pdfExtractTextParallel(ctx context.Context, path string, r *pdf.Reader, first, last int, maxBytes int64, workers int) (string, error) {
 	type pageResult struct {
 		page int
 		text string
 		err  error
 	}
 
-	if err := checkPdfPages(r); err != nil {
-		return "", err
-	}
-	pages := r.NumPage()
-	if pages <= 1 {
-		return pdfExtractTextSequential(ctx, path, r, maxBytes)
+	if last-first < 1 {
+		return pdfExtractTextSequential(ctx, path, r, first, last, maxBytes)
 	}
 
 	ctx, cancel := context.WithCancel(ctx)
@@ -541,7 +344,7 @@ func pdfExtractTextParallel(ctx context.Context, path string, r *pdf.Reader, max
 	// feed jobs
 	go func() {
 		defer close(jobs)
-		for i := 1; i <= pages; i++ {
+		for i := first; i <= last; i++ {
 			select {
 			case jobs <- i:
 			case <-ctx.Done():
@@ -592,7 +395,7 @@ func pdfExtractTextParallel(ctx context.Context, path string, r *pdf.Reader, max
 
 	var sb strings.Builder
 	var approx int64
-	nextPage := 1
+	nextPage := first
 	pending := make(map[int]string, workers*2)
 
 	for res := range results {
@@ -623,6 +426,9 @@ func pdfExtractTextParallel(ctx context.Context, path string, r *pdf.Reader, max
 				if int64(len(text)) > remaining {
 					text = text[:remaining]
 				}
+				if sb.Len() > 0 {
+					sb.WriteByte('\n') // 页间分隔，命中不跨页
+				}
 				sb.WriteString(text)
 				approx += int64(len(text))
 				if approx >= maxBytes {
@@ -630,7 +436,7 @@ func pdfExtractTextParallel(ctx context.Context, path string, r *pdf.Reader, max
 					return sb.String(), nil
 				}
 			}
-			if nextPage > pages {
+			if nextPage > last {
 				return sb.String(), nil
 			}
 		}
@@ -0,0 +1,220 @@
+package extract
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// PDF 后端链：ifilter（Windows 系统 IFilter）、pdftotext（Poppler 子进程）、purego（内置纯 Go）。
+// 顺序可按查询（WithPDFBackends）、按根目录（OFIND_PDF_BACKENDS_ROOTS）或全局（OFIND_PDF_BACKENDS）配置，
+// 例如 "pdftotext,purego"、"purego-only"、"all"；未配置时沿用原有默认顺序。
+
+const (
+	pdfBackendIFilter   = "ifilter"
+	pdfBackendPdftotext = "pdftotext"
+	pdfBackendPureGo    = "purego"
+)
+
+var pdfAllBackends = []string{pdfBackendIFilter, pdfBackendPdftotext, pdfBackendPureGo}
+
+type pdfBackendsKey struct{}
+
+// WithPDFBackends 为单次查询指定 PDF 后端链，优先于环境变量配置；spec 为空表示不覆盖。
+func WithPDFBackends(ctx context.Context, spec string) context.Context {
+	if strings.TrimSpace(spec) == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, pdfBackendsKey{}, spec)
+}
+
+// PDFBackendsFrom 返回 WithPDFBackends 为本次查询指定的后端链，未指定时为空。
+func PDFBackendsFrom(ctx context.Context) string {
+	spec, _ := ctx.Value(pdfBackendsKey{}).(string)
+	return spec
+}
+
+// ValidatePDFBackends 检查后端链配置是否合法，供命令行参数提前报错。
+func ValidatePDFBackends(spec string) error {
+	_, err := parsePDFBackends(spec)
+	return err
+}
+
+// parsePDFBackends 解析后端链；"" 与 "auto" 返回 nil 表示使用默认顺序。
+func parsePDFBackends(spec string) ([]string, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	switch spec {
+	case "", "auto":
+		return nil, nil
+	case "all":
+		return pdfAllBackends, nil
+	}
+	if name := strings.TrimSuffix(spec, "-only"); name != spec {
+		spec = name
+	}
+	var out []string
+	for _, name := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == ' ' || r == '>' }) {
+		known := false
+		for _, b := range pdfAllBackends {
+			if name == b {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("未知的 PDF 后端 %q（可选 ifilter/pdftotext/purego）", name)
+		}
+		dup := false
+		for _, b := range out {
+			if b == name {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			out = append(out, name)
+		}
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// pdfDefaultBackends 为未配置时的顺序：Windows 先 IFilter，再按 OFIND_PDF_PUREGO 选择纯 Go 或 pdftotext；
+// 其它平台没有 IFilter，先用系统安装的 pdftotext（省内存），找不到时回退纯 Go。
+func pdfDefaultBackends() []string {
+	if runtime.GOOS != "windows" {
+		return []string{pdfBackendPdftotext, pdfBackendPureGo}
+	}
+	if pdfPureGoFallbackEnabled() {
+		return []string{pdfBackendIFilter, pdfBackendPureGo}
+	}
+	return []string{pdfBackendIFilter, pdfBackendPdftotext}
+}
+
+// pdfBackendSpecFor 按“查询 > 根目录 > 全局”的优先级返回后端链配置。
+func pdfBackendSpecFor(ctx context.Context, path string) string {
+	if spec, ok := ctx.Value(pdfBackendsKey{}).(string); ok && strings.TrimSpace(spec) != "" {
+		return spec
+	}
+	if spec, ok := rootScopedEnv("OFIND_PDF_BACKENDS_ROOTS", path); ok {
+		return spec
+	}
+	return os.Getenv("OFIND_PDF_BACKENDS")
+}
+
+// pdfChain 返回 path 对应的 PDF 提取器链，作为 pdf 格式的 Format.ChainFor。
+func pdfChain(ctx context.Context, path string) ([]Extractor, error) {
+	names, err := parsePDFBackends(pdfBackendSpecFor(ctx, path))
+	if err != nil {
+		return nil, err
+	}
+	if names == nil {
+		names = pdfDefaultBackends()
+	}
+	chain := make([]Extractor, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case pdfBackendIFilter:
+			chain = append(chain, pdfIFilterBackend{})
+		case pdfBackendPdftotext:
+			chain = append(chain, pdftotextBackend{})
+		case pdfBackendPureGo:
+			chain = append(chain, pdfPureGoBackend{})
+		}
+	}
+	return chain, nil
+}
+
+type pdfIFilterBackend struct{}
+
+func (pdfIFilterBackend) Name() string { return pdfBackendIFilter }
+
+func (pdfIFilterBackend) FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	pdfMemHook("pdf:IFilter:try", path)
+	found, snip, err := ifilterFindFirst(ctx, path, query, contextLen)
+	if err == nil {
+		pdfMemHook("pdf:IFilter:ok", path)
+	}
+	return found, snip, err
+}
+
+func (pdfIFilterBackend) FindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
+	pdfMemHook("pdf:IFilter:try_snippets", path)
+	snips, err := ifilterFindSnippets(ctx, path, query, contextLen, maxSnippets)
+	if err == nil {
+		pdfMemHook("pdf:IFilter:ok_snippets", path)
+	}
+	return snips, err
+}
+
+func (pdfIFilterBackend) ExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
+	pdfMemHook("pdf:IFilter:try_extract", path)
+	text, err := ifilterExtractText(ctx, path, maxBytesOrDefault(maxBytes))
+	if err == nil {
+		pdfMemHook("pdf:IFilter:ok_extract", path)
+	}
+	return text, err
+}
+
+func (pdfIFilterBackend) Stream(ctx context.Context, path string) (TextStream, error) {
+	return ifilterOpenStream(ctx, path)
+}
+
+type pdftotextBackend struct{}
+
+func (pdftotextBackend) Name() string { return pdfBackendPdftotext }
+
+func (pdftotextBackend) FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	pdfMemHook("pdf:pdftotext:findFirst", path)
+	return pdftotextFindFirst(ctx, path, query, contextLen)
+}
+
+func (pdftotextBackend) FindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
+	pdfMemHook("pdf:pdftotext:snippets", path)
+	return pdftotextFindSnippets(ctx, path, query, contextLen, maxSnippets)
+}
+
+func (pdftotextBackend) ExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
+	pdfMemHook("pdf:pdftotext:extract", path)
+	return pdftotextExtractText(ctx, path, maxBytesOrDefault(maxBytes))
+}
+
+func (pdftotextBackend) Stream(ctx context.Context, path string) (TextStream, error) {
+	return pdftotextOpenPages(ctx, path)
+}
+
+type pdfPureGoBackend struct{}
+
+func (pdfPureGoBackend) Name() string { return pdfBackendPureGo }
+
+func (pdfPureGoBackend) FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	return pdfPureGoFindFirst(ctx, path, query, contextLen)
+}
+
+func (pdfPureGoBackend) FindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
+	return pdfPureGoFindSnippets(ctx, path, query, contextLen, maxSnippets)
+}
+
+func (pdfPureGoBackend) ExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
+	return pdfPureGoExtractText(ctx, path, maxBytes)
+}
+
+func (pdfPureGoBackend) Stream(ctx context.Context, path string) (TextStream, error) {
+	if st, err := os.Stat(path); err == nil {
+		if st.Size() > pdfMaxFileBytes() {
+			return nil, ErrTooLarge
+		}
+	}
+	f, r, err := pdfOpen(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	first, last := pdfPureGoSpan(ctx, r)
+	pages := newPdfPureGoPages(path, r, first, last)
+	pages.close = f.Close
+	return pages, nil
+}
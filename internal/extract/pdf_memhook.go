@@ -6,7 +6,6 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
-	"sync/atomic"
 )
 
 // PDF 内存监测（Go runtime.MemStats，不含 Windows IFilter 等 native 堆）。
@@ -45,15 +44,14 @@ func pdfMemHook(phase string, path string) {
 	if path != "" {
 		base = filepath.Base(path)
 	}
-	log.Printf("[pdf-mem] phase=%s file=%s | Alloc=%.2fMiB Sys=%.2fMiB HeapInuse=%.2fMiB HeapObjs=%d Mallocs=%d NumGC=%d | activePDF=%d",
+	log.Printf("[pdf-mem] phase=%s file=%s | Alloc=%.2fMiB Sys=%.2fMiB HeapInuse=%.2fMiB HeapObjs=%d Mallocs=%d NumGC=%d",
 		phase, base,
 		float64(m.Alloc)/(1024*1024),
 		float64(m.Sys)/(1024*1024),
 		float64(m.HeapInuse)/(1024*1024),
 		m.HeapObjects,
 		m.Mallocs,
-		m.NumGC,
-		atomic.LoadInt32(&activePDFTasks))
+		m.NumGC)
 }
 
 // pdfMemHookPage 每页 GetPlainText 之后（纯 Go 路径）。
@@ -64,9 +62,8 @@ func pdfMemHookPage(path string, page int, plainLen int) {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 	base := filepath.Base(path)
-	log.Printf("[pdf-mem] page file=%s page=%d plainLen=%d | Alloc=%.2fMiB HeapInuse=%.2fMiB | activePDF=%d",
+	log.Printf("[pdf-mem] page file=%s page=%d plainLen=%d | Alloc=%.2fMiB HeapInuse=%.2fMiB",
 		base, page, plainLen,
 		float64(m.Alloc)/(1024*1024),
-		float64(m.HeapInuse)/(1024*1024),
-		atomic.LoadInt32(&activePDFTasks))
+		float64(m.HeapInuse)/(1024*1024))
 }
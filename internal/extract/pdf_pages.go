@@ -0,0 +1,218 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// PageRange 为从 1 开始的闭区间页码范围；Last 为 0 表示到最后一页，零值表示全部页。
+type PageRange struct {
+	First int
+	Last  int
+}
+
+func (pr PageRange) IsZero() bool { return pr.First <= 0 && pr.Last <= 0 }
+
+func (pr PageRange) String() string {
+	if pr.IsZero() {
+		return ""
+	}
+	first := pr.First
+	if first <= 0 {
+		first = 1
+	}
+	if pr.Last <= 0 {
+		return strconv.Itoa(first) + "-"
+	}
+	return strconv.Itoa(first) + "-" + strconv.Itoa(pr.Last)
+}
+
+// clamp 将范围限制在 [1, numPages] 内；numPages<=0 表示页数未知（只处理 First）。
+func (pr PageRange) clamp(numPages int) (first, last int) {
+	first, last = pr.First, pr.Last
+	if first <= 0 {
+		first = 1
+	}
+	if numPages > 0 && (last <= 0 || last > numPages) {
+		last = numPages
+	}
+	return first, last
+}
+
+// ParsePageRange 解析 "1-5"、"3-"、"-5"（前 5 页）、"7"（仅第 7 页）；空串返回零值。
+func ParsePageRange(s string) (PageRange, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return PageRange{}, nil
+	}
+	bad := fmt.Errorf("无效的页码范围 %q（示例：1-5、3-、-5、7）", s)
+	a, b, isRange := strings.Cut(s, "-")
+	if !isRange {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return PageRange{}, bad
+		}
+		return PageRange{First: n, Last: n}, nil
+	}
+	var pr PageRange
+	if a = strings.TrimSpace(a); a != "" {
+		n, err := strconv.Atoi(a)
+		if err != nil || n <= 0 {
+			return PageRange{}, bad
+		}
+		pr.First = n
+	}
+	if b = strings.TrimSpace(b); b != "" {
+		n, err := strconv.Atoi(b)
+		if err != nil || n <= 0 {
+			return PageRange{}, bad
+		}
+		pr.Last = n
+	}
+	if pr.Last > 0 && pr.First > pr.Last {
+		return PageRange{}, bad
+	}
+	return pr, nil
+}
+
+type pdfPageRangeKey struct{}
+
+// WithPDFPageRange 为单次查询限制 PDF 处理的页码范围，优先于 OFIND_PDF_PAGES。
+func WithPDFPageRange(ctx context.Context, pr PageRange) context.Context {
+	if pr.IsZero() {
+		return ctx
+	}
+	return context.WithValue(ctx, pdfPageRangeKey{}, pr)
+}
+
+// pdfPageRangeFor 返回本次提取的页码范围：查询指定 > OFIND_PDF_PAGES；无效配置按全部页处理。
+func pdfPageRangeFor(ctx context.Context) PageRange {
+	if pr, ok := ctx.Value(pdfPageRangeKey{}).(PageRange); ok {
+		return pr
+	}
+	pr, _ := ParsePageRange(os.Getenv("OFIND_PDF_PAGES"))
+	return pr
+}
+
+// PageStream 为带页码的正文流：每段不跨页，NextPage 同时返回该段所在页码（从 1 开始）。
+// 同一页可能分多段返回。
+type PageStream interface {
+	TextStream
+	NextPage(ctx context.Context) (page int, text string, err error)
+}
+
+var errNoPageInfo = errors.New("该 PDF 后端不提供页码信息")
+
+// pageSeparated 供 PageStream 的 Next 使用：换页时在正文前加一个换行，使命中不会由前一页末尾与
+// 后一页开头拼接而成；同一页的后续分段原样返回。prev 记录上一段非空正文的页码。
+func pageSeparated(prev *int, page int, text string) string {
+	if text == "" {
+		return text
+	}
+	if *prev != 0 && page != *prev {
+		text = "\n" + text
+	}
+	*prev = page
+	return text
+}
+
+// OpenPDFPages 按 PDF 后端链打开带页码的正文流（IFilter 不提供页码，会被跳过）。
+// 页码范围取自 WithPDFPageRange / OFIND_PDF_PAGES。
+func OpenPDFPages(ctx context.Context, path string) (PageStream, error) {
+	chain, err := pdfChain(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	lastErr := errNoPageInfo
+	for _, ex := range chain {
+		s, err := ex.Stream(ctx, path)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = preferErr(lastErr, err)
+			continue
+		}
+		if ps, ok := s.(PageStream); ok {
+			traceBackend(ctx, ex)
+			return ps, nil
+		}
+		_ = s.Close()
+	}
+	return nil, lastErr
+}
+
+// pdfPureGoSpan 决定纯 Go 后端要处理的页：指定了范围时按范围；否则最多前 OFIND_PDF_MAX_PAGES 页，
+// 超出部分不再整份拒绝，而是截断并记录在 Trace.Truncated。
+func pdfPureGoSpan(ctx context.Context, r *pdf.Reader) (first, last int) {
+	pages := r.NumPage()
+	if pr := pdfPageRangeFor(ctx); !pr.IsZero() {
+		return pr.clamp(pages)
+	}
+	max := pdfMaxPages()
+	if pages > max {
+		traceTruncated(ctx, fmt.Sprintf("pages 1-%d/%d", max, pages))
+		return 1, max
+	}
+	return 1, pages
+}
+
+// pdfPureGoPages 按页返回纯 Go 解析出的文本，字体在页间复用。
+type pdfPureGoPages struct {
+	path  string
+	r     *pdf.Reader
+	fonts map[string]*pdf.Font
+	page  int
+	last  int
+	prev  int // 上一段非空正文的页码（见 pageSeparated）
+	close func() error
+}
+
+func newPdfPureGoPages(path string, r *pdf.Reader, first, last int) *pdfPureGoPages {
+	return &pdfPureGoPages{path: path, r: r, fonts: make(map[string]*pdf.Font), page: first, last: last}
+}
+
+func (p *pdfPureGoPages) NextPage(ctx context.Context) (int, string, error) {
+	if p.page > p.last {
+		return 0, "", io.EOF
+	}
+	if ctx.Err() != nil {
+		return 0, "", ctx.Err()
+	}
+	pg := p.r.Page(p.page)
+	cur := p.page
+	p.page++
+	for _, name := range pg.Fonts() {
+		if _, ok := p.fonts[name]; ok {
+			continue
+		}
+		f := pg.Font(name)
+		p.fonts[name] = &f
+	}
+	text, err := pg.GetPlainText(p.fonts)
+	if err == nil {
+		pdfMemHookPage(p.path, cur, len(text))
+	}
+	return cur, text, err
+}
+
+func (p *pdfPureGoPages) Next(ctx context.Context) (string, error) {
+	page, text, err := p.NextPage(ctx)
+	return pageSeparated(&p.prev, page, text), err
+}
+
+func (p *pdfPureGoPages) Close() error {
+	if p.close == nil {
+		return nil
+	}
+	c := p.close
+	p.close = nil
+	return c()
+}
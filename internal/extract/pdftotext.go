@@ -0,0 +1,422 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Poppler pdftotext 子进程后端：各平台共用查找、运行与输出上限逻辑；
+// Windows 额外支持嵌入的 pdftotext.exe 并隐藏子进程窗口（见 pdftotext_windows.go）。
+
+var (
+	errPdftotextNotFound = fmt.Errorf("%w: 未找到 pdftotext（请安装 Poppler（Linux 为 poppler-utils 包）或将 pdftotext 放在 exe 同目录，或设置 OFIND_PDFTOTEXT_PATH）", ErrBackendMissing)
+	errPdftotextDisabled = fmt.Errorf("%w: 已禁用 pdftotext（OFIND_PDFTOTEXT=0）", ErrBackendMissing)
+	errPdftotextRun      = errors.New("pdftotext 执行失败")
+)
+
+func pdftotextFeatureEnabled() bool {
+	v := strings.TrimSpace(os.Getenv("OFIND_PDFTOTEXT"))
+	if v == "" {
+		return true
+	}
+	if v == "0" || strings.EqualFold(v, "false") || strings.EqualFold(v, "off") {
+		return false
+	}
+	return true
+}
+
+// pdftotextPasswordsEnabled 表示是否把口令列表交给 pdftotext（OFIND_PDFTOTEXT_PASSWORDS=1）。
+// pdftotext 只能通过 -upw 命令行参数接收口令，同一台机器上的其他用户可在进程列表中看到，因此默认关闭：
+// 需要口令的 PDF 由链中后面的纯 Go 后端在本进程内尝试口令列表。
+func pdftotextPasswordsEnabled() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("OFIND_PDFTOTEXT_PASSWORDS"))) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+func pdftotextMaxOutBytes() int64 {
+	const def = 40 * 1024 * 1024
+	v := strings.TrimSpace(os.Getenv("OFIND_PDFTOTEXT_MAX_OUT_BYTES"))
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		return def
+	}
+	if n > 200*1024*1024 {
+		return 200 * 1024 * 1024
+	}
+	return n
+}
+
+// resolvePdftotextExe 查找 Poppler 的 pdftotext：OFIND_PDFTOTEXT_PATH → 嵌入包（仅 Windows）→ exe 同目录 → PATH。
+func resolvePdftotextExe() (string, error) {
+	if p := strings.TrimSpace(os.Getenv("OFIND_PDFTOTEXT_PATH")); p != "" {
+		if st, err := os.Stat(p); err == nil && !st.IsDir() {
+			return p, nil
+		}
+		return "", fmt.Errorf("OFIND_PDFTOTEXT_PATH 无效: %s", p)
+	}
+	// 嵌入在 ofind.exe 内的 Poppler（首次解压到用户缓存目录）
+	if dir, err := bundledPdftotextDir(); err == nil && dir != "" {
+		cand := filepath.Join(dir, pdftotextExeName)
+		if st, e := os.Stat(cand); e == nil && !st.IsDir() {
+			return cand, nil
+		}
+	}
+	exe, err := os.Executable()
+	if err == nil {
+		dir := filepath.Dir(exe)
+		cand := filepath.Join(dir, pdftotextExeName)
+		if st, e := os.Stat(cand); e == nil && !st.IsDir() {
+			return cand, nil
+		}
+	}
+	if p, err := exec.LookPath(pdftotextExeName); err == nil {
+		return p, nil
+	}
+	if p, err := exec.LookPath("pdftotext"); err == nil {
+		return p, nil
+	}
+	return "", errPdftotextNotFound
+}
+
+// pdftotextStream 将 pdftotext 的 stdout 直接接入分块匹配：命中后 Close 立即结束子进程，
+// 输出上限在读取时生效（达到上限即停止读取并结束子进程），不再整体缓冲全部输出。
+type pdftotextStream struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+	out    *capReader
+	next   nextStringChunkFunc
+	waited bool
+}
+
+// pdftotextStart 启动一个 pdftotext 进程（first/last>0 时通过 -f/-l 限定页，upw 非空时通过 -upw 传口令）。
+func pdftotextStart(ctx context.Context, exe string, abs string, first, last int, upw string) (*pdftotextStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pdfMemHook("pdf:pdftotext:run", abs)
+
+	args := []string{"-q"}
+	if first > 0 {
+		args = append(args, "-f", strconv.Itoa(first))
+	}
+	if last > 0 {
+		args = append(args, "-l", strconv.Itoa(last))
+	}
+	if upw != "" {
+		args = append(args, "-upw", upw)
+	}
+	args = append(args, abs, "-")
+
+	s := &pdftotextStream{}
+	s.cmd = exec.CommandContext(ctx, exe, args...)
+	s.cmd.Env = os.Environ()
+	s.cmd.Dir = filepath.Dir(exe)
+	s.cmd.Stderr = &s.stderr
+	pdftotextPrepareCmd(s.cmd)
+	var err error
+	s.stdout, err = s.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%w: %w: %w", errPdftotextRun, ErrBackendMissing, err)
+	}
+	s.out = &capReader{r: s.stdout, remaining: pdftotextMaxOutBytes()}
+	s.next = textChunkReader(s.out, charsetUTF8)
+	return s, nil
+}
+
+func (s *pdftotextStream) Next(ctx context.Context) (string, error) {
+	chunk, err := s.next(ctx)
+	if errors.Is(err, io.EOF) {
+		if werr := s.wait(); werr != nil {
+			return chunk, werr
+		}
+	}
+	return chunk, err
+}
+
+// wait 等待子进程退出；因达到输出上限而提前结束时先杀掉进程，且不视为错误。
+func (s *pdftotextStream) wait() error {
+	if s.waited {
+		return nil
+	}
+	s.waited = true
+	if s.out.capped {
+		_ = s.cmd.Process.Kill()
+		_ = s.cmd.Wait()
+		return nil
+	}
+	if err := s.cmd.Wait(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			// pdftotext 以非 0 退出（打不开/解析失败）多为文件本身的问题
+			return fmt.Errorf("%w: %w: %v", errPdftotextRun, ErrCorrupt, strings.TrimSpace(s.stderr.String()))
+		}
+		return fmt.Errorf("%w: %w", errPdftotextRun, err)
+	}
+	return nil
+}
+
+func (s *pdftotextStream) Close() error {
+	if s.waited {
+		return nil
+	}
+	s.waited = true
+	_ = s.cmd.Process.Kill()
+	_ = s.stdout.Close()
+	_ = s.cmd.Wait()
+	return nil
+}
+
+// capReader 读到 remaining 字节后返回 io.EOF，并记录是否因上限截断。
+type capReader struct {
+	r         io.Reader
+	remaining int64
+	capped    bool
+}
+
+func (c *capReader) Read(p []byte) (int, error) {
+	if c.remaining <= 0 {
+		c.capped = true
+		return 0, io.EOF
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	return n, err
+}
+
+// pdftotextPageWindow 为超大 PDF 分窗口处理时每个 pdftotext 进程处理的页数。
+func pdftotextPageWindow() int {
+	const def = 50
+	v := strings.TrimSpace(os.Getenv("OFIND_PDFTOTEXT_PAGE_WINDOW"))
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
+
+// pdftotextPages 按 '\f'（pdftotext 的分页符）切分输出，实现 PageStream。
+// 超过 OFIND_PDF_MAX_FILE_BYTES 的文件不再拒绝，而是以 -f/-l 每次处理 pdftotextPageWindow 页，
+// 依次启动新进程，直到某个窗口的页数不足（已到末页）或到达页码范围终点。
+type pdftotextPages struct {
+	exe, abs string
+	last     int // 0 表示到最后一页
+	window   int // 0 表示单个进程处理全部范围
+
+	cur      *pdftotextStream
+	pending  string
+	page     int // pending 开头所在页
+	winStart int
+	winPages int // 当前窗口已结束的页数
+	start    int
+	nextWin  int // >0 时表示当前进程结束后从该页开始下一个窗口
+	prev     int // 上一段非空正文的页码（见 pageSeparated）
+
+	upw       string   // 当前使用的用户口令（空表示空口令）
+	passwords []string // 尚未尝试的口令
+	encrypted bool     // trailer 含 /Encrypt
+}
+
+func pdftotextOpenPages(ctx context.Context, pdfPath string) (*pdftotextPages, error) {
+	if !pdftotextFeatureEnabled() {
+		return nil, errPdftotextDisabled
+	}
+	exe, err := resolvePdftotextExe()
+	if err != nil {
+		return nil, err
+	}
+	abs := pdfPath
+	if !filepath.IsAbs(pdfPath) {
+		if a, e := filepath.Abs(pdfPath); e == nil {
+			abs = a
+		}
+	}
+	pr := pdfPageRangeFor(ctx)
+	first, last := pr.clamp(0)
+	// 以 -q 运行时 Poppler 不输出任何错误信息，口令不对只表现为非 0 退出：事先按 trailer 判断是否加密
+	p := &pdftotextPages{exe: exe, abs: abs, last: last, start: first, encrypted: pdfFileEncrypted(abs)}
+	if p.encrypted && pdftotextPasswordsEnabled() {
+		p.passwords = passwordsFor(ctx)
+	}
+	if st, e := os.Stat(abs); e == nil && st.Size() > pdfMaxFileBytes() {
+		p.window = pdftotextPageWindow()
+	}
+	if err := p.startWindow(ctx, first); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *pdftotextPages) startWindow(ctx context.Context, first int) error {
+	last := p.last
+	if p.window > 0 {
+		last = first + p.window - 1
+		if p.last > 0 && last > p.last {
+			last = p.last
+		}
+	}
+	f := first
+	if f == 1 && p.window == 0 {
+		f = 0 // 从第 1 页开始时不必传 -f
+	}
+	s, err := pdftotextStart(ctx, p.exe, p.abs, f, last, p.upw)
+	if err != nil {
+		return err
+	}
+	p.cur, p.page, p.winStart, p.winPages, p.nextWin = s, first, first, 0, 0
+	return nil
+}
+
+func (p *pdftotextPages) NextPage(ctx context.Context) (int, string, error) {
+	for {
+		if p.pending != "" {
+			i := strings.IndexByte(p.pending, '\f')
+			if i < 0 {
+				text := p.pending
+				p.pending = ""
+				return p.page, text, nil
+			}
+			text, page := p.pending[:i], p.page
+			p.pending = p.pending[i+1:]
+			p.page++
+			p.winPages++
+			if text != "" {
+				return page, text, nil
+			}
+			continue
+		}
+		if p.cur == nil {
+			if p.nextWin > 0 {
+				if err := p.startWindow(ctx, p.nextWin); err != nil {
+					return 0, "", err
+				}
+				continue
+			}
+			return 0, "", io.EOF
+		}
+		chunk, err := p.cur.Next(ctx)
+		p.pending = chunk
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			// 加密文件在第一个窗口没有任何输出就失败：视为口令不对，换下一个口令从头重来。
+			if p.encrypted && p.page == p.start && p.winStart == p.start && chunk == "" && errors.Is(err, ErrCorrupt) {
+				_ = p.cur.Close()
+				p.cur = nil
+				if len(p.passwords) == 0 {
+					if !pdftotextPasswordsEnabled() {
+						return 0, "", fmt.Errorf("%w: pdftotext 只试空口令（口令列表需 OFIND_PDFTOTEXT_PASSWORDS=1）: %w", ErrEncrypted, err)
+					}
+					return 0, "", fmt.Errorf("%w: %w", ErrEncrypted, err)
+				}
+				p.upw, p.passwords = p.passwords[0], p.passwords[1:]
+				if err := p.startWindow(ctx, p.start); err != nil {
+					return 0, "", err
+				}
+				continue
+			}
+			// 页数恰为窗口整数倍时，下一个窗口的 -f 会越过末页而报错：视为结束。
+			if p.winStart > p.start && p.winPages == 0 && chunk == "" {
+				_ = p.cur.Close()
+				p.cur = nil
+				continue
+			}
+			return 0, "", err
+		}
+		if p.cur.out.capped {
+			traceTruncated(ctx, "pdftotext output cap")
+		}
+		if p.upw != "" {
+			traceEncrypted(ctx)
+		}
+		_ = p.cur.Close()
+		p.cur = nil
+		// pending 中最后一个 '\f' 之后的内容仍属于本窗口，先计数再决定是否继续。
+		p.winPages += strings.Count(p.pending, "\f")
+		if p.window > 0 && p.winPages >= p.window {
+			next := p.winStart + p.window
+			if p.last == 0 || next <= p.last {
+				p.nextWin = next
+			}
+		}
+		p.winPages -= strings.Count(p.pending, "\f")
+	}
+}
+
+func (p *pdftotextPages) Next(ctx context.Context) (string, error) {
+	page, text, err := p.NextPage(ctx)
+	return pageSeparated(&p.prev, page, text), err
+}
+
+func (p *pdftotextPages) Close() error {
+	if p.cur != nil {
+		_ = p.cur.Close()
+		p.cur = nil
+	}
+	p.nextWin = 0
+	return nil
+}
+
+func pdftotextFindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	q := stringsTrimSpace(query)
+	if q == "" {
+		return false, "", errors.New("query 为空")
+	}
+	s, err := pdftotextOpenPages(ctx, path)
+	if err != nil {
+		return false, "", err
+	}
+	defer s.Close()
+	return streamFindFirst(ctx, s.Next, q, contextLen)
+}
+
+func pdftotextFindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
+	q := stringsTrimSpace(query)
+	if q == "" {
+		return nil, errors.New("query 为空")
+	}
+	if maxSnippets <= 0 {
+		maxSnippets = 1
+	}
+	s, err := pdftotextOpenPages(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	return streamFindSnippets(ctx, s.Next, q, contextLen, maxSnippets)
+}
+
+func pdftotextExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
+	s, err := pdftotextOpenPages(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+	if maxBytes <= 0 {
+		maxBytes = pdftotextMaxOutBytes()
+	}
+	return collectChunks(ctx, s.Next, maxBytes)
+}
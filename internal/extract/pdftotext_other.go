@@ -0,0 +1,19 @@
+//go:build !windows
+
+package extract
+
+import (
+	"errors"
+	"os/exec"
+)
+
+const pdftotextExeName = "pdftotext"
+
+// 非 Windows 不嵌入 pdftotext，使用系统安装的 Poppler（如 poppler-utils）。
+func bundledPdftotextDir() (string, error) {
+	return "", errors.New("非 Windows 不提供内置 pdftotext")
+}
+
+func pdftotextPrepareCmd(cmd *exec.Cmd) {
+	_ = cmd
+}
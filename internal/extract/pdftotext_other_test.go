@@ -0,0 +1,213 @@
+//go:build !windows
+
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePdftotext 写一个假的 pdftotext 脚本：忽略参数，把 text 输出到 stdout，然后执行 tail（可为空）。
+func fakePdftotext(t *testing.T, text string, tail string) string {
+	t.Helper()
+	dir := t.TempDir()
+	textPath := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(textPath, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(dir, "pdftotext")
+	script := "#!/bin/sh\ncat '" + textPath + "'\n" + tail + "\n"
+	if err := os.WriteFile(exe, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return exe
+}
+
+func writeFakePDF(t *testing.T) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "a.pdf")
+	if err := os.WriteFile(p, []byte("%PDF-1.4\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPdftotextBackend_NonWindows(t *testing.T) {
+	t.Setenv("OFIND_PDFTOTEXT_PATH", fakePdftotext(t, "第一页\n合同编号：A-001\f第二页\n", ""))
+	pdfPath := writeFakePDF(t)
+
+	ctx, trace := WithTrace(WithPDFBackends(context.Background(), "pdftotext-only"))
+	found, snip, err := FileFindFirst(ctx, pdfPath, "A-001", 1)
+	if err != nil || !found || !strings.Contains(snip, "【A-001】") {
+		t.Fatalf("found=%v snip=%q err=%v", found, snip, err)
+	}
+	if trace.Backend != "pdftotext" {
+		t.Fatalf("backend = %q", trace.Backend)
+	}
+}
+
+func TestPdftotextBackend_NoMatchAcrossPages(t *testing.T) {
+	// 前一页末尾与后一页开头拼起来才构成的关键词不算命中
+	t.Setenv("OFIND_PDFTOTEXT_PATH", fakePdftotext(t, "合同编\f号：A-001\f", ""))
+	ctx := WithPDFBackends(context.Background(), "pdftotext-only")
+	if found, snip, err := FileFindFirst(ctx, writeFakePDF(t), "合同编号", 1); err != nil || found {
+		t.Fatalf("across pages: found=%v snip=%q err=%v", found, snip, err)
+	}
+	if found, _, err := FileFindFirst(ctx, writeFakePDF(t), "A-001", 1); err != nil || !found {
+		t.Fatalf("found=%v err=%v", found, err)
+	}
+}
+
+func TestPdftotextStream_StopsEarlyOnHit(t *testing.T) {
+	// 命中后子进程仍在“输出”（sleep）：应立即结束子进程返回，而不是等它退出。
+	t.Setenv("OFIND_PDFTOTEXT_PATH", fakePdftotext(t, "合同编号：A-001 后续内容\n", "exec sleep 30"))
+	start := time.Now()
+	found, _, err := pdftotextFindFirst(context.Background(), writeFakePDF(t), "A-001", 2)
+	if err != nil || !found {
+		t.Fatalf("found=%v err=%v", found, err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Fatalf("pdftotext was not killed early: %v", d)
+	}
+}
+
+func TestPdftotextStream_CapWhileReading(t *testing.T) {
+	// 无限输出：应在读到上限时停止并结束子进程。
+	t.Setenv("OFIND_PDFTOTEXT_PATH", fakePdftotext(t, "", "exec yes 合同"))
+	t.Setenv("OFIND_PDFTOTEXT_MAX_OUT_BYTES", "4096")
+	found, _, err := pdftotextFindFirst(context.Background(), writeFakePDF(t), "A-001", 2)
+	if err != nil || found {
+		t.Fatalf("found=%v err=%v", found, err)
+	}
+	text, err := pdftotextExtractText(context.Background(), writeFakePDF(t), 0)
+	if err != nil || len(text) > 4096 || !strings.HasPrefix(text, "合同\n") {
+		t.Fatalf("len=%d err=%v", len(text), err)
+	}
+}
+
+// fakePagedPdftotext 写一个按 -f/-l 输出 "p<页码>\f" 的假 pdftotext，共 pages 页；越过末页时按 Poppler 的方式报错。
+func fakePagedPdftotext(t *testing.T, pages int) string {
+	t.Helper()
+	exe := filepath.Join(t.TempDir(), "pdftotext")
+	script := `#!/bin/sh
+f=1; l=999999
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-f) f=$2; shift ;;
+	-l) l=$2; shift ;;
+	esac
+	shift
+done
+n=` + strconv.Itoa(pages) + `
+if [ $f -gt $n ]; then echo "Wrong page range given" >&2; exit 99; fi
+if [ $l -gt $n ]; then l=$n; fi
+i=$f
+while [ $i -le $l ]; do printf 'p%d\f' $i; i=$((i+1)); done
+`
+	if err := os.WriteFile(exe, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return exe
+}
+
+func collectPages(t *testing.T, ctx context.Context, path string) []string {
+	t.Helper()
+	ps, err := OpenPDFPages(WithPDFBackends(ctx, "pdftotext-only"), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+	var got []string
+	for {
+		page, text, err := ps.NextPage(ctx)
+		if text != "" {
+			got = append(got, fmt.Sprintf("%d:%s", page, text))
+		}
+		if err == io.EOF {
+			return got
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPdftotextPages_SplitsAtFormFeed(t *testing.T) {
+	t.Setenv("OFIND_PDFTOTEXT_PATH", fakePagedPdftotext(t, 4))
+	got := strings.Join(collectPages(t, context.Background(), writeFakePDF(t)), ",")
+	if got != "1:p1,2:p2,3:p3,4:p4" {
+		t.Fatalf("pages = %s", got)
+	}
+	ctx := WithPDFPageRange(context.Background(), PageRange{First: 2, Last: 3})
+	if got := strings.Join(collectPages(t, ctx, writeFakePDF(t)), ","); got != "2:p2,3:p3" {
+		t.Fatalf("range pages = %s", got)
+	}
+}
+
+func TestPdftotextPages_WindowsLargeFiles(t *testing.T) {
+	t.Setenv("OFIND_PDF_MAX_FILE_BYTES", "1")
+	t.Setenv("OFIND_PDFTOTEXT_PAGE_WINDOW", "3")
+	for _, n := range []int{7, 6} {
+		t.Setenv("OFIND_PDFTOTEXT_PATH", fakePagedPdftotext(t, n))
+		got := collectPages(t, context.Background(), writeFakePDF(t))
+		if len(got) != n || got[n-1] != fmt.Sprintf("%d:p%d", n, n) {
+			t.Fatalf("n=%d pages = %v", n, got)
+		}
+	}
+}
+
+func TestPdftotextPasswords(t *testing.T) {
+	// 只有 -upw secret 能打开；其余口令按 Poppler 的方式退出码为 1，-q 时不输出错误信息。
+	exe := filepath.Join(t.TempDir(), "pdftotext")
+	script := `#!/bin/sh
+pw=
+quiet=
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-upw) pw=$2; shift ;;
+	-q) quiet=1 ;;
+	esac
+	shift
+done
+if [ "$pw" != "secret" ]; then
+	[ -n "$quiet" ] || echo "Command Line Error: Incorrect password" >&2
+	exit 1
+fi
+printf '合同编号：A-001\f'
+`
+	if err := os.WriteFile(exe, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OFIND_PDFTOTEXT_PATH", exe)
+	pdfPath := filepath.Join(t.TempDir(), "enc.pdf")
+	if err := os.WriteFile(pdfPath, []byte("%PDF-1.4\ntrailer\n<< /Size 6 /Encrypt 5 0 R >>\n%%EOF\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("OFIND_PASSWORDS", "wrong;secret")
+	// 默认不经命令行（-upw）把口令交给 pdftotext
+	if _, _, err := FileFindFirst(WithPDFBackends(context.Background(), "pdftotext-only"), pdfPath, "A-001", 1); !errors.Is(err, ErrEncrypted) {
+		t.Fatalf("passwords disabled: err = %v, want ErrEncrypted", err)
+	}
+
+	t.Setenv("OFIND_PDFTOTEXT_PASSWORDS", "1")
+	ctx, trace := WithTrace(WithPDFBackends(context.Background(), "pdftotext-only"))
+	found, _, err := FileFindFirst(ctx, pdfPath, "A-001", 1)
+	if err != nil || !found || !trace.Encrypted {
+		t.Fatalf("found=%v encrypted=%v err=%v", found, trace.Encrypted, err)
+	}
+
+	t.Setenv("OFIND_PASSWORDS", "wrong")
+	_, _, err = FileFindFirst(WithPDFBackends(context.Background(), "pdftotext,purego"), pdfPath, "A-001", 1)
+	if !errors.Is(err, ErrEncrypted) {
+		t.Fatalf("err = %v, want ErrEncrypted", err)
+	}
+}
@@ -0,0 +1,406 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TextStream 为流式正文：Next 依次返回正文片段，结束时返回 io.EOF（可与最后一段同时返回）。
+type TextStream interface {
+	Next(ctx context.Context) (string, error)
+	Close() error
+}
+
+// Extractor 为一种格式（或同一格式的一个后端）的提取实现。
+type Extractor interface {
+	// Name 为后端名（如 "pdftotext"），记录在 Trace.Backend 中。
+	Name() string
+	FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error)
+	FindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error)
+	ExtractText(ctx context.Context, path string, maxBytes int64) (string, error)
+	Stream(ctx context.Context, path string) (TextStream, error)
+}
+
+// Format 描述一种文件格式。
+type Format struct {
+	// Name 与内容识别结果对应（text/ooxml/pdf/rtf/html/ifilter），同名注册会覆盖。
+	Name string
+	// Extensions 为小写、带点的扩展名，遍历器据此过滤文件。
+	Extensions []string
+	// TrustExtension 为 true 时不做内容校验（纯文本没有可靠的 magic bytes）。
+	TrustExtension bool
+	// Chain 按顺序尝试：前一个返回错误时回退到下一个。
+	Chain []Extractor
+	// ChainFor 非空时按查询/路径动态决定提取器链（如可配置的 PDF 后端），优先于 Chain。
+	ChainFor func(ctx context.Context, path string) ([]Extractor, error)
+}
+
+// Registry 维护扩展名 → 格式 → 提取器链的映射，是支持格式的唯一来源。
+type Registry struct {
+	mu      sync.RWMutex
+	formats map[string]*Format
+	byExt   map[string]*Format
+}
+
+func NewRegistry() *Registry {
+	return &Registry{formats: map[string]*Format{}, byExt: map[string]*Format{}}
+}
+
+// Default 为内置格式的注册表；FileFindFirst 等包级函数均基于它。
+var Default = newDefaultRegistry()
+
+func newDefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register(Format{
+		Name:           "text",
+		Extensions:     []string{".txt", ".md", ".log", ".csv", ".json", ".xml", ".ini", ".yaml", ".yml"},
+		TrustExtension: true,
+		Chain:          []Extractor{textExtractor{}},
+	})
+	// 含宏（.docm/.xlsm/.pptm）与模板变体同样是 OOXML；包内 vbaProject.bin 的宏源码也会被搜索
+	r.Register(Format{
+		Name:       "ooxml",
+		Extensions: []string{".docx", ".xlsx", ".pptx", ".vsdx", ".docm", ".xlsm", ".pptm", ".dotx", ".xltx", ".potx"},
+		Chain:      []Extractor{ooxmlExtractor{}},
+	})
+	r.Register(Format{
+		Name:       "pdf",
+		Extensions: []string{".pdf"},
+		ChainFor:   pdfChain,
+	})
+	r.Register(Format{
+		Name:       "rtf",
+		Extensions: []string{".rtf"},
+		Chain:      []Extractor{rtfExtractor{}},
+	})
+	r.Register(Format{
+		Name:       "html",
+		Extensions: []string{".htm", ".html"},
+		Chain:      []Extractor{htmlExtractor{}},
+	})
+	// .doc/.xls/.ppt 等：在 Windows 下用 IFilter；非 Windows 则返回不支持。.doc/.xls 中的宏源码另行搜索。
+	r.Register(Format{
+		Name:       "ifilter",
+		Extensions: []string{".doc", ".xls", ".ppt"},
+		Chain:      []Extractor{legacyOfficeExtractor{base: ifilterExtractor{}}},
+	})
+	return r
+}
+
+// Register 注册（或按 Name 覆盖）一种格式。
+func (r *Registry) Register(f Format) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.formats[f.Name]; ok {
+		for _, ext := range old.Extensions {
+			if r.byExt[ext] == old {
+				delete(r.byExt, ext)
+			}
+		}
+	}
+	nf := f
+	nf.Extensions = make([]string, 0, len(f.Extensions))
+	for _, ext := range f.Extensions {
+		ext = strings.ToLower(ext)
+		nf.Extensions = append(nf.Extensions, ext)
+		r.byExt[ext] = &nf
+	}
+	r.formats[f.Name] = &nf
+}
+
+// SupportsExt 判断扩展名（小写、带点）是否有已注册的格式。
+func (r *Registry) SupportsExt(ext string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.byExt[ext]
+	return ok
+}
+
+// ExtFormat 返回扩展名（小写、带点）注册的格式名，不读文件头；未注册时为空。
+func (r *Registry) ExtFormat(ext string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if f, ok := r.byExt[ext]; ok {
+		return f.Name
+	}
+	return ""
+}
+
+// Extensions 返回全部已注册扩展名（排序后）。
+func (r *Registry) Extensions() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]string, 0, len(r.byExt))
+	for ext := range r.byExt {
+		out = append(out, ext)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Lookup 按格式名返回格式描述。
+func (r *Registry) Lookup(name string) (Format, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	f, ok := r.formats[name]
+	if !ok {
+		return Format{}, false
+	}
+	return *f, true
+}
+
+// resolve 决定文件实际使用的格式。
+//   - TrustExtension 的格式（文本）：直接按扩展名。
+//   - 其它已注册扩展名：读文件头校验，内容与扩展名不符时以内容为准（如 .doc 实为 RTF/HTML）。
+//   - 未注册扩展名：SniffUnknownEnabled 时按内容识别（识别不出返回 nil）；
+//     否则保持旧行为交给 IFilter。
+func (r *Registry) resolve(path string) *Format {
+	return r.resolveSniff(path, SniffUnknownEnabled())
+}
+
+// resolveSniff 同 resolve；sniffUnknown 为 true 时未注册的扩展名按文件头识别，否则交给 IFilter。
+func (r *Registry) resolveSniff(path string, sniffUnknown bool) *Format {
+	ext := strings.ToLower(filepath.Ext(path))
+	r.mu.RLock()
+	byExt := r.byExt[ext]
+	r.mu.RUnlock()
+	if byExt != nil && byExt.TrustExtension {
+		return byExt
+	}
+	if byExt == nil && !sniffUnknown {
+		return r.formatByName(kindIFilter.String())
+	}
+	if kind, err := sniffFile(path); err == nil && kind != kindUnknown {
+		if f := r.formatByName(kind.String()); f != nil {
+			return f
+		}
+	}
+	return byExt
+}
+
+// ResolveFormat 返回文件实际使用的格式名（可能读文件头），无可用格式时为空。
+// 同一文件要多次提取（多个关键词、预算估算、沙箱路由）时先解析一次，再用 FindFirstAs 等传入。
+func (r *Registry) ResolveFormat(path string) string {
+	if f := r.resolve(path); f != nil {
+		return f.Name
+	}
+	return ""
+}
+
+// SniffFormat 同 ResolveFormat，但未注册的扩展名总是按文件头识别。
+func (r *Registry) SniffFormat(path string) string {
+	if f := r.resolveSniff(path, true); f != nil {
+		return f.Name
+	}
+	return ""
+}
+
+func (r *Registry) formatByName(name string) *Format {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.formats[name]
+}
+
+// chainFor 返回文件对应的提取器链；无可用格式时返回 ErrUnsupported。
+func (r *Registry) chainFor(ctx context.Context, path string) ([]Extractor, error) {
+	return r.chainForFormat(ctx, r.resolve(path), path)
+}
+
+// chainForFormat 返回已解析格式 f 的提取器链；f 为 nil 时返回 ErrUnsupported。
+func (r *Registry) chainForFormat(ctx context.Context, f *Format, path string) ([]Extractor, error) {
+	if f == nil {
+		return nil, ErrUnsupported
+	}
+	chain := f.Chain
+	if f.ChainFor != nil {
+		var err error
+		if chain, err = f.ChainFor(ctx, path); err != nil {
+			return nil, err
+		}
+	}
+	if len(chain) == 0 {
+		return nil, ErrUnsupported
+	}
+	if t := traceFrom(ctx); t != nil {
+		t.Format = f.Name
+	}
+	return chain, nil
+}
+
+func (r *Registry) FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	chain, err := r.chainFor(ctx, path)
+	if err != nil {
+		return false, "", err
+	}
+	return findFirstChain(ctx, chain, path, query, contextLen)
+}
+
+// FindFirstAs 与 FindFirst 相同，但使用已由 ResolveFormat 解析出的格式，不再读文件头；
+// format 为空或未注册时返回 ErrUnsupported。
+func (r *Registry) FindFirstAs(ctx context.Context, format string, path string, query string, contextLen int) (bool, string, error) {
+	chain, err := r.chainForFormat(ctx, r.formatByName(format), path)
+	if err != nil {
+		return false, "", err
+	}
+	return findFirstChain(ctx, chain, path, query, contextLen)
+}
+
+func findFirstChain(ctx context.Context, chain []Extractor, path string, query string, contextLen int) (bool, string, error) {
+	var lastErr error
+	for _, ex := range chain {
+		found, snip, err := ex.FindFirst(ctx, path, query, contextLen)
+		if err == nil {
+			traceBackend(ctx, ex)
+			return found, snip, nil
+		}
+		if ctx.Err() != nil {
+			return false, "", ctx.Err()
+		}
+		lastErr = preferErr(lastErr, err)
+	}
+	return false, "", lastErr
+}
+
+func (r *Registry) FindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
+	chain, err := r.chainFor(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, ex := range chain {
+		snips, err := ex.FindSnippets(ctx, path, query, contextLen, maxSnippets)
+		if err == nil {
+			traceBackend(ctx, ex)
+			return snips, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		lastErr = preferErr(lastErr, err)
+	}
+	return nil, lastErr
+}
+
+func (r *Registry) ExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
+	chain, err := r.chainFor(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	var lastErr error
+	for _, ex := range chain {
+		text, err := ex.ExtractText(ctx, path, maxBytes)
+		if err == nil {
+			traceBackend(ctx, ex)
+			return text, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		lastErr = preferErr(lastErr, err)
+	}
+	return "", lastErr
+}
+
+// Stream 返回链上第一个能成功打开的正文流；调用方负责 Close。
+func (r *Registry) Stream(ctx context.Context, path string) (TextStream, error) {
+	chain, err := r.chainFor(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, ex := range chain {
+		s, err := ex.Stream(ctx, path)
+		if err == nil {
+			traceBackend(ctx, ex)
+			return s, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		lastErr = preferErr(lastErr, err)
+	}
+	return nil, lastErr
+}
+
+// preferErr 决定整条链失败时报告哪个错误：ErrEncrypted 比其它后端的失败更能说明原因，保留它。
+func preferErr(lastErr, err error) error {
+	if errors.Is(lastErr, ErrEncrypted) && !errors.Is(err, ErrEncrypted) {
+		return lastErr
+	}
+	// “缺少后端”不如前面后端给出的具体原因（如文件损坏）有用
+	if lastErr != nil && errors.Is(err, ErrBackendMissing) {
+		return lastErr
+	}
+	return err
+}
+
+// Trace 记录一次提取实际使用的格式与后端，便于在结果中展示“正文由哪个后端产生”。
+type Trace struct {
+	Format  string
+	Backend string
+	// Truncated 非空时表示只处理了部分内容及原因（如 "pages 1-100/250"）
+	Truncated string
+	// Encrypted 表示文件是加密的，已用空口令或口令列表打开
+	Encrypted bool
+}
+
+type traceKey struct{}
+
+// WithTrace 返回携带 Trace 的 ctx；提取成功后 Format/Backend 会被填写。
+// 同一 Trace 不应被并发的提取共用。
+func WithTrace(ctx context.Context) (context.Context, *Trace) {
+	t := &Trace{}
+	return context.WithValue(ctx, traceKey{}, t), t
+}
+
+// TraceFrom 返回 ctx 携带的 Trace（没有时为 nil），供在其它进程中完成提取的调用方回填结果。
+func TraceFrom(ctx context.Context) *Trace {
+	return traceFrom(ctx)
+}
+
+func traceFrom(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+func traceBackend(ctx context.Context, ex Extractor) {
+	if t := traceFrom(ctx); t != nil {
+		t.Backend = ex.Name()
+	}
+}
+
+func traceTruncated(ctx context.Context, reason string) {
+	if t := traceFrom(ctx); t != nil {
+		if t.Truncated != "" {
+			t.Truncated += "; "
+		}
+		t.Truncated += reason
+	}
+}
+
+// funcStream 将 nextStringChunkFunc 与需要释放的资源组合成 TextStream。
+type funcStream struct {
+	next  nextStringChunkFunc
+	close func() error
+}
+
+func (s *funcStream) Next(ctx context.Context) (string, error) {
+	if s.next == nil {
+		return "", io.EOF
+	}
+	return s.next(ctx)
+}
+
+func (s *funcStream) Close() error {
+	if s.close == nil {
+		return nil
+	}
+	c := s.close
+	s.close = nil
+	return c()
+}
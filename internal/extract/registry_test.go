@@ -0,0 +1,170 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type failingExtractor struct{ textExtractor }
+
+var errFailingExtractor = errors.New("backend unavailable")
+
+func (failingExtractor) FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	return false, "", errFailingExtractor
+}
+
+func (failingExtractor) Stream(ctx context.Context, path string) (TextStream, error) {
+	return nil, errFailingExtractor
+}
+
+func TestRegistry_FallbackChainAndExtensions(t *testing.T) {
+	r := NewRegistry()
+	r.Register(Format{
+		Name:           "text",
+		Extensions:     []string{".NOTE"},
+		TrustExtension: true,
+		Chain:          []Extractor{failingExtractor{}, textExtractor{}},
+	})
+	if !r.SupportsExt(".note") || r.SupportsExt(".txt") {
+		t.Fatalf("unexpected extensions %v", r.Extensions())
+	}
+
+	p := filepath.Join(t.TempDir(), "a.note")
+	if err := os.WriteFile(p, []byte("合同编号：A-001"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	found, snip, err := r.FindFirst(context.Background(), p, "A-001", 1)
+	if err != nil || !found || snip != "：【A-001】" {
+		t.Fatalf("fallback FindFirst: found=%v snip=%q err=%v", found, snip, err)
+	}
+
+	s, err := r.Stream(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	text, err := s.Next(context.Background())
+	if err != nil && !errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+	if text != "合同编号：A-001" {
+		t.Fatalf("stream text %q", text)
+	}
+
+	// 同名重新注册会替换旧的扩展名
+	r.Register(Format{Name: "text", Extensions: []string{".txt"}, TrustExtension: true, Chain: []Extractor{textExtractor{}}})
+	if r.SupportsExt(".note") || !r.SupportsExt(".txt") {
+		t.Fatalf("re-register: %v", r.Extensions())
+	}
+}
+
+func TestPDFChain_Config(t *testing.T) {
+	names := func(ctx context.Context, path string) string {
+		chain, err := pdfChain(ctx, path)
+		if err != nil {
+			return "error: " + err.Error()
+		}
+		out := ""
+		for i, ex := range chain {
+			if i > 0 {
+				out += ","
+			}
+			out += ex.Name()
+		}
+		return out
+	}
+	root := t.TempDir()
+	scanned := filepath.Join(root, "scanned")
+	t.Setenv("OFIND_PDF_BACKENDS", "all")
+	t.Setenv("OFIND_PDF_BACKENDS_ROOTS", root+"=pdftotext-only;"+scanned+"=ifilter,purego")
+
+	ctx := context.Background()
+	if got := names(ctx, filepath.Join(t.TempDir(), "a.pdf")); got != "ifilter,pdftotext,purego" {
+		t.Errorf("global: got %q", got)
+	}
+	if got := names(ctx, filepath.Join(root, "a.pdf")); got != "pdftotext" {
+		t.Errorf("per root: got %q", got)
+	}
+	if got := names(ctx, filepath.Join(scanned, "a.pdf")); got != "ifilter,purego" {
+		t.Errorf("longest root: got %q", got)
+	}
+	if got := names(WithPDFBackends(ctx, "purego-only"), filepath.Join(root, "a.pdf")); got != "purego" {
+		t.Errorf("per query: got %q", got)
+	}
+	if err := ValidatePDFBackends("pdftotext,ghostscript"); err == nil {
+		t.Error("unknown backend should be rejected")
+	}
+}
+
+func TestRegistry_TraceRecordsBackend(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(p, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, trace := WithTrace(context.Background())
+	if _, _, err := FileFindFirst(ctx, p, "hello", 0); err != nil {
+		t.Fatal(err)
+	}
+	if trace.Format != "text" || trace.Backend != "text" {
+		t.Fatalf("trace = %+v", *trace)
+	}
+}
+
+func TestParsePageRange(t *testing.T) {
+	cases := map[string]PageRange{
+		"":    {},
+		"1-5": {First: 1, Last: 5},
+		"3-":  {First: 3},
+		"-5":  {Last: 5},
+		" 7 ": {First: 7, Last: 7},
+	}
+	for in, want := range cases {
+		got, err := ParsePageRange(in)
+		if err != nil || got != want {
+			t.Fatalf("ParsePageRange(%q) = %+v, %v", in, got, err)
+		}
+	}
+	for _, in := range []string{"0", "5-2", "a-b", "1-x"} {
+		if _, err := ParsePageRange(in); err == nil {
+			t.Fatalf("ParsePageRange(%q) should fail", in)
+		}
+	}
+}
+
+func TestRegistry_FindFirstAsUsesGivenFormat(t *testing.T) {
+	r := NewRegistry()
+	r.Register(Format{Name: "text", Extensions: []string{".txt"}, TrustExtension: true, Chain: []Extractor{textExtractor{}}})
+	// 扩展名不认识，但调用方已给出格式：不再按路径解析
+	p := filepath.Join(t.TempDir(), "a.unknown")
+	if err := os.WriteFile(p, []byte("合同编号：A-001"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if f := r.ResolveFormat(p); f != "" {
+		t.Fatalf("ResolveFormat = %q", f)
+	}
+	found, _, err := r.FindFirstAs(context.Background(), "text", p, "A-001", 1)
+	if err != nil || !found {
+		t.Fatalf("FindFirstAs: found=%v err=%v", found, err)
+	}
+	if _, _, err := r.FindFirstAs(context.Background(), "", p, "A-001", 1); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("empty format: err=%v", err)
+	}
+}
+
+func TestRegistry_SniffFormatIgnoresSniffSetting(t *testing.T) {
+	t.Setenv("OFIND_SNIFF_UNKNOWN", "0")
+	p := filepath.Join(t.TempDir(), "build.out")
+	if err := os.WriteFile(p, []byte("2024-03-31 合同编号：A-001\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if f := Default.ResolveFormat(p); f == "text" {
+		t.Fatalf("ResolveFormat sniffed an unknown extension with OFIND_SNIFF_UNKNOWN=0")
+	}
+	if f := Default.SniffFormat(p); f != "text" {
+		t.Fatalf("SniffFormat = %q, want text", f)
+	}
+}
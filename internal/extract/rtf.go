@@ -0,0 +1,378 @@
+package extract
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 纯 Go 的 RTF 文本提取：常见于“实际是 RTF 的 .doc”。只做流式扫描，
+// 跳过字体表/样式表/图片等非正文 destination，按 \ansicpg 解码 \'hh 转义。
+
+// rtfSkipDestinations 中的 destination 不含正文。
+var rtfSkipDestinations = map[string]struct{}{
+	"fonttbl": {}, "colortbl": {}, "stylesheet": {}, "info": {}, "pict": {},
+	"object": {}, "themedata": {}, "colorschememapping": {}, "datastore": {},
+	"latentstyles": {}, "listtable": {}, "listoverridetable": {}, "rsidtbl": {},
+	"generator": {}, "xmlnstbl": {}, "filetbl": {}, "revtbl": {}, "fldinst": {},
+	"bkmkstart": {}, "bkmkend": {}, "nonshppict": {},
+	"mmathPr": {}, "pgdsctbl": {}, "operator": {}, "author": {},
+}
+
+type rtfReader struct {
+	br *bufio.Reader
+
+	cs      charset // \ansicpg 对应的编码；charsetUnknown 表示按 Windows-1252 近似处理
+	uc      int     // \ucN：\uN 之后需要跳过的替代字符数
+	skip    int     // 剩余待跳过的替代字符数
+	depth   int
+	skipAt  int // >0 时表示从该深度开始的 group 整体跳过
+	ucStack []int
+
+	pending []byte // 连续的 \'hh 字节，遇到其它 token 时统一解码（双字节编码需要成对）
+	out     strings.Builder
+	eof     bool
+}
+
+func newRTFReader(r io.Reader) *rtfReader {
+	return &rtfReader{br: bufio.NewReaderSize(r, 32*1024), uc: 1}
+}
+
+func (r *rtfReader) flushPending() {
+	if len(r.pending) == 0 {
+		return
+	}
+	r.out.WriteString(decodeWithCharset(r.cs, r.pending))
+	r.pending = r.pending[:0]
+}
+
+func (r *rtfReader) emit(s string) {
+	if r.skipAt > 0 {
+		return
+	}
+	r.flushPending()
+	r.out.WriteString(s)
+}
+
+// next 返回下一段正文（约 32KiB），用于 streamFindFirst / streamFindSnippets。
+func (r *rtfReader) next(ctx context.Context) (string, error) {
+	if r.eof {
+		return "", io.EOF
+	}
+	for r.out.Len() < 32*1024 {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		c, err := r.br.ReadByte()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return "", err
+			}
+			r.eof = true
+			break
+		}
+		switch c {
+		case '{':
+			r.flushPending()
+			r.depth++
+			r.ucStack = append(r.ucStack, r.uc)
+		case '}':
+			r.flushPending()
+			if r.skipAt > 0 && r.depth <= r.skipAt {
+				r.skipAt = 0
+			}
+			r.depth--
+			if n := len(r.ucStack); n > 0 {
+				r.uc = r.ucStack[n-1]
+				r.ucStack = r.ucStack[:n-1]
+			}
+		case '\\':
+			if err := r.readControl(); err != nil {
+				if errors.Is(err, io.EOF) {
+					r.eof = true
+					break
+				}
+				return "", err
+			}
+		case '\r', '\n':
+			// 源码换行不属于正文
+		default:
+			if r.skip > 0 {
+				r.skip--
+				continue
+			}
+			if r.skipAt > 0 {
+				continue
+			}
+			r.flushPending()
+			if c < 0x80 {
+				r.out.WriteByte(c)
+			} else {
+				r.pending = append(r.pending, c)
+			}
+		}
+	}
+	r.flushPending()
+	s := r.out.String()
+	r.out.Reset()
+	if r.eof {
+		return s, io.EOF
+	}
+	return s, nil
+}
+
+func (r *rtfReader) readControl() error {
+	c, err := r.br.ReadByte()
+	if err != nil {
+		return err
+	}
+	if !isASCIILetter(c) {
+		switch c {
+		case '\'':
+			h := make([]byte, 2)
+			if _, err := io.ReadFull(r.br, h); err != nil {
+				return err
+			}
+			v, perr := strconv.ParseUint(string(h), 16, 8)
+			if perr != nil {
+				return nil
+			}
+			if r.skip > 0 {
+				r.skip--
+				return nil
+			}
+			if r.skipAt == 0 {
+				r.pending = append(r.pending, byte(v))
+			}
+		case '*':
+			// {\*\dest ...}：未知的可忽略 destination，整体跳过。
+			if r.skipAt == 0 {
+				r.skipAt = r.depth
+			}
+		case '~':
+			r.emit(" ")
+		case '_':
+			r.emit("-")
+		case '\\', '{', '}':
+			r.emit(string(c))
+		case '\r', '\n':
+			r.emit("\n")
+		case '\t':
+			r.emit("\t")
+		}
+		return nil
+	}
+
+	word := []byte{c}
+	for {
+		c, err = r.br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if !isASCIILetter(c) {
+			break
+		}
+		if len(word) < 32 {
+			word = append(word, c)
+		}
+	}
+	hasParam := false
+	neg := false
+	param := 0
+	if c == '-' {
+		neg = true
+		if c, err = r.br.ReadByte(); err != nil {
+			return err
+		}
+	}
+	for c >= '0' && c <= '9' {
+		hasParam = true
+		if param < 1<<24 {
+			param = param*10 + int(c-'0')
+		}
+		if c, err = r.br.ReadByte(); err != nil {
+			return err
+		}
+	}
+	if neg {
+		param = -param
+	}
+	// 控制字后的一个空格属于分隔符；其它字符放回去。
+	if c != ' ' {
+		_ = r.br.UnreadByte()
+	}
+	r.control(string(word), param, hasParam)
+	return nil
+}
+
+func (r *rtfReader) control(word string, param int, hasParam bool) {
+	if _, ok := rtfSkipDestinations[word]; ok {
+		if r.skipAt == 0 {
+			r.skipAt = r.depth
+		}
+		return
+	}
+	switch word {
+	case "ansicpg":
+		r.cs = charsetForCodePage(param)
+	case "uc":
+		if hasParam && param >= 0 {
+			r.uc = param
+		}
+	case "u":
+		if param < 0 {
+			param += 65536
+		}
+		if r.skipAt == 0 {
+			r.emit(string(rune(param)))
+		}
+		r.skip = r.uc
+	case "par", "line", "sect", "page", "row":
+		r.emit("\n")
+	case "tab", "cell":
+		r.emit("\t")
+	case "emdash":
+		r.emit("—")
+	case "endash":
+		r.emit("–")
+	case "lquote":
+		r.emit("‘")
+	case "rquote":
+		r.emit("’")
+	case "ldblquote":
+		r.emit("“")
+	case "rdblquote":
+		r.emit("”")
+	case "bullet":
+		r.emit("•")
+	}
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// charsetForCodePage 将 Windows 代码页映射到内置解码器。
+func charsetForCodePage(cp int) charset {
+	switch cp {
+	case 936, 54936:
+		return charsetGB18030
+	case 950:
+		return charsetBig5
+	case 932:
+		return charsetShiftJIS
+	case 65001:
+		return charsetUTF8
+	default:
+		return charsetUnknown
+	}
+}
+
+// cp1252High 为 Windows-1252 中 0x80..0x9F 的字符，其余字节同 Latin-1。
+var cp1252High = [32]rune{
+	'€', '�', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '�', 'Ž', '�',
+	'�', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '�', 'ž', 'Ÿ',
+}
+
+func cp1252Rune(c byte) rune {
+	if c >= 0x80 && c <= 0x9F {
+		return cp1252High[c-0x80]
+	}
+	return rune(c)
+}
+
+// decodeWithCharset 按代码页映射得到的编码解码一段完整的字节；未知编码按 Windows-1252 处理。
+func decodeWithCharset(cs charset, b []byte) string {
+	switch cs {
+	case charsetGB18030, charsetBig5, charsetShiftJIS:
+		text, _ := decodeMBCS(cs, b, true)
+		return text
+	case charsetUTF8:
+		return strings.ToValidUTF8(string(b), "�")
+	}
+	var sb strings.Builder
+	sb.Grow(len(b))
+	for _, c := range b {
+		sb.WriteRune(cp1252Rune(c))
+	}
+	return sb.String()
+}
+
+func rtfFindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, "", err
+	}
+	defer f.Close()
+	return streamFindFirst(ctx, newRTFReader(f).next, query, contextLen)
+}
+
+func rtfFindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return streamFindSnippets(ctx, newRTFReader(f).next, query, contextLen, maxSnippets)
+}
+
+func rtfExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return collectChunks(ctx, newRTFReader(f).next, maxBytesOrDefault(maxBytes))
+}
+
+type rtfExtractor struct{}
+
+func (rtfExtractor) Name() string { return "rtf" }
+
+func (rtfExtractor) FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	return rtfFindFirst(ctx, path, query, contextLen)
+}
+
+func (rtfExtractor) FindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
+	return rtfFindSnippets(ctx, path, query, contextLen, maxSnippets)
+}
+
+func (rtfExtractor) ExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
+	return rtfExtractText(ctx, path, maxBytes)
+}
+
+func (rtfExtractor) Stream(ctx context.Context, path string) (TextStream, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &funcStream{next: newRTFReader(f).next, close: f.Close}, nil
+}
+
+// collectChunks 把流式 chunk 拼接为全文，最多 maxBytes 字节（按 rune 边界截断）。
+func collectChunks(ctx context.Context, next nextStringChunkFunc, maxBytes int64) (string, error) {
+	var sb strings.Builder
+	for {
+		chunk, err := next(ctx)
+		if remaining := maxBytes - int64(sb.Len()); int64(len(chunk)) > remaining {
+			cut := int(remaining)
+			for cut > 0 && !utf8.RuneStart(chunk[cut]) {
+				cut--
+			}
+			sb.WriteString(chunk[:cut])
+			return sb.String(), nil
+		}
+		sb.WriteString(chunk)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return sb.String(), nil
+			}
+			return "", err
+		}
+	}
+}
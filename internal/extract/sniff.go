@@ -0,0 +1,160 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// 按内容（magic bytes）识别真实格式：下载得到的无扩展名文件、report.tmp，
+// 以及实际是 RTF/HTML 的 .doc 都能交给正确的提取器。
+
+type fileKind int
+
+const (
+	kindUnknown fileKind = iota
+	kindText
+	kindOOXML
+	kindPDF
+	kindIFilter // 旧版 Office 复合文档（doc/xls/ppt）及其它交给系统 IFilter 的格式
+	kindRTF
+	kindHTML
+)
+
+func (k fileKind) String() string {
+	switch k {
+	case kindText:
+		return "text"
+	case kindOOXML:
+		return "ooxml"
+	case kindPDF:
+		return "pdf"
+	case kindIFilter:
+		return "ifilter"
+	case kindRTF:
+		return "rtf"
+	case kindHTML:
+		return "html"
+	default:
+		return "unknown"
+	}
+}
+
+const sniffHeadBytes = 4096
+
+var cfbSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// SniffUnknownEnabled 表示是否对未知扩展名（含无扩展名）的文件做内容识别。
+// 开启后遍历器会把所有文件交给提取器，代价是每个文件都要打开读取文件头。
+// 通过 OFIND_SNIFF_UNKNOWN=1 开启。
+func SniffUnknownEnabled() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("OFIND_SNIFF_UNKNOWN"))) {
+	case "1", "true", "yes", "y", "on":
+		return true
+	}
+	return false
+}
+
+// sniffFile 读取文件头识别格式；ZIP 需要进一步确认含 [Content_Types].xml 才算 OOXML。
+func sniffFile(path string) (fileKind, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return kindUnknown, err
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffHeadBytes)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return kindUnknown, err
+	}
+	head = head[:n]
+
+	kind := sniffBytes(head)
+	if kind == kindIFilter && cfbIsEncryptedOOXML(f) {
+		// 设置了打开口令的 docx/xlsx/pptx 是包着 EncryptedPackage 的 CFB，由 OOXML 提取器解密。
+		return kindOOXML, nil
+	}
+	if kind != kindOOXML {
+		return kind, nil
+	}
+	st, err := f.Stat()
+	if err != nil {
+		return kindUnknown, err
+	}
+	zr, err := zip.NewReader(f, st.Size())
+	if err != nil {
+		return kindUnknown, nil
+	}
+	for _, zf := range zr.File {
+		if zf.Name == "[Content_Types].xml" {
+			return kindOOXML, nil
+		}
+	}
+	return kindUnknown, nil
+}
+
+// sniffBytes 仅依据文件头判断格式；ZIP 一律返回 kindOOXML，由调用方进一步确认。
+func sniffBytes(head []byte) fileKind {
+	switch {
+	case len(head) == 0:
+		return kindUnknown
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return kindOOXML
+	case bytes.HasPrefix(head, cfbSignature):
+		return kindIFilter
+	case bytes.HasPrefix(head, []byte("{\\rtf")):
+		return kindRTF
+	}
+	// %PDF 允许出现在前 1KiB 内（部分生成器会在前面写入垃圾字节）。
+	if i := bytes.Index(head, []byte("%PDF-")); i >= 0 && i < 1024 {
+		return kindPDF
+	}
+
+	cs := detectCharset(head)
+	switch cs {
+	case charsetUTF16LE, charsetUTF16BE:
+		return kindText
+	case charsetUnknown:
+		return kindUnknown
+	}
+	if !looksLikeText(head) {
+		return kindUnknown
+	}
+	if looksLikeHTML(head) {
+		return kindHTML
+	}
+	return kindText
+}
+
+// looksLikeText 拒绝含 NUL 或大量控制字符的样本（图片、可执行文件等）。
+func looksLikeText(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	ctrl := 0
+	for _, c := range b {
+		if c == 0 {
+			return false
+		}
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' && c != '\f' {
+			ctrl++
+		}
+	}
+	return ctrl*100 <= len(b)
+}
+
+func looksLikeHTML(head []byte) bool {
+	s := strings.ToLower(string(bytes.TrimLeft(bytes.TrimPrefix(head, []byte{0xEF, 0xBB, 0xBF}), " \t\r\n")))
+	if strings.HasPrefix(s, "<!doctype html") || strings.HasPrefix(s, "<html") {
+		return true
+	}
+	// Word“另存为网页”生成的 .doc 以 <html xmlns:o=...> 开头，但前面可能还有 <?xml ...?> 或注释。
+	if len(s) > 1024 {
+		s = s[:1024]
+	}
+	return strings.HasPrefix(s, "<") && (strings.Contains(s, "<html") || strings.Contains(s, "<head") || strings.Contains(s, "<body"))
+}
@@ -0,0 +1,113 @@
+package extract
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSniffBytes(t *testing.T) {
+	cases := []struct {
+		name string
+		head []byte
+		want fileKind
+	}{
+		{"pdf", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3"), kindPDF},
+		{"cfb", append(append([]byte{}, cfbSignature...), 0, 0, 0, 0), kindIFilter},
+		{"rtf", []byte(`{\rtf1\ansi\ansicpg936 hello}`), kindRTF},
+		{"html", []byte("<!DOCTYPE html><html><body>hi</body></html>"), kindHTML},
+		{"word-html", []byte("<html xmlns:o=\"urn:schemas-microsoft-com:office:office\">"), kindHTML},
+		{"utf8", []byte("合同编号：A-001\n"), kindText},
+		{"utf16", encodeUTF16LE("plain text file\r\n"), kindText},
+		{"gbk", sampleGBK, kindText},
+		{"binary", []byte{0x7f, 'E', 'L', 'F', 2, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0}, kindUnknown},
+	}
+	for _, c := range cases {
+		if got := sniffBytes(c.head); got != c.want {
+			t.Errorf("%s: sniffBytes = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestResolveKind_MisnamedFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	rtfDoc := write("letter.doc", `{\rtf1\ansi hello}`)
+	htmlDoc := write("page.doc", "<html><body>hello</body></html>")
+	noExt := write("document", "%PDF-1.4\n")
+	resolveKind := func(p string) string {
+		if f := Default.resolve(p); f != nil {
+			return f.Name
+		}
+		return ""
+	}
+
+	if got := resolveKind(rtfDoc); got != kindRTF.String() {
+		t.Errorf(".doc with RTF content: got %v", got)
+	}
+	if got := resolveKind(htmlDoc); got != kindHTML.String() {
+		t.Errorf(".doc with HTML content: got %v", got)
+	}
+
+	t.Setenv("OFIND_SNIFF_UNKNOWN", "")
+	if got := resolveKind(noExt); got != kindIFilter.String() {
+		t.Errorf("unknown ext without sniffing should keep IFilter, got %v", got)
+	}
+	t.Setenv("OFIND_SNIFF_UNKNOWN", "1")
+	if got := resolveKind(noExt); got != kindPDF.String() {
+		t.Errorf("unknown ext with sniffing: got %v", got)
+	}
+
+	zipPath := filepath.Join(dir, "report.tmp")
+	zf, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(zf)
+	for name, body := range map[string]string{
+		"[Content_Types].xml": `<Types/>`,
+		"word/document.xml":   `<w:document><w:body><w:p><w:r><w:t>合同编号：A-001</w:t></w:r></w:p></w:body></w:document>`,
+	} {
+		w, _ := zw.Create(name)
+		_, _ = w.Write([]byte(body))
+	}
+	_ = zw.Close()
+	_ = zf.Close()
+	found, snip, err := FileFindFirst(context.Background(), zipPath, "A-001", 1)
+	if err != nil || !found || snip != "：【A-001】" {
+		t.Fatalf("docx named .tmp: found=%v snip=%q err=%v", found, snip, err)
+	}
+}
+
+func TestRTFReader(t *testing.T) {
+	// \'c4\'e3\'ba\'c3 = “你好”（GBK）；\u21512? = “合”，\u21516? = “同”
+	src := `{\rtf1\ansi\ansicpg936{\fonttbl{\f0\fnil SimSun;}}{\*\generator Riched20;}\f0 \'c4\'e3\'ba\'c3\par \u21512?\u21516?\'b1\'e0\'ba\'c5: A-001\par}`
+	got, err := collectChunks(context.Background(), newRTFReader(strings.NewReader(src)).next, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "你好\n合同编号: A-001\n" {
+		t.Fatalf("unexpected text %q", got)
+	}
+}
+
+func TestHTMLStripper(t *testing.T) {
+	src := `<html><head><title>T</title><style>p{color:red}</style><script>var a="x<y";</script></head>` +
+		`<body><p>合同&nbsp;编号&amp;A-001</p><table><tr><td>a</td><td>b</td></tr></table></body></html>`
+	got, err := collectChunks(context.Background(), newHTMLStripper(textChunkReader(strings.NewReader(src), charsetUTF8)).read, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "合同 编号&A-001") || strings.Contains(got, "color") || strings.Contains(got, "var a") {
+		t.Fatalf("unexpected text %q", got)
+	}
+}
@@ -31,20 +31,28 @@ func streamFindFirst(ctx context.Context, next nextStringChunkFunc, query string
 		if ctx.Err() != nil {
 			return false, "", ctx.Err()
 		}
+		// 实现可以在返回最后一块数据的同时返回 io.EOF，这里先处理数据再结束。
 		chunk, err := next(ctx)
+		eof := false
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				return false, "", nil
+			if !errors.Is(err, io.EOF) {
+				return false, "", err
 			}
-			return false, "", err
+			eof = true
 		}
 		if chunk == "" {
+			if eof {
+				return false, "", nil
+			}
 			continue
 		}
 
 		searchText := prevTail + chunk
 		idx := strings.Index(searchText, query)
 		if idx < 0 {
+			if eof {
+				return false, "", nil
+			}
 			prevTail = tailRunes(searchText, keepRunes)
 			continue
 		}
@@ -55,19 +63,16 @@ func streamFindFirst(ctx context.Context, next nextStringChunkFunc, query string
 		matchEnd := idx + len(query)
 
 		fullText := searchText
-		for !hasEnoughRightContext(fullText, matchEnd, contextLen) {
+		for !eof && !hasEnoughRightContext(fullText, matchEnd, contextLen) {
 			if ctx.Err() != nil {
 				return false, "", ctx.Err()
 			}
 			more, err := next(ctx)
 			if err != nil {
-				if errors.Is(err, io.EOF) {
-					break
+				if !errors.Is(err, io.EOF) {
+					return false, "", err
 				}
-				return false, "", err
-			}
-			if more == "" {
-				continue
+				eof = true
 			}
 			fullText += more
 		}
@@ -107,15 +112,19 @@ func streamFindSnippets(ctx context.Context, next nextStringChunkFunc, query str
 			return nil, ctx.Err()
 		}
 		chunk, err := next(ctx)
-		if err != nil && !errors.Is(err, io.EOF) {
-			return nil, err
+		eof := false
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			eof = true
 		}
-		if chunk == "" && err == nil {
+		if chunk == "" {
+			if eof {
+				break
+			}
 			continue
 		}
-		if chunk == "" && errors.Is(err, io.EOF) {
-			break
-		}
 
 		searchText := prevTail + chunk
 		searchFrom := 0
@@ -129,26 +138,19 @@ func streamFindSnippets(ctx context.Context, next nextStringChunkFunc, query str
 			matchStart := realIdx
 			matchEnd := matchStart + len(query)
 
-			fullText := searchText
-			eof := false
-			for !hasEnoughRightContext(fullText, matchEnd, contextLen) {
+			for !eof && !hasEnoughRightContext(searchText, matchEnd, contextLen) {
 				if ctx.Err() != nil {
 					return nil, ctx.Err()
 				}
 				more, ferr := next(ctx)
 				if ferr != nil {
-					if errors.Is(ferr, io.EOF) {
-						eof = true
-						break
+					if !errors.Is(ferr, io.EOF) {
+						return nil, ferr
 					}
-					return nil, ferr
+					eof = true
 				}
-				if more == "" {
-					continue
-				}
-				fullText += more
+				searchText += more
 			}
-			searchText = fullText
 
 			start := moveLeftRunes(searchText, matchStart, contextLen)
 			end := moveRightRunes(searchText, matchEnd, contextLen)
@@ -167,17 +169,19 @@ func streamFindSnippets(ctx context.Context, next nextStringChunkFunc, query str
 			} else {
 				searchFrom = matchEnd
 			}
-
-			if eof {
-				return snips, nil
-			}
 		}
 
-		if len(snips) >= maxSnippets {
+		if len(snips) >= maxSnippets || eof {
 			return snips, nil
 		}
 
-		prevTail = tailRunes(searchText, keepRunes)
+		// 已报告的命中不能再次出现在 tail 中，否则下一块会重复命中。
+		tailFrom := len(searchText) - len(tailRunes(searchText, keepRunes))
+		if tailFrom < searchFrom {
+			prevTail = searchText[searchFrom:]
+		} else {
+			prevTail = searchText[tailFrom:]
+		}
 	}
 	return snips, nil
 }
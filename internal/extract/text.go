@@ -3,6 +3,7 @@ package extract
 import (
 	"context"
 	"errors"
+	"io"
 	"os"
 	"unicode/utf16"
 	"unicode/utf8"
@@ -23,23 +24,25 @@ func textFileFindFirst(ctx context.Context, path string, query string, contextLe
 		return false, "", ctx.Err()
 	}
 
-	// 读取一定上限，避免极端大文件导致内存压力。
-	const maxBytes = 20 * 1024 * 1024
-	b, err := readAllLimit(f, maxBytes)
+	// 所有编码都走分块流式扫描：内存占用与文件大小无关，多 GB 日志也能完整搜索。
+	cs, err := textDetectFileCharset(f, path)
 	if err != nil {
 		return false, "", err
 	}
+	return streamFindFirst(ctx, textChunkReader(f, cs), query, contextLen)
+}
 
-	text, err := decodeTextBytes(b)
-	if err != nil {
-		return false, "", err
+// textDetectFileCharset 读取文件开头样本判断编码，并把读位置复位到文件开头。
+func textDetectFileCharset(f *os.File, path string) (charset, error) {
+	head := make([]byte, textDetectSampleBytes)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return charsetUnknown, err
 	}
-
-	snips := FindSnippets(text, query, contextLen, 1)
-	if len(snips) == 0 {
-		return false, "", nil
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return charsetUnknown, err
 	}
-	return true, snips[0], nil
+	return textCharsetFor(path, head[:n]), nil
 }
 
 func textFileExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
@@ -57,10 +60,25 @@ func textFileExtractText(ctx context.Context, path string, maxBytes int64) (stri
 	if err != nil {
 		return "", err
 	}
-	return decodeTextBytes(b)
+	return decodeTextBytes(b, textCharsetFor(path, b))
 }
 
-func decodeTextBytes(b []byte) (string, error) {
+// textDetectSampleBytes 为编码探测读取的文件开头字节数。
+const textDetectSampleBytes = 64 * 1024
+
+// textCharsetFor 决定文件编码：用户按根目录/全局指定的编码优先，否则按开头样本探测。
+func textCharsetFor(path string, head []byte) charset {
+	if cs, ok := textEncodingOverride(path); ok {
+		return cs
+	}
+	if len(head) > textDetectSampleBytes {
+		head = head[:textDetectSampleBytes]
+	}
+	return detectCharset(head)
+}
+
+func decodeTextBytes(b []byte, cs charset) (string, error) {
+	// BOM 始终优先于探测/覆盖结果。
 	// UTF-8 BOM
 	if len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF {
 		b = b[3:]
@@ -73,6 +91,15 @@ func decodeTextBytes(b []byte) (string, error) {
 	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
 		return decodeUTF16(b[2:], false), nil
 	}
+	switch cs {
+	case charsetUTF16LE:
+		return decodeUTF16(b, true), nil
+	case charsetUTF16BE:
+		return decodeUTF16(b, false), nil
+	case charsetGB18030, charsetBig5, charsetShiftJIS:
+		text, _ := decodeMBCS(cs, b, true)
+		return text, nil
+	}
 	// 默认 UTF-8
 	if utf8.Valid(b) {
 		return string(b), nil
@@ -101,57 +128,34 @@ func decodeUTF16(b []byte, littleEndian bool) string {
 }
 
 func textFileFindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
-	// Detect encoding first
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	// Do not defer close here immediately if we pass f to closure, but we can if closure uses f.
-	// But closure lifetime is inside this function.
 	defer f.Close()
 
-	head := make([]byte, 4)
-	n, _ := f.Read(head)
-	_, _ = f.Seek(0, 0)
-
-	isUTF16 := false
-	if n >= 2 {
-		if head[0] == 0xFF && head[1] == 0xFE {
-			isUTF16 = true
-		} else if head[0] == 0xFE && head[1] == 0xFF {
-			isUTF16 = true
-		}
-	}
-
-	// For UTF-16, simpler to just read all (with limit) for now,
-	// as streaming decode + snippet searching is complex (need to align chunks).
-	// Most large CSVs/Logs causing issues are UTF-8.
-	if isUTF16 {
-		// Fallback to memory load (capped)
-		const maxBytes = 10 * 1024 * 1024
-		b, err := readAllLimit(f, maxBytes)
-		if err != nil {
-			return nil, err
-		}
-		text, err := decodeTextBytes(b)
-		if err != nil {
-			return nil, err
-		}
-		return FindSnippets(text, query, contextLen, maxSnippets), nil
+	cs, err := textDetectFileCharset(f, path)
+	if err != nil {
+		return nil, err
 	}
+	return streamFindSnippets(ctx, textChunkReader(f, cs), query, contextLen, maxSnippets)
+}
 
-	// UTF-8 Streaming
+// textChunkReader 按 32KiB 分块读取并解码为 UTF-8 字符串（UTF-8 / UTF-16LE/BE / GB18030 / Big5 / Shift-JIS）。
+// 块尾不完整的多字节序列或 UTF-16 代理对留到下一块拼接，保证不会把一个字符切成两半。
+// 文件开头的 BOM 优先于 cs。
+func textChunkReader(r io.Reader, cs charset) nextStringChunkFunc {
 	var leftOver []byte
 	firstChunk := true
 
-	next := func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
 		readBufSize := 32 * 1024
 		buf := make([]byte, readBufSize+len(leftOver))
 		if len(leftOver) > 0 {
 			copy(buf, leftOver)
 		}
 
-		n, err := f.Read(buf[len(leftOver):])
+		n, err := r.Read(buf[len(leftOver):])
 		total := len(leftOver) + n
 
 		if total == 0 {
@@ -164,26 +168,69 @@ func textFileFindSnippets(ctx context.Context, path string, query string, contex
 		b := buf[:total]
 		leftOver = nil // Reset
 
-		// Check for partial UTF-8 at the end only if NOT EOF
-		if err == nil {
-			valid, rest := cutPartialUTF8(b)
-			b = valid
-			if len(rest) > 0 {
-				leftOver = make([]byte, len(rest))
-				copy(leftOver, rest)
-			}
-		}
-
 		if firstChunk {
-			if len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF {
+			// BOM 可能被拆到两次 Read 中，凑够 3 字节再判断。
+			if len(b) < 3 && err == nil {
+				leftOver = append([]byte(nil), b...)
+				return "", nil
+			}
+			switch {
+			case len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF:
 				b = b[3:]
+				cs = charsetUTF8
+			case len(b) >= 2 && b[0] == 0xFF && b[1] == 0xFE:
+				b = b[2:]
+				cs = charsetUTF16LE
+			case len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF:
+				b = b[2:]
+				cs = charsetUTF16BE
 			}
 			firstChunk = false
 		}
-		return string(b), err
+
+		var (
+			text string
+			rest []byte
+		)
+		switch cs {
+		case charsetUTF16LE, charsetUTF16BE:
+			text, rest = decodeUTF16Chunk(b, cs == charsetUTF16LE, err != nil)
+		case charsetGB18030, charsetBig5, charsetShiftJIS:
+			text, rest = decodeMBCS(cs, b, err != nil)
+		default:
+			// Check for partial UTF-8 at the end only if NOT EOF
+			if err == nil {
+				b, rest = cutPartialUTF8(b)
+			}
+			text = string(b)
+		}
+		if len(rest) > 0 {
+			leftOver = make([]byte, len(rest))
+			copy(leftOver, rest)
+		}
+		return text, err
 	}
+}
 
-	return streamFindSnippets(ctx, next, query, contextLen, maxSnippets)
+// decodeUTF16Chunk 解码一块 UTF-16 字节；atEOF=false 时，末尾的奇数字节与落单的高位代理
+// 作为 rest 返回，避免代理对被块边界拆开后解成两个 U+FFFD。
+func decodeUTF16Chunk(b []byte, littleEndian bool, atEOF bool) (string, []byte) {
+	if atEOF {
+		return decodeUTF16(b, littleEndian), nil
+	}
+	cut := len(b) &^ 1
+	if cut >= 2 {
+		var last uint16
+		if littleEndian {
+			last = uint16(b[cut-2]) | uint16(b[cut-1])<<8
+		} else {
+			last = uint16(b[cut-1]) | uint16(b[cut-2])<<8
+		}
+		if last >= 0xD800 && last <= 0xDBFF {
+			cut -= 2
+		}
+	}
+	return decodeUTF16(b[:cut], littleEndian), b[cut:]
 }
 
 func cutPartialUTF8(b []byte) (valid, rest []byte) {
@@ -218,3 +265,32 @@ func cutPartialUTF8(b []byte) (valid, rest []byte) {
 	}
 	return b, nil
 }
+
+type textExtractor struct{}
+
+func (textExtractor) Name() string { return "text" }
+
+func (textExtractor) FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	return textFileFindFirst(ctx, path, query, contextLen)
+}
+
+func (textExtractor) FindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
+	return textFileFindSnippets(ctx, path, query, contextLen, maxSnippets)
+}
+
+func (textExtractor) ExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
+	return textFileExtractText(ctx, path, maxBytes)
+}
+
+func (textExtractor) Stream(ctx context.Context, path string) (TextStream, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := textDetectFileCharset(f, path)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &funcStream{next: textChunkReader(f, cs), close: f.Close}, nil
+}
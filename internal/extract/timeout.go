@@ -0,0 +1,140 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTimeout 表示单个文件的处理超过了时限（OFIND_FILE_TIMEOUT）。
+var ErrTimeout = errors.New("文件处理超时")
+
+const defaultFileTimeout = 60 * time.Second
+
+// FileTimeout 返回单个文件的处理时限：OFIND_FILE_TIMEOUT 可写 "30s"、"2m" 或纯数字（秒），
+// 默认 60 秒；"0" 表示不限时。
+func FileTimeout() time.Duration {
+	v := strings.TrimSpace(os.Getenv("OFIND_FILE_TIMEOUT"))
+	if v == "" {
+		return defaultFileTimeout
+	}
+	if n, err := strconv.ParseFloat(v, 64); err == nil {
+		if n <= 0 {
+			return 0
+		}
+		return time.Duration(n * float64(time.Second))
+	}
+	if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+		return d
+	}
+	return defaultFileTimeout
+}
+
+// WithFileTimeout 为单个文件派生带时限的 ctx；d <= 0 时不限时。
+func WithFileTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// FileFindFirstWithin 与 FileFindFirst 相同，但 ctx 到期后立即返回 ErrTimeout：
+// 不响应取消的后端（如卡在 IFilter 调用里）会被留在后台自行结束，不再占住调用方的 worker。
+func FileFindFirstWithin(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	return Default.FindFirstWithin(ctx, path, query, contextLen)
+}
+
+// FileFindFirstAsWithin 与 FileFindFirstWithin 相同，但使用已由 FileFormat 解析出的格式。
+func FileFindFirstAsWithin(ctx context.Context, format string, path string, query string, contextLen int) (bool, string, error) {
+	return Default.FindFirstAsWithin(ctx, format, path, query, contextLen)
+}
+
+// FindFirstWithin 为 FileFindFirstWithin 基于指定注册表的版本：后台的提取 goroutine 只引用 r。
+func (r *Registry) FindFirstWithin(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	return within(ctx, func() (bool, string, error) { return r.FindFirst(ctx, path, query, contextLen) })
+}
+
+// FindFirstAsWithin 为 FindFirstAs 的限时版本。
+func (r *Registry) FindFirstAsWithin(ctx context.Context, format string, path string, query string, contextLen int) (bool, string, error) {
+	return within(ctx, func() (bool, string, error) { return r.FindFirstAs(ctx, format, path, query, contextLen) })
+}
+
+// within 在 ctx 有期限时把 find 放到单独的 goroutine 中运行，到期立即返回 ErrTimeout。
+func within(ctx context.Context, find func() (bool, string, error)) (bool, string, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		return find()
+	}
+	type result struct {
+		found   bool
+		snippet string
+		err     error
+	}
+	done := make(chan result, 1)
+	h := holdFrom(ctx)
+	h.add()
+	go func() {
+		defer h.done()
+		found, snippet, err := find()
+		done <- result{found, snippet, err}
+	}()
+	select {
+	case res := <-done:
+		if !res.found && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return false, "", ErrTimeout
+		}
+		return res.found, res.snippet, res.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return false, "", ErrTimeout
+		}
+		return false, "", ctx.Err()
+	}
+}
+
+type holdKey struct{}
+
+// hold 统计仍在运行的提取：调用方自己占一份，FileFindFirstWithin 的每个提取 goroutine 各占一份。
+type hold struct {
+	mu      sync.Mutex
+	n       int
+	release func()
+}
+
+func (h *hold) add() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	h.n++
+	h.mu.Unlock()
+}
+
+func (h *hold) done() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	h.n--
+	last := h.n == 0
+	h.mu.Unlock()
+	if last {
+		h.release()
+	}
+}
+
+func holdFrom(ctx context.Context) *hold {
+	h, _ := ctx.Value(holdKey{}).(*hold)
+	return h
+}
+
+// WithRelease 把与提取同生命周期的资源（如内存预算）挂在 ctx 上：调用方处理完文件后调用返回的 done，
+// release 在 done 被调用、且经该 ctx 启动的提取 goroutine（含超时后留在后台的）都结束后调用一次。
+func WithRelease(ctx context.Context, release func()) (context.Context, func()) {
+	h := &hold{n: 1, release: release}
+	var once sync.Once
+	return context.WithValue(ctx, holdKey{}, h), func() { once.Do(h.done) }
+}
@@ -0,0 +1,84 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// hangingExtractor 模拟不响应取消的后端（如卡住的 IFilter）。
+type hangingExtractor struct {
+	textExtractor
+	release chan struct{}
+}
+
+func (e hangingExtractor) FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	<-e.release
+	return false, "", nil
+}
+
+func TestFileFindFirstWithin_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	// 用独立的注册表：超时后留在后台的 goroutine 不会读到被测试替换/恢复的 Default
+	reg := NewRegistry()
+	reg.Register(Format{Name: "hang", Extensions: []string{".hang"}, TrustExtension: true, Chain: []Extractor{hangingExtractor{release: release}}})
+
+	p := filepath.Join(t.TempDir(), "a.hang")
+	if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := WithFileTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := reg.FindFirstWithin(ctx, p, "x", 5); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("returned after %s", d)
+	}
+
+	t.Setenv("OFIND_FILE_TIMEOUT", "1.5")
+	if d := FileTimeout(); d != 1500*time.Millisecond {
+		t.Fatalf("FileTimeout = %s", d)
+	}
+	t.Setenv("OFIND_FILE_TIMEOUT", "2m")
+	if d := FileTimeout(); d != 2*time.Minute {
+		t.Fatalf("FileTimeout = %s", d)
+	}
+}
+
+func TestWithRelease_WaitsForAbandonedExtraction(t *testing.T) {
+	release := make(chan struct{})
+	// 用独立的注册表：超时后留在后台的 goroutine 不会读到被测试替换/恢复的 Default
+	reg := NewRegistry()
+	reg.Register(Format{Name: "hang", Extensions: []string{".hang"}, TrustExtension: true, Chain: []Extractor{hangingExtractor{release: release}}})
+
+	p := filepath.Join(t.TempDir(), "a.hang")
+	if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	freed := make(chan struct{})
+	rctx, done := WithRelease(context.Background(), func() { close(freed) })
+	ctx, cancel := WithFileTimeout(rctx, 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := reg.FindFirstWithin(ctx, p, "x", 5); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+	done()
+	done()
+	select {
+	case <-freed:
+		t.Fatal("released while the extraction is still running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-freed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("not released after the extraction returned")
+	}
+}
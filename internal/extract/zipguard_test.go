@@ -0,0 +1,94 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestZip(t *testing.T, path string, files map[string][]byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write(body)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZipGuard_RefusesBombs(t *testing.T) {
+	dir := t.TempDir()
+
+	// 8MiB 的 0 压缩后只有几 KiB，压缩比远超默认上限
+	bomb := filepath.Join(dir, "bomb.docx")
+	writeTestZip(t, bomb, map[string][]byte{
+		"word/document.xml": append([]byte(`<?xml version="1.0"?><w:document xmlns:w="w"><w:t>`), make([]byte, 8<<20)...),
+	})
+	if _, _, err := FileFindFirst(context.Background(), bomb, "x", 5); !errors.Is(err, ErrRefused) {
+		t.Fatalf("ratio: err = %v, want ErrRefused", err)
+	}
+
+	// 每个 entry 都不到 1MiB，但合计 8MiB 的 0 同样是炸弹
+	small := filepath.Join(dir, "small.docx")
+	parts := map[string][]byte{"word/document.xml": []byte(`<w:t xmlns:w="w">hi</w:t>`)}
+	for i := 0; i < 16; i++ {
+		parts["word/part"+strings.Repeat("a", i+1)+".xml"] = make([]byte, 512<<10)
+	}
+	writeTestZip(t, small, parts)
+	if _, _, err := FileFindFirst(context.Background(), small, "hi", 5); !errors.Is(err, ErrRefused) {
+		t.Fatalf("total ratio: err = %v, want ErrRefused", err)
+	}
+
+	many := filepath.Join(dir, "many.docx")
+	files := map[string][]byte{"word/document.xml": []byte(`<w:t xmlns:w="w">hi</w:t>`)}
+	for i := 0; i < 20; i++ {
+		files["word/media/"+strings.Repeat("a", i+1)] = nil
+	}
+	writeTestZip(t, many, files)
+	t.Setenv("OFIND_ZIP_MAX_ENTRIES", "10")
+	if _, _, err := FileFindFirst(context.Background(), many, "hi", 5); !errors.Is(err, ErrRefused) {
+		t.Fatalf("entries: err = %v, want ErrRefused", err)
+	}
+}
+
+func TestZipGuard_TruncatesDeepAndLargeXML(t *testing.T) {
+	dir := t.TempDir()
+
+	deep := filepath.Join(dir, "deep.docx")
+	writeTestZip(t, deep, map[string][]byte{
+		"word/document.xml": []byte(`<w:document xmlns:w="w"><w:t>浅层</w:t>` + strings.Repeat("<a>", 50) + `深层` + strings.Repeat("</a>", 50) + `</w:document>`),
+	})
+	t.Setenv("OFIND_XML_MAX_DEPTH", "10")
+	ctx, trace := WithTrace(context.Background())
+	if found, _, _ := FileFindFirst(ctx, deep, "深层", 5); found || !strings.Contains(trace.Truncated, "depth > 10") {
+		t.Fatalf("deep: found=%v trace=%+v", found, trace)
+	}
+	ctx, trace = WithTrace(context.Background())
+	if found, _, _ := FileFindFirst(ctx, deep, "浅层", 5); !found {
+		t.Fatalf("deep: shallow text not found, trace=%+v", trace)
+	}
+
+	large := filepath.Join(dir, "large.docx")
+	writeTestZip(t, large, map[string][]byte{
+		"word/document.xml": []byte(`<w:document xmlns:w="w"><w:t>开头</w:t><w:t>` + strings.Repeat("填充", 1000) + `结尾</w:t></w:document>`),
+	})
+	t.Setenv("OFIND_ZIP_MAX_ENTRY_BYTES", "1024")
+	ctx, trace = WithTrace(context.Background())
+	if found, _, _ := FileFindFirst(ctx, large, "结尾", 5); found || !strings.Contains(trace.Truncated, "word/document.xml > 1024 bytes") {
+		t.Fatalf("large: found=%v trace=%+v", found, trace)
+	}
+}
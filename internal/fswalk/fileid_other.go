@@ -0,0 +1,16 @@
+//go:build !unix && !windows
+
+package fswalk
+
+import (
+	"errors"
+	"io/fs"
+)
+
+const mountsAreLinks = false
+
+func statID(path string) (fileID, error) {
+	return fileID{}, errors.New("fswalk: 此平台不支持文件标识: " + path)
+}
+
+func linkedFileID(path string, d fs.DirEntry) (fileID, bool) { return fileID{}, false }
@@ -0,0 +1,39 @@
+//go:build unix
+
+package fswalk
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// 挂载点是普通目录，需逐个目录比较设备号
+const mountsAreLinks = false
+
+// statID 返回路径（跟随链接）的设备号与 inode。
+func statID(path string) (fileID, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileID{}, err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, errors.New("fswalk: 无法取得文件标识: " + path)
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, nil
+}
+
+// linkedFileID 返回有多个硬链接的文件的标识；只有一个链接时 ok 为 false。
+func linkedFileID(path string, d fs.DirEntry) (id fileID, ok bool) {
+	info, err := d.Info()
+	if err != nil {
+		return fileID{}, false
+	}
+	st, isStat := info.Sys().(*syscall.Stat_t)
+	if !isStat || uint64(st.Nlink) <= 1 {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
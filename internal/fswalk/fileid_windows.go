@@ -0,0 +1,44 @@
+package fswalk
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// 卷挂载点与目录联接都是重解析点，不跟随链接时不会跨卷
+const mountsAreLinks = true
+
+// statID 返回路径（跟随链接）所在卷的序列号与文件索引。
+func statID(path string) (fileID, error) {
+	id, _, err := fileInfo(path)
+	return id, err
+}
+
+// linkedFileID 返回有多个硬链接的文件的标识；只有一个链接时 ok 为 false。
+// 目录项里没有链接数，需要打开文件，因此只在开启 DedupFiles 时调用。
+func linkedFileID(path string, d fs.DirEntry) (id fileID, ok bool) {
+	id, links, err := fileInfo(path)
+	if err != nil || links <= 1 {
+		return fileID{}, false
+	}
+	return id, true
+}
+
+func fileInfo(path string) (fileID, uint32, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return fileID{}, 0, err
+	}
+	h, err := syscall.CreateFile(p, 0,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return fileID{}, 0, &fs.PathError{Op: "open", Path: path, Err: err}
+	}
+	defer syscall.CloseHandle(h)
+	var d syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(h, &d); err != nil {
+		return fileID{}, 0, &fs.PathError{Op: "stat", Path: path, Err: err}
+	}
+	return fileID{dev: uint64(d.VolumeSerialNumber), ino: uint64(d.FileIndexHigh)<<32 | uint64(d.FileIndexLow)}, d.NumberOfLinks, nil
+}
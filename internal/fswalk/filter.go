@@ -0,0 +1,359 @@
+package fswalk
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// 路径过滤：
+//   - 排除（exclude）：匹配的目录整个跳过（不再列其内容），匹配的文件跳过
+//   - 包含（include）：非空时只交出自身或某个上级目录匹配的文件；不影响进入哪些目录
+//   - 默认忽略：系统、回收站、版本库、依赖与 NAS 快照目录，见 DefaultIgnores
+//   - .ofindignore：目录下的忽略文件，按 gitignore 规则作用于该目录及其子目录
+//
+// 模式语法：* 与 ? 匹配一段路径内的字符，** 匹配任意多段；\ 与 / 都视为分隔符（Windows）。
+// 不含分隔符的模式（如 node_modules、*.tmp）匹配任意层级的名称；其它相对模式同样可出现在任意层级之下；
+// 绝对模式（如 D:\Projects\*\docs）从根开始匹配。Windows 上不区分大小写。
+
+// DefaultIgnores 为默认忽略的目录（OFIND_DEFAULT_IGNORES=0 关闭）。
+var DefaultIgnores = []string{
+	"?:/Windows",
+	"$Recycle.Bin",
+	"System Volume Information",
+	"$WINDOWS.~BT",
+	"$WinREAgent",
+	"WindowsImageBackup",
+	".git",
+	".svn",
+	".hg",
+	"node_modules",
+	"__pycache__",
+	".Trash-*",
+	"#recycle",
+	"@eaDir",
+	".snapshot",
+	"~snapshot",
+}
+
+// IgnoreFileName 为每个目录下按 gitignore 规则读取的忽略文件名。
+const IgnoreFileName = ".ofindignore"
+
+type pattern struct {
+	raw  string
+	segs []string
+}
+
+func caseFold(s string) string {
+	if runtime.GOOS == "windows" {
+		return strings.ToLower(s)
+	}
+	return s
+}
+
+func splitPath(p string) []string {
+	return strings.Split(caseFold(filepath.ToSlash(p)), "/")
+}
+
+func isAbsPattern(p string) bool {
+	return strings.HasPrefix(p, "/") || (len(p) >= 2 && p[1] == ':')
+}
+
+// compilePattern 编译用户模式；不含分隔符或相对的模式前面补 **/，使其可出现在任意层级。
+func compilePattern(raw string) (pattern, error) {
+	p := strings.TrimSpace(raw)
+	if p == "" {
+		return pattern{}, errors.New("空的路径模式")
+	}
+	p = strings.TrimSuffix(filepath.ToSlash(p), "/")
+	if !isAbsPattern(p) && !strings.HasPrefix(p, "**/") {
+		p = "**/" + p
+	}
+	pt := pattern{raw: raw, segs: strings.Split(caseFold(p), "/")}
+	for _, s := range pt.segs {
+		if _, err := path.Match(s, ""); err != nil {
+			return pattern{}, fmt.Errorf("无效的路径模式 %q: %w", raw, err)
+		}
+	}
+	return pt, nil
+}
+
+// matchSegs 按段匹配，** 可匹配零或多段。
+func matchSegs(p, s []string) bool {
+	for len(p) > 0 {
+		if p[0] == "**" {
+			p = p[1:]
+			if len(p) == 0 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchSegs(p, s[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(s) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(p[0], s[0]); !ok {
+			return false
+		}
+		p, s = p[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+// Filter 决定遍历时跳过哪些目录与文件；nil *Filter 不过滤。
+type Filter struct {
+	include     []pattern
+	exclude     []pattern
+	defaults    []pattern
+	ignoreFiles bool
+}
+
+// NewFilter 编译包含/排除模式；defaults 为 true 时启用 DefaultIgnores，ignoreFiles 为 true 时读取 .ofindignore。
+func NewFilter(include, exclude []string, defaults, ignoreFiles bool) (*Filter, error) {
+	f := &Filter{ignoreFiles: ignoreFiles}
+	for _, raw := range include {
+		p, err := compilePattern(raw)
+		if err != nil {
+			return nil, err
+		}
+		f.include = append(f.include, p)
+	}
+	for _, raw := range exclude {
+		p, err := compilePattern(raw)
+		if err != nil {
+			return nil, err
+		}
+		f.exclude = append(f.exclude, p)
+	}
+	if defaults {
+		for _, raw := range DefaultIgnores {
+			p, err := compilePattern(raw)
+			if err != nil {
+				return nil, err
+			}
+			f.defaults = append(f.defaults, p)
+		}
+	}
+	return f, nil
+}
+
+// SplitList 拆分 ; 分隔的模式列表（环境变量与 -include/-exclude 共用）。
+func SplitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ";") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// FilterFromEnv 读取 OFIND_INCLUDE、OFIND_EXCLUDE（; 分隔）、OFIND_DEFAULT_IGNORES（0 关闭默认忽略）、
+// OFIND_IGNORE_FILES（0 不读 .ofindignore）。无效模式被丢弃（CLI 在启动时已校验）。
+func FilterFromEnv() *Filter {
+	keep := func(list []string) []string {
+		out := list[:0]
+		for _, raw := range list {
+			if _, err := compilePattern(raw); err == nil {
+				out = append(out, raw)
+			}
+		}
+		return out
+	}
+	f, _ := NewFilter(
+		keep(SplitList(os.Getenv("OFIND_INCLUDE"))),
+		keep(SplitList(os.Getenv("OFIND_EXCLUDE"))),
+		strings.TrimSpace(os.Getenv("OFIND_DEFAULT_IGNORES")) != "0",
+		strings.TrimSpace(os.Getenv("OFIND_IGNORE_FILES")) != "0",
+	)
+	return f
+}
+
+func matchAny(list []pattern, segs []string) bool {
+	for _, p := range list {
+		if matchSegs(p.segs, segs) {
+			return true
+		}
+	}
+	return false
+}
+
+// SkipRoot 判断根路径本身是否被用户排除（默认忽略不作用于用户明确指定的根）。
+func (f *Filter) SkipRoot(root string) bool {
+	return f != nil && matchAny(f.exclude, splitPath(root))
+}
+
+// skip 判断目录项是否被排除（用户模式、默认忽略与 .ofindignore）。
+func (f *Filter) skip(sc *scope, p string, isDir bool) bool {
+	if f == nil {
+		return false
+	}
+	segs := splitPath(p)
+	if matchAny(f.exclude, segs) {
+		return true
+	}
+	if isDir && matchAny(f.defaults, segs) {
+		return true
+	}
+	return sc.ignored(p, isDir)
+}
+
+// keepFile 判断文件是否满足包含模式（自身或任一上级目录匹配）。
+func (f *Filter) keepFile(p string) bool {
+	if f == nil || len(f.include) == 0 {
+		return true
+	}
+	segs := splitPath(p)
+	for k := len(segs); k > 0; k-- {
+		if matchAny(f.include, segs[:k]) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchFile 判断单独给出的文件路径（不经遍历，如文件列表）是否通过过滤：排除模式作用于它自身与各级上级目录，
+// 默认忽略作用于各级上级目录，包含模式与遍历时相同；不读取 .ofindignore。
+func (f *Filter) MatchFile(p string) bool {
+	if f == nil {
+		return true
+	}
+	segs := splitPath(p)
+	if matchAny(f.exclude, segs) {
+		return false
+	}
+	for k := len(segs) - 1; k > 0; k-- {
+		if matchAny(f.exclude, segs[:k]) || matchAny(f.defaults, segs[:k]) {
+			return false
+		}
+	}
+	return f.keepFile(p)
+}
+
+// enter 在进入目录时读取其 .ofindignore（仅当列目录结果中有该文件，避免网络共享上多一次访问）。
+func (f *Filter) enter(parent *scope, dir string, entries []fs.DirEntry) *scope {
+	if f == nil || !f.ignoreFiles {
+		return parent
+	}
+	for _, e := range entries {
+		if e.Name() == IgnoreFileName && !e.IsDir() {
+			return f.enterDir(parent, dir)
+		}
+	}
+	return parent
+}
+
+// enterDir 读取 dir 下的 .ofindignore（不先列目录），没有或没有规则时返回 parent。
+func (f *Filter) enterDir(parent *scope, dir string) *scope {
+	if f == nil || !f.ignoreFiles {
+		return parent
+	}
+	rules := readIgnoreFile(filepath.Join(dir, IgnoreFileName))
+	if len(rules) == 0 {
+		return parent
+	}
+	return &scope{parent: parent, dir: dir, rules: rules}
+}
+
+// scope 为从根到当前目录沿途读到的 .ofindignore 规则链。
+type scope struct {
+	parent *scope
+	dir    string
+	rules  []ignoreRule
+}
+
+type ignoreRule struct {
+	segs    []string
+	negate  bool
+	dirOnly bool
+}
+
+func readIgnoreFile(name string) []ignoreRule {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var rules []ignoreRule
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if r, ok := parseIgnoreLine(sc.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreLine 按 gitignore 规则解析一行：# 注释、! 取反、结尾 / 只匹配目录、
+// 含 / 的模式相对忽略文件所在目录，否则匹配任意层级的名称。
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	var r ignoreRule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:] // \# 与 \! 表示字面字符
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+	r.segs = strings.Split(caseFold(line), "/")
+	for _, s := range r.segs {
+		if _, err := path.Match(s, ""); err != nil {
+			return ignoreRule{}, false
+		}
+	}
+	return r, true
+}
+
+// ignored 按规则链判断 p 是否被忽略：外层目录的规则先生效，同一文件内后面的规则覆盖前面的。
+func (s *scope) ignored(p string, isDir bool) bool {
+	if s == nil {
+		return false
+	}
+	var chain []*scope
+	for c := s; c != nil; c = c.parent {
+		chain = append(chain, c)
+	}
+	ignored := false
+	for i := len(chain) - 1; i >= 0; i-- {
+		c := chain[i]
+		rel, err := filepath.Rel(c.dir, p)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		segs := splitPath(rel)
+		for _, r := range c.rules {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if matchSegs(r.segs, segs) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
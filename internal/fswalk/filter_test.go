@@ -0,0 +1,96 @@
+package fswalk
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func walkRel(t *testing.T, root string, f *Filter, ordered bool) []string {
+	t.Helper()
+	var got []string
+	err := Walk(context.Background(), []string{root}, Options{Filter: f, Ordered: ordered}, func(path string, d fs.DirEntry) error {
+		rel, _ := filepath.Rel(root, path)
+		got = append(got, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestFilter_ExcludeDefaultsAndIgnoreFile(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.docx":                     "",
+		"a.tmp":                      "",
+		"node_modules/x/readme.md":   "",
+		".git/config.txt":            "",
+		"proj/docs/spec.pdf":         "",
+		"proj/build/out.txt":         "",
+		"proj/keep/build/report.txt": "",
+		"proj/.ofindignore":          "# 构建产物\n/build/\nsecret*.txt\n!secret-ok.txt\n",
+		"proj/secret1.txt":           "",
+		"proj/secret-ok.txt":         "",
+		"proj/sub/secret2.txt":       "",
+	})
+	f, err := NewFilter(nil, []string{"*.tmp", "**/docs"}, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.docx", "proj/.ofindignore", "proj/keep/build/report.txt", "proj/secret-ok.txt"}
+	for _, ordered := range []bool{false, true} {
+		if got := walkRel(t, root, f, ordered); !reflect.DeepEqual(got, want) {
+			t.Fatalf("ordered=%v:\n got %v\nwant %v", ordered, got, want)
+		}
+	}
+
+	// 关闭默认忽略与忽略文件后全部可见
+	f, _ = NewFilter(nil, nil, false, false)
+	if got := walkRel(t, root, f, false); len(got) != 11 {
+		t.Fatalf("unfiltered walk: %v", got)
+	}
+}
+
+func TestFilter_Include(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"p1/docs/a.txt":  "",
+		"p1/src/b.txt":   "",
+		"p2/docs/c.pdf":  "",
+		"p2/notes/d.pdf": "",
+	})
+	abs := filepath.Join(root, "*", "docs")
+	f, err := NewFilter([]string{abs, "notes/*.pdf"}, nil, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"p1/docs/a.txt", "p2/docs/c.pdf", "p2/notes/d.pdf"}
+	if got := walkRel(t, root, f, true); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if _, err := NewFilter(nil, []string{"[bad"}, false, false); err == nil || !strings.Contains(err.Error(), "[bad") {
+		t.Fatalf("invalid pattern: err = %v", err)
+	}
+}
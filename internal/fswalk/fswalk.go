@@ -0,0 +1,471 @@
+package fswalk
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 并发目录遍历：网络共享上列目录的延迟远大于处理单个文件，单线程 filepath.WalkDir 会让 worker 空等。
+// Walk 用有限个读取者并发列目录，文件仍在调用方的单个 goroutine 中依次回调，调用方无需加锁。
+
+type Options struct {
+	// Readers 为同时读取目录的数量，<=0 时为 4
+	Readers int
+	// Ordered 为 true 时按与 filepath.WalkDir 相同的（字典序、深度优先）顺序回调，结果可复现；
+	// 否则谁先列完谁先回调，吞吐更高但每次顺序不同。OptionsFromEnv 默认为 true
+	Ordered bool
+	// OnDirError 在目录（或根路径）无法读取时回调；同一目录能读到的部分项仍会继续处理
+	OnDirError func(path string, err error)
+	// Filter 决定跳过哪些目录与文件（包含/排除模式、默认忽略、.ofindignore），nil 表示不过滤
+	Filter *Filter
+	// MaxDepth 限制遍历深度：1 表示只看根目录下的文件，2 再加一层子目录，以此类推；<=0 不限
+	MaxDepth int
+	// FollowLinks 为 true 时进入指向目录的符号链接与目录联接（junction），并按文件标识避免循环与重复遍历；
+	// 默认不进入（指向文件的链接仍照常回调）
+	FollowLinks bool
+	// OneFileSystem 为 true 时不进入与根目录不在同一文件系统（卷）上的目录，如挂载的网络盘
+	OneFileSystem bool
+	// DedupFiles 为 true 时按文件标识识别硬链接，同一文件只回调一次（只对链接数大于 1 的文件记录标识）。
+	// 读取链接数需要对每个文件多访问一次（Windows 上为打开文件），网络共享上代价明显，因此默认关闭
+	DedupFiles bool
+	// SkipDirs 为遍历到时跳过的目录（与 roots 写法相同），用于各根分开遍历时（如每个根一个 daemon）
+	// 外层根跳过位于其下、由别的遍历负责的根；与一次 Walk 传入多个根时的效果相同
+	SkipDirs []string
+}
+
+// OptionsFromEnv 读取 OFIND_WALK_READERS（默认 4）、OFIND_WALK_ORDERED（默认按固定顺序，0 表示谁先列完谁先回调）、
+// OFIND_MAX_DEPTH、OFIND_FOLLOW_LINKS、OFIND_ONE_FILE_SYSTEM、OFIND_DEDUP_FILES
+// 与过滤设置（见 FilterFromEnv）。
+func OptionsFromEnv() Options {
+	o := Options{Readers: 4, Filter: FilterFromEnv()}
+	if v := strings.TrimSpace(os.Getenv("OFIND_WALK_READERS")); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			o.Readers = n
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("OFIND_MAX_DEPTH")); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			o.MaxDepth = n
+		}
+	}
+	o.Ordered = strings.TrimSpace(os.Getenv("OFIND_WALK_ORDERED")) != "0"
+	o.FollowLinks = envBool("OFIND_FOLLOW_LINKS")
+	o.OneFileSystem = envBool("OFIND_ONE_FILE_SYSTEM")
+	o.DedupFiles = envBool("OFIND_DEDUP_FILES")
+	return o
+}
+
+func envBool(key string) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(key))) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// WalkFunc 对每个非目录项调用一次。返回 fs.SkipAll 结束遍历（Walk 返回 nil），返回其它错误时 Walk 返回该错误。
+type WalkFunc func(path string, d fs.DirEntry) error
+
+// Walk 依次遍历 roots。ctx 结束时尽快返回 ctx.Err()。
+func Walk(ctx context.Context, roots []string, opts Options, fn WalkFunc) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := &walker{ctx: ctx, opts: opts, fn: fn, visited: make(map[fileID]bool), files: make(map[fileID]bool)}
+	if w.opts.Readers <= 0 {
+		w.opts.Readers = 4
+	}
+	w.sem = make(chan struct{}, w.opts.Readers)
+
+	if len(roots) > 1 || len(opts.SkipDirs) > 0 {
+		w.roots = make(map[string]bool, len(roots)+len(opts.SkipDirs))
+		for _, r := range append(append([]string(nil), roots...), opts.SkipDirs...) {
+			w.roots[caseFold(filepath.Clean(r))] = true
+		}
+	}
+	var dirs []dirTask
+	for _, root := range roots {
+		if w.opts.Filter.SkipRoot(root) {
+			continue
+		}
+		info, err := os.Lstat(root)
+		if err == nil && isLink(info.Mode()) {
+			// 根路径本身是链接时总是解析到目标
+			info, err = os.Stat(root)
+		}
+		if err != nil {
+			w.dirError(root, err)
+			continue
+		}
+		if !info.IsDir() {
+			// 根路径本身是文件时与 filepath.WalkDir 相同：直接回调
+			if !w.opts.Filter.keepFile(root) || !w.firstLink(root, fs.FileInfoToDirEntry(info)) {
+				continue
+			}
+			if err := fn(root, fs.FileInfoToDirEntry(info)); err != nil {
+				return w.result(err)
+			}
+			continue
+		}
+		var n node
+		if w.opts.FollowLinks || w.opts.OneFileSystem {
+			id, err := statID(root)
+			if err != nil {
+				w.dirError(root, err)
+				continue
+			}
+			if w.opts.FollowLinks && !w.visit(id) {
+				continue // 与之前的根是同一目录
+			}
+			n.dev = id.dev
+		}
+		if w.opts.Ordered {
+			if err := w.walkOrdered(root, w.prefetch(root), nil, n); err != nil {
+				return w.result(err)
+			}
+			continue
+		}
+		dirs = append(dirs, dirTask{dir: root, node: n})
+	}
+	if len(dirs) > 0 {
+		if err := w.walkUnordered(dirs); err != nil {
+			return w.result(err)
+		}
+	}
+	return ctx.Err()
+}
+
+type walker struct {
+	ctx  context.Context
+	opts Options
+	fn   WalkFunc
+	sem  chan struct{}
+
+	mu      sync.Mutex
+	visited map[fileID]bool
+	files   map[fileID]bool
+	// roots 为有多个根时各根（及 SkipDirs）的比较键：外层遍历遇到另一个根时跳过，由它自己的遍历负责
+	roots map[string]bool
+}
+
+// fileID 唯一标识一个目录：Unix 上为设备号与 inode，Windows 上为卷序列号与文件索引。
+type fileID struct {
+	dev, ino uint64
+}
+
+// node 为目录在遍历中的位置：深度（根为 0）与所在文件系统。
+type node struct {
+	depth int
+	dev   uint64
+}
+
+// dirTask 为待读目录、它的位置及其上级的 .ofindignore 规则链。
+type dirTask struct {
+	dir   string
+	node  node
+	scope *scope
+}
+
+func isLink(m fs.FileMode) bool {
+	// 较新的 Go 在 Windows 上把目录联接与卷挂载点报告为 ModeIrregular 而非 ModeSymlink
+	return m&(fs.ModeSymlink|fs.ModeIrregular) != 0
+}
+
+// visit 记录目录已遍历；已遍历过（经链接回到上级造成的循环，或多个链接指向同一目录）时返回 false。
+func (w *walker) visit(id fileID) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.visited[id] {
+		return false
+	}
+	w.visited[id] = true
+	return true
+}
+
+// firstLink 在开启 DedupFiles 时判断文件是否第一次遇到；没有其它硬链接的文件总是返回 true。
+func (w *walker) firstLink(path string, d fs.DirEntry) bool {
+	if !w.opts.DedupFiles {
+		return true
+	}
+	id, ok := linkedFileID(path, d)
+	if !ok {
+		return true
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.files[id] {
+		return false
+	}
+	w.files[id] = true
+	return true
+}
+
+// entry 决定目录 dir（位置 n）下的一项如何处理：返回要进入的子目录（enter 为 true 及其位置），
+// 或交给回调的文件（file 非空），或二者皆无表示跳过；err 为无法判断的子目录。
+func (w *walker) entry(n node, sc *scope, path string, e fs.DirEntry) (enter bool, child node, file fs.DirEntry, err error) {
+	f := w.opts.Filter
+	isDir, viaLink := e.IsDir(), false
+	if !isDir && w.opts.FollowLinks && isLink(e.Type()) {
+		info, serr := os.Stat(path)
+		if serr != nil {
+			return false, node{}, nil, nil // 失效的链接
+		}
+		isDir, viaLink = info.IsDir(), true
+		if !isDir {
+			e = fs.FileInfoToDirEntry(info)
+		}
+	}
+	if !isDir {
+		if f.skip(sc, path, false) || !f.keepFile(path) || !w.firstLink(path, e) {
+			return false, node{}, nil, nil
+		}
+		return false, node{}, e, nil
+	}
+	if f.skip(sc, path, true) || (w.opts.MaxDepth > 0 && n.depth+1 >= w.opts.MaxDepth) || w.roots[caseFold(path)] {
+		return false, node{}, nil, nil
+	}
+	child = node{depth: n.depth + 1, dev: n.dev}
+	if w.opts.FollowLinks || (w.opts.OneFileSystem && (viaLink || !mountsAreLinks)) {
+		id, err := statID(path)
+		if err != nil {
+			return false, node{}, nil, err
+		}
+		if w.opts.OneFileSystem && id.dev != n.dev {
+			return false, node{}, nil, nil
+		}
+		if w.opts.FollowLinks && !w.visit(id) {
+			return false, node{}, nil, nil
+		}
+	}
+	return true, child, nil, nil
+}
+
+func (w *walker) dirError(path string, err error) {
+	if w.opts.OnDirError != nil && w.ctx.Err() == nil {
+		w.opts.OnDirError(path, err)
+	}
+}
+
+func (w *walker) result(err error) error {
+	if errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// listing 为预先读取的目录内容。
+type listing struct {
+	entries []fs.DirEntry
+	err     error
+	done    chan struct{}
+}
+
+// prefetch 在读取者名额内后台读取目录。
+func (w *walker) prefetch(dir string) *listing {
+	l := &listing{done: make(chan struct{})}
+	go func() {
+		defer close(l.done)
+		select {
+		case w.sem <- struct{}{}:
+		case <-w.ctx.Done():
+			l.err = w.ctx.Err()
+			return
+		}
+		l.entries, l.err = os.ReadDir(dir)
+		<-w.sem
+	}()
+	return l
+}
+
+// walkOrdered 深度优先、按字典序回调；进入目录时预读它的全部子目录，使列目录与回调重叠进行。
+// 同时持有的目录内容只有当前路径上各层的子目录，内存随深度×扇出增长，而不是随整棵树。
+func (w *walker) walkOrdered(dir string, l *listing, parent *scope, n node) error {
+	select {
+	case <-l.done:
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	}
+	if l.err != nil {
+		if w.ctx.Err() != nil {
+			return w.ctx.Err()
+		}
+		w.dirError(dir, l.err)
+	}
+	sc := w.opts.Filter.enter(parent, dir, l.entries)
+	// 先决定每一项的去向并预读要进入的子目录，再按顺序回调与递归
+	type child struct {
+		l    *listing
+		node node
+		file fs.DirEntry
+	}
+	children := make([]child, len(l.entries))
+	for i, e := range l.entries {
+		path := filepath.Join(dir, e.Name())
+		enter, cn, file, err := w.entry(n, sc, path, e)
+		switch {
+		case err != nil:
+			w.dirError(path, err)
+		case enter:
+			children[i] = child{l: w.prefetch(path), node: cn}
+		default:
+			children[i].file = file
+		}
+	}
+	for i, e := range l.entries {
+		path := filepath.Join(dir, e.Name())
+		c := children[i]
+		if c.l != nil {
+			if err := w.walkOrdered(path, c.l, sc, c.node); err != nil {
+				return err
+			}
+			continue
+		}
+		if c.file == nil {
+			continue
+		}
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+		if err := w.fn(path, c.file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// item 为读取者交给回调 goroutine 的一项：文件，或无法读取的目录（err 非空）。
+type item struct {
+	path string
+	d    fs.DirEntry
+	err  error
+}
+
+// walkUnordered 由 Readers 个读取者从共享的目录栈取目录读取：子目录压栈供其它读取者继续，文件交给回调。
+func (w *walker) walkUnordered(roots []dirTask) error {
+	ctx, cancel := context.WithCancel(w.ctx)
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		cond   = sync.NewCond(&mu)
+		stack  []dirTask
+		active int
+	)
+	stack = append(stack, roots...)
+	f := w.opts.Filter
+	out := make(chan item, 256)
+	// 取消时唤醒等待中的读取者
+	go func() {
+		<-ctx.Done()
+		mu.Lock()
+		cond.Broadcast()
+		mu.Unlock()
+	}()
+
+	send := func(it item) bool {
+		select {
+		case out <- it:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	reader := func() {
+		for {
+			mu.Lock()
+			for len(stack) == 0 && active > 0 && ctx.Err() == nil {
+				cond.Wait()
+			}
+			if len(stack) == 0 || ctx.Err() != nil {
+				// 没有待读目录且没有读取者在工作（不会再有新目录），或已取消
+				cond.Broadcast()
+				mu.Unlock()
+				return
+			}
+			task := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			active++
+			mu.Unlock()
+
+			dir := task.dir
+			entries, err := os.ReadDir(dir)
+			sc := f.enter(task.scope, dir, entries)
+			var files []item
+			var subdirs []dirTask
+			for _, e := range entries {
+				path := filepath.Join(dir, e.Name())
+				enter, cn, file, err := w.entry(task.node, sc, path, e)
+				switch {
+				case err != nil:
+					files = append(files, item{path: path, err: err})
+				case enter:
+					subdirs = append(subdirs, dirTask{dir: path, node: cn, scope: sc})
+				case file != nil:
+					files = append(files, item{path: path, d: file})
+				}
+			}
+			mu.Lock()
+			// 逆序压栈，使出栈顺序大致保持字典序
+			for i := len(subdirs) - 1; i >= 0; i-- {
+				stack = append(stack, subdirs[i])
+			}
+			cond.Broadcast()
+			mu.Unlock()
+
+			ok := true
+			if err != nil {
+				ok = send(item{path: dir, err: err})
+			}
+			for _, f := range files {
+				if !ok {
+					break
+				}
+				ok = send(f)
+			}
+
+			mu.Lock()
+			active--
+			if active == 0 && len(stack) == 0 {
+				cond.Broadcast()
+			}
+			mu.Unlock()
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(w.opts.Readers)
+	for i := 0; i < w.opts.Readers; i++ {
+		go func() {
+			defer wg.Done()
+			reader()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	var walkErr error
+	for it := range out {
+		if walkErr != nil {
+			continue // 已出错：排空 out，让读取者退出
+		}
+		if it.err != nil {
+			w.dirError(it.path, it.err)
+			continue
+		}
+		if err := w.fn(it.path, it.d); err != nil {
+			walkErr = err
+			cancel()
+		}
+	}
+	if walkErr != nil {
+		return walkErr
+	}
+	return w.ctx.Err()
+}
@@ -0,0 +1,250 @@
+package fswalk
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func makeTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 3; j++ {
+			dir := filepath.Join(root, fmt.Sprintf("d%d", i), fmt.Sprintf("s%d", j))
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			for k := 0; k < 3; k++ {
+				if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%d.txt", k)), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("d%d", i), "top.txt"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func stdWalk(t *testing.T, root string) []string {
+	t.Helper()
+	var want []string
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			want = append(want, path)
+		}
+		return nil
+	})
+	return want
+}
+
+func TestWalk_OrderedMatchesWalkDir(t *testing.T) {
+	root := makeTree(t)
+	var got []string
+	err := Walk(context.Background(), []string{root}, Options{Readers: 3, Ordered: true}, func(path string, d fs.DirEntry) error {
+		got = append(got, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := stdWalk(t, root); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ordered walk differs from WalkDir:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestWalk_UnorderedVisitsAll(t *testing.T) {
+	root := makeTree(t)
+	var got []string
+	err := Walk(context.Background(), []string{root}, Options{Readers: 4}, func(path string, d fs.DirEntry) error {
+		got = append(got, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := stdWalk(t, root)
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %d files, want %d", len(got), len(want))
+	}
+}
+
+func TestWalk_ErrorsAndStop(t *testing.T) {
+	root := makeTree(t)
+	missing := filepath.Join(root, "missing")
+	for _, ordered := range []bool{false, true} {
+		var dirErrs []string
+		n := 0
+		err := Walk(context.Background(), []string{missing, root}, Options{
+			Ordered:    ordered,
+			OnDirError: func(path string, err error) { dirErrs = append(dirErrs, path) },
+		}, func(path string, d fs.DirEntry) error {
+			n++
+			if n == 5 {
+				return fs.SkipAll
+			}
+			return nil
+		})
+		if err != nil || n != 5 {
+			t.Fatalf("ordered=%v: err=%v n=%d, want stop after 5 files", ordered, err, n)
+		}
+		if !reflect.DeepEqual(dirErrs, []string{missing}) {
+			t.Fatalf("ordered=%v: dir errors %v", ordered, dirErrs)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Walk(ctx, []string{root}, Options{}, func(string, fs.DirEntry) error { return nil }); err != context.Canceled {
+		t.Fatalf("canceled walk: err = %v", err)
+	}
+}
+
+func TestWalk_MaxDepthAndLinks(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"a.txt":            "",
+		"d1/b.txt":         "",
+		"d1/d2/c.txt":      "",
+		"other/linked.txt": "",
+	})
+	if err := os.Symlink(filepath.Join(root, "other"), filepath.Join(root, "d1", "mnt")); err != nil {
+		t.Skipf("symlink: %v", err)
+	}
+	// 指回上级的链接：跟随时不能陷入循环
+	if err := os.Symlink(root, filepath.Join(root, "d1", "d2", "loop")); err != nil {
+		t.Fatal(err)
+	}
+	walk := func(root string, opts Options) []string {
+		t.Helper()
+		var got []string
+		for _, ordered := range []bool{false, true} {
+			opts.Ordered = ordered
+			var paths []string
+			err := Walk(context.Background(), []string{root}, opts, func(path string, d fs.DirEntry) error {
+				if d.Type()&fs.ModeSymlink == 0 {
+					rel, _ := filepath.Rel(root, path)
+					paths = append(paths, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(paths)
+			// 跟随链接时同一目录经哪条路径先到达与读取顺序有关，只比较不跟随的情况
+			if ordered && !opts.FollowLinks && !reflect.DeepEqual(paths, got) {
+				t.Fatalf("ordered %v != unordered %v", paths, got)
+			}
+			got = paths
+		}
+		return got
+	}
+
+	if got, want := walk(root, Options{MaxDepth: 2}), []string{"a.txt", "d1/b.txt", "other/linked.txt"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("max depth 2: got %v, want %v", got, want)
+	}
+	if got, want := walk(root, Options{}), []string{"a.txt", "d1/b.txt", "d1/d2/c.txt", "other/linked.txt"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("no follow: got %v, want %v", got, want)
+	}
+	// 跟随链接：other 只遍历一次（经 d1/mnt 或直接到达），loop 指回根不重复
+	got := walk(root, Options{FollowLinks: true})
+	if len(got) != 4 {
+		t.Fatalf("follow links: got %v", got)
+	}
+
+	// 根路径本身是链接时解析到目标
+	link := filepath.Join(t.TempDir(), "rootlink")
+	if err := os.Symlink(filepath.Join(root, "d1"), link); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := walk(link, Options{}), []string{"b.txt", "d2/c.txt"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("root link: got %v, want %v", got, want)
+	}
+}
+
+func TestCanonicalRootsAndHardLinks(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"docs/a.txt": "", "docs/sub/b.txt": "", "other/c.txt": ""})
+	docs := filepath.Join(root, "docs")
+	link := filepath.Join(t.TempDir(), "docs-link")
+	if err := os.Symlink(docs, link); err != nil {
+		t.Skipf("symlink: %v", err)
+	}
+	got := CanonicalRoots([]string{" " + docs + " ", "", filepath.Join(docs, "sub"), root, link, filepath.Join(root, "other") + string(filepath.Separator), root}, Options{})
+	if want := []string{root}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("CanonicalRoots = %v, want %v", got, want)
+	}
+	got = CanonicalRoots([]string{link, filepath.Join(root, "other"), docs}, Options{})
+	if want := []string{link, filepath.Join(root, "other")}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("CanonicalRoots = %v, want %v", got, want)
+	}
+
+	// 外层遍历不一定到达内层根时分开保留：深度限制、排除
+	if got := CanonicalRoots([]string{root, docs}, Options{MaxDepth: 2}); len(got) != 2 {
+		t.Fatalf("max depth: CanonicalRoots = %v, want both roots", got)
+	}
+	f, _ := NewFilter(nil, []string{"docs"}, false, false)
+	if got := CanonicalRoots([]string{root, filepath.Join(docs, "sub")}, Options{Filter: f}); len(got) != 2 {
+		t.Fatalf("excluded: CanonicalRoots = %v, want both roots", got)
+	}
+	// 分开保留的内层根只由它自己的遍历处理，文件不重复
+	for _, ordered := range []bool{false, true} {
+		var files []string
+		err := Walk(context.Background(), []string{root, docs}, Options{MaxDepth: 2, Ordered: ordered}, func(p string, d fs.DirEntry) error {
+			rel, _ := filepath.Rel(root, p)
+			files = append(files, filepath.ToSlash(rel))
+			return nil
+		})
+		sort.Strings(files)
+		if want := []string{"docs/a.txt", "docs/sub/b.txt", "other/c.txt"}; err != nil || !reflect.DeepEqual(files, want) {
+			t.Fatalf("ordered=%v: err=%v files %v, want %v", ordered, err, files, want)
+		}
+	}
+	// 各根分开遍历（每个根一个 daemon）时，外层根通过 SkipDirs 跳过内层根
+	for _, ordered := range []bool{false, true} {
+		var files []string
+		err := Walk(context.Background(), []string{root}, Options{MaxDepth: 2, Ordered: ordered, SkipDirs: []string{docs}}, func(p string, d fs.DirEntry) error {
+			rel, _ := filepath.Rel(root, p)
+			files = append(files, filepath.ToSlash(rel))
+			return nil
+		})
+		if want := []string{"other/c.txt"}; err != nil || !reflect.DeepEqual(files, want) {
+			t.Fatalf("skip dirs ordered=%v: err=%v files %v, want %v", ordered, err, files, want)
+		}
+	}
+
+	if err := os.Link(filepath.Join(root, "docs", "a.txt"), filepath.Join(root, "other", "a-hard.txt")); err != nil {
+		t.Skipf("hard link: %v", err)
+	}
+	for _, ordered := range []bool{false, true} {
+		n := 0
+		err := Walk(context.Background(), []string{root}, Options{Ordered: ordered, DedupFiles: true}, func(string, fs.DirEntry) error {
+			n++
+			return nil
+		})
+		if err != nil || n != 3 {
+			t.Fatalf("ordered=%v: err=%v, %d files, want 3 (hard links once)", ordered, err, n)
+		}
+	}
+}
+
+func TestOptionsFromEnv_OrderedByDefault(t *testing.T) {
+	t.Setenv("OFIND_WALK_ORDERED", "")
+	if !OptionsFromEnv().Ordered {
+		t.Fatal("default walk should be ordered (reproducible result order)")
+	}
+	t.Setenv("OFIND_WALK_ORDERED", "0")
+	if OptionsFromEnv().Ordered {
+		t.Fatal("OFIND_WALK_ORDERED=0 should select the unordered walk")
+	}
+}
@@ -0,0 +1,135 @@
+package fswalk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Paths 为路径来源（git ls-files 的输出、DMS 导出的清单、find 的结果等）：依次对每个路径调用 yield，
+// yield 返回错误时停止并返回该错误。
+type Paths func(yield func(path string) error) error
+
+// PathList 返回切片的路径来源。
+func PathList(list []string) Paths {
+	return func(yield func(string) error) error {
+		for _, p := range list {
+			if err := yield(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// ReadPaths 返回从 r 读取的路径来源：每行一个路径（忽略空行与行尾 \r），
+// 或在读到的内容中出现 NUL 时按 NUL 分隔（find -print0、git ls-files -z），此时路径可以含换行。
+func ReadPaths(r io.Reader) Paths {
+	return func(yield func(string) error) error {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64<<10), 1<<20)
+		nul, decided := false, false
+		sc.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			if !decided {
+				if bytes.IndexByte(data, 0) >= 0 {
+					nul, decided = true, true
+				} else if bytes.IndexByte(data, '\n') >= 0 || atEOF {
+					decided = true
+				} else {
+					return 0, nil, nil // 还没读到分隔符，继续读
+				}
+			}
+			sep := byte('\n')
+			if nul {
+				sep = 0
+			}
+			if i := bytes.IndexByte(data, sep); i >= 0 {
+				return i + 1, data[:i], nil
+			}
+			if atEOF && len(data) > 0 {
+				return len(data), data, nil
+			}
+			return 0, nil, nil
+		})
+		first := true
+		for sc.Scan() {
+			p := sc.Text()
+			if first {
+				p = strings.TrimPrefix(p, "\ufeff") // 记事本等保存的 UTF-8 BOM
+				first = false
+			}
+			if !nul {
+				p = strings.TrimSuffix(p, "\r")
+			}
+			if strings.TrimSpace(p) == "" {
+				continue
+			}
+			if err := yield(p); err != nil {
+				return err
+			}
+		}
+		return sc.Err()
+	}
+}
+
+// OpenPaths 打开文件列表；name 为 "-" 时读取标准输入。返回的 close 在用完后调用。
+func OpenPaths(name string) (Paths, func() error, error) {
+	if name == "-" {
+		return ReadPaths(os.Stdin), func() error { return nil }, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ReadPaths(bufio.NewReader(f)), f.Close, nil
+}
+
+// WalkPaths 与 Walk 相同地回调 src 给出的文件，但不遍历目录：相对路径按当前目录转为绝对路径，
+// 重复的路径与（开启 DedupFiles 时）同一文件的硬链接只回调一次，Filter 的包含/排除模式与默认忽略照常生效
+// （不读取 .ofindignore）。列表中的目录被跳过；无法访问的路径交给 OnDirError。
+func WalkPaths(ctx context.Context, src Paths, opts Options, fn WalkFunc) error {
+	w := &walker{ctx: ctx, opts: opts, fn: fn, files: make(map[fileID]bool)}
+	seen := make(map[string]bool)
+	err := src(func(p string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		key := caseFold(p)
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+		if !opts.Filter.MatchFile(p) {
+			return nil
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			w.dirError(p, err)
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		d := fs.FileInfoToDirEntry(info)
+		if !w.firstLink(p, d) {
+			return nil
+		}
+		return fn(p, d)
+	})
+	if errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
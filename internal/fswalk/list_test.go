@@ -0,0 +1,67 @@
+package fswalk
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, src Paths) []string {
+	t.Helper()
+	var got []string
+	if err := src(func(p string) error {
+		got = append(got, p)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestReadPaths(t *testing.T) {
+	lines := readAll(t, ReadPaths(strings.NewReader("\ufeffa.docx\r\n\r\nsub/b c.pdf\nlast.txt")))
+	if want := []string{"a.docx", "sub/b c.pdf", "last.txt"}; !reflect.DeepEqual(lines, want) {
+		t.Fatalf("lines: got %q, want %q", lines, want)
+	}
+	nul := readAll(t, ReadPaths(strings.NewReader("a.docx\x00with\nnewline.txt\x00\x00")))
+	if want := []string{"a.docx", "with\nnewline.txt"}; !reflect.DeepEqual(nul, want) {
+		t.Fatalf("nul: got %q, want %q", nul, want)
+	}
+}
+
+func TestWalkPaths(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a.docx": "", "node_modules/x.txt": "", "skip.tmp": "", "dir/b.txt": ""})
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	f, _ := NewFilter(nil, []string{"*.tmp"}, true, false)
+	list := []string{"a.docx", filepath.Join(root, "a.docx"), "node_modules/x.txt", "skip.tmp", "dir", "dir/b.txt", "missing.pdf"}
+	var got, missing []string
+	err = WalkPaths(context.Background(), PathList(list), Options{Filter: f, OnDirError: func(p string, err error) {
+		missing = append(missing, filepath.Base(p))
+	}}, func(p string, d fs.DirEntry) error {
+		rel, _ := filepath.Rel(root, p)
+		got = append(got, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.docx", "dir/b.txt"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(missing, []string{"missing.pdf"}) {
+		t.Fatalf("missing: %v", missing)
+	}
+}
@@ -0,0 +1,156 @@
+package fswalk
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Meta 为文件元数据条件，在遍历时（提取之前）判断，不满足的文件不进入提取；零值不过滤。
+type Meta struct {
+	// Exts 为允许的扩展名（小写、带点），空表示不限
+	Exts []string `json:"exts,omitempty"`
+	// MinSize/MaxSize 为文件大小范围（字节，含边界），0 表示不限
+	MinSize int64 `json:"minSize,omitempty"`
+	MaxSize int64 `json:"maxSize,omitempty"`
+	// Newer 非零时只保留修改时间不早于它的文件；Older 非零时只保留修改时间早于它的文件
+	Newer time.Time `json:"newer"`
+	Older time.Time `json:"older"`
+}
+
+// Empty 表示没有任何条件。
+func (m Meta) Empty() bool {
+	return len(m.Exts) == 0 && m.MinSize == 0 && m.MaxSize == 0 && m.Newer.IsZero() && m.Older.IsZero()
+}
+
+// HasExt 判断 ext（小写、带点）是否为显式指定的扩展名；未指定扩展名条件时返回 false。
+func (m Meta) HasExt(ext string) bool {
+	for _, e := range m.Exts {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// Match 判断目录项是否满足条件；只有设置了大小或时间条件时才读取文件信息。
+func (m Meta) Match(d fs.DirEntry) bool {
+	if len(m.Exts) > 0 && !m.HasExt(strings.ToLower(filepath.Ext(d.Name()))) {
+		return false
+	}
+	if m.MinSize == 0 && m.MaxSize == 0 && m.Newer.IsZero() && m.Older.IsZero() {
+		return true
+	}
+	info, err := d.Info()
+	if err != nil {
+		return false
+	}
+	if size := info.Size(); (m.MinSize > 0 && size < m.MinSize) || (m.MaxSize > 0 && size > m.MaxSize) {
+		return false
+	}
+	mod := info.ModTime()
+	if !m.Newer.IsZero() && mod.Before(m.Newer) {
+		return false
+	}
+	if !m.Older.IsZero() && !mod.Before(m.Older) {
+		return false
+	}
+	return true
+}
+
+// ParseExts 解析逗号分隔的扩展名列表（"docx,pdf"、".docx"、"*.pdf" 均可），返回小写、带点的形式。
+func ParseExts(s string) []string {
+	var out []string
+	for _, e := range strings.Split(s, ",") {
+		e = strings.ToLower(strings.TrimSpace(e))
+		e = strings.TrimPrefix(e, "*")
+		if e == "" || e == "." {
+			continue
+		}
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// ParseSize 解析文件大小：纯数字为字节，支持 K/KB、M/MB、G/GB 后缀（1024 进制，不区分大小写），如 "500K"、"1.5GB"。
+func ParseSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	if v == "" {
+		return 0, nil
+	}
+	v = strings.TrimSuffix(v, "B")
+	mult := float64(1)
+	switch {
+	case strings.HasSuffix(v, "K"):
+		mult, v = 1<<10, strings.TrimSuffix(v, "K")
+	case strings.HasSuffix(v, "M"):
+		mult, v = 1<<20, strings.TrimSuffix(v, "M")
+	case strings.HasSuffix(v, "G"):
+		mult, v = 1<<30, strings.TrimSuffix(v, "G")
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("无效的文件大小 %q（如 500K、10MB、1.5GB）", s)
+	}
+	return int64(n * mult), nil
+}
+
+// ParseTime 解析时间点：
+//   - 日期/时间："2024-03-31"、"2024-03-31 18:00"、RFC3339（本地时区）
+//   - 距今时长："30d"、"2w"、"12h"、"90m"（即 now 之前多久）
+//   - 当前周期的开始："today"、"week"（周一）、"month"、"quarter"、"year"
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	if v == "" {
+		return time.Time{}, nil
+	}
+	y, mo, d := now.Date()
+	loc := now.Location()
+	switch v {
+	case "today":
+		return time.Date(y, mo, d, 0, 0, 0, 0, loc), nil
+	case "week":
+		offset := (int(now.Weekday()) + 6) % 7
+		return time.Date(y, mo, d-offset, 0, 0, 0, 0, loc), nil
+	case "month":
+		return time.Date(y, mo, 1, 0, 0, 0, 0, loc), nil
+	case "quarter":
+		return time.Date(y, mo-(mo-1)%3, 1, 0, 0, 0, 0, loc), nil
+	case "year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc), nil
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(s), loc); err == nil {
+			return t, nil
+		}
+	}
+	if i := strings.IndexFunc(v, unicode.IsLetter); i > 0 {
+		if n, err := strconv.ParseFloat(v[:i], 64); err == nil && n >= 0 {
+			var unit time.Duration
+			switch v[i:] {
+			case "d":
+				unit = 24 * time.Hour
+			case "w":
+				unit = 7 * 24 * time.Hour
+			case "h":
+				unit = time.Hour
+			case "m":
+				unit = time.Minute
+			}
+			if unit > 0 {
+				return now.Add(-time.Duration(n * float64(unit))), nil
+			}
+		}
+	}
+	if dur, err := time.ParseDuration(v); err == nil {
+		return now.Add(-dur), nil
+	}
+	return time.Time{}, fmt.Errorf("无效的时间 %q（如 2024-03-31、30d、quarter）", s)
+}
@@ -0,0 +1,87 @@
+package fswalk
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseMeta(t *testing.T) {
+	if got := ParseExts(" DOCX, .pdf,*.Txt,,"); !reflect.DeepEqual(got, []string{".docx", ".pdf", ".txt"}) {
+		t.Fatalf("ParseExts = %v", got)
+	}
+	for in, want := range map[string]int64{"": 0, "1024": 1024, "500k": 500 << 10, "10MB": 10 << 20, "1.5G": 3 << 29} {
+		if got, err := ParseSize(in); err != nil || got != want {
+			t.Fatalf("ParseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	if _, err := ParseSize("10XB"); err == nil {
+		t.Fatal("ParseSize(10XB) should fail")
+	}
+
+	now := time.Date(2024, 5, 15, 10, 30, 0, 0, time.Local) // 周三
+	cases := map[string]time.Time{
+		"2024-03-31":       time.Date(2024, 3, 31, 0, 0, 0, 0, time.Local),
+		"2024-03-31 18:00": time.Date(2024, 3, 31, 18, 0, 0, 0, time.Local),
+		"30d":              now.Add(-30 * 24 * time.Hour),
+		"2w":               now.Add(-14 * 24 * time.Hour),
+		"90m":              now.Add(-90 * time.Minute),
+		"1h30m":            now.Add(-90 * time.Minute),
+		"week":             time.Date(2024, 5, 13, 0, 0, 0, 0, time.Local),
+		"quarter":          time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local),
+		"Year":             time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local),
+	}
+	for in, want := range cases {
+		if got, err := ParseTime(in, now); err != nil || !got.Equal(want) {
+			t.Fatalf("ParseTime(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseTime("last tuesday", now); err == nil {
+		t.Fatal("ParseTime(last tuesday) should fail")
+	}
+}
+
+func TestMeta_Match(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"small.docx": "x",
+		"big.docx":   string(make([]byte, 4096)),
+		"old.pdf":    "xx",
+		"note.txt":   "xx",
+	})
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "old.pdf"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	m := Meta{Exts: []string{".docx", ".pdf"}, MaxSize: 1024, Newer: time.Now().Add(-30 * 24 * time.Hour)}
+	if got, want := walkMeta(t, root, m), []string{"small.docx"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	m = Meta{MinSize: 2, Older: time.Now().Add(-30 * 24 * time.Hour)}
+	if got, want := walkMeta(t, root, m), []string{"old.pdf"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if !(Meta{}).Empty() || len(walkMeta(t, root, Meta{})) != 4 {
+		t.Fatal("zero Meta should not filter")
+	}
+	if m := (Meta{Exts: ParseExts("out")}); !m.HasExt(".out") || m.HasExt(".txt") || (Meta{}).HasExt(".out") {
+		t.Fatal("HasExt should report only explicitly listed extensions")
+	}
+}
+
+func walkMeta(t *testing.T, root string, m Meta) []string {
+	t.Helper()
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		if m.Match(e) {
+			got = append(got, e.Name())
+		}
+	}
+	return got
+}
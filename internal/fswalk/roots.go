@@ -0,0 +1,102 @@
+package fswalk
+
+import (
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+type canonRoot struct {
+	path  string // 规范化后的写法（用于遍历与显示）
+	real  string // 解析链接后的路径
+	key   string // real 的比较键
+	id    fileID
+	hasID bool
+	idx   int
+}
+
+// CanonicalRoots 规范化根目录列表：转为绝对路径（Windows 上 "D:" 视为盘符根目录、盘符大写），
+// 去掉重复的根、以其它写法指向同一目录的根（按文件标识），以及位于其它根之下（解析链接后判断）
+// 且按 opts 从外层根遍历一定会到达的根。外层遍历不一定到达时（深度限制、跨文件系统、被排除或默认忽略），
+// 内层根保留为单独的根。比较在 Windows 上不区分大小写；保留的根按输入顺序返回，写法取第一次出现的。
+func CanonicalRoots(roots []string, opts Options) []string {
+	var list []canonRoot
+	for _, r := range roots {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		if runtime.GOOS == "windows" && len(r) == 2 && r[1] == ':' {
+			r += `\` // "D:" 表示盘符根目录，而不是该盘的当前目录
+		}
+		if abs, err := filepath.Abs(r); err == nil {
+			r = abs
+		}
+		if len(r) >= 2 && r[1] == ':' {
+			r = strings.ToUpper(r[:1]) + r[1:]
+		}
+		// 嵌套按解析链接后的路径判断：D:\Link 指向 D:\Docs\Sub 时同样位于 D:\Docs 之下
+		real := r
+		if p, err := filepath.EvalSymlinks(r); err == nil {
+			real = p
+		}
+		rt := canonRoot{path: r, real: real, key: strings.TrimSuffix(caseFold(filepath.ToSlash(real)), "/"), idx: len(list)}
+		if id, err := statID(r); err == nil {
+			rt.id, rt.hasID = id, true
+		}
+		list = append(list, rt)
+	}
+
+	// 短的在前：嵌套的根只需与已保留的比较
+	sort.SliceStable(list, func(i, j int) bool { return len(list[i].key) < len(list[j].key) })
+	var kept []canonRoot
+next:
+	for _, r := range list {
+		for _, k := range kept {
+			if r.key == k.key || (r.hasID && k.hasID && r.id == k.id) {
+				continue next
+			}
+			if strings.HasPrefix(r.key, k.key+"/") && reaches(k, r, opts) {
+				continue next
+			}
+		}
+		kept = append(kept, r)
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].idx < kept[j].idx })
+	out := make([]string, len(kept))
+	for i, r := range kept {
+		out[i] = r.path
+	}
+	return out
+}
+
+// reaches 判断从 outer 开始的遍历在 opts 下是否一定会进入位于其下的 inner。
+func reaches(outer, inner canonRoot, opts Options) bool {
+	if opts.MaxDepth > 0 {
+		// 深度从各自的根算起：单独遍历时 inner 之下能比从 outer 到达时多走几层
+		return false
+	}
+	if opts.OneFileSystem && (!outer.hasID || !inner.hasID || outer.id.dev != inner.id.dev) {
+		return false
+	}
+	f := opts.Filter
+	if f == nil {
+		return true
+	}
+	rel, err := filepath.Rel(outer.real, inner.real)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	// 按外层遍历看到的路径逐级检查排除模式、默认忽略与沿途的 .ofindignore
+	dir := outer.path
+	var sc *scope
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		sc = f.enterDir(sc, dir)
+		dir = filepath.Join(dir, name)
+		if f.skip(sc, dir, true) {
+			return false
+		}
+	}
+	return true
+}
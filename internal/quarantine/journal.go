@@ -0,0 +1,102 @@
+package quarantine
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 崩溃检测：daemon 定期把正在处理的文件写到 journal（<列表文件>.inflight-<pid>）；
+// 父进程发现 daemon 意外退出时读取该 journal，把其中的文件记为 ReasonCrash。正常关闭时删除 journal。
+
+// JournalPath 返回 pid 对应的 journal 文件。
+func JournalPath(storePath string, pid int) string {
+	return storePath + ".inflight-" + strconv.Itoa(pid)
+}
+
+// Begin 标记 path 开始处理。
+func (s *Store) Begin(path string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.inflight[path] = time.Now()
+	s.journal = true
+	s.mu.Unlock()
+}
+
+// End 标记 path 处理结束。
+func (s *Store) End(path string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	delete(s.inflight, path)
+	s.journal = true
+	s.mu.Unlock()
+}
+
+// WriteJournal 在在处理集合有变化时重写本进程的 journal；集合为空时删除 journal。
+func (s *Store) WriteJournal() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	if !s.journal {
+		s.mu.Unlock()
+		return nil
+	}
+	s.journal = false
+	paths := make([]string, 0, len(s.inflight))
+	for p := range s.inflight {
+		paths = append(paths, p)
+	}
+	s.mu.Unlock()
+
+	jp := JournalPath(s.path, os.Getpid())
+	if len(paths) == 0 {
+		if err := os.Remove(jp); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	sort.Strings(paths)
+	return writeFileAtomic(jp, []byte(strings.Join(paths, "\n")+"\n"))
+}
+
+// AdoptJournal 读取已崩溃进程 pid 的 journal，把其中的文件记为 ReasonCrash 并删除 journal；返回记录的文件数。
+func (s *Store) AdoptJournal(pid int) (int, error) {
+	jp := JournalPath(s.path, pid)
+	b, err := os.ReadFile(jp)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, p := range strings.Split(string(b), "\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			s.Record(p, ReasonCrash)
+			n++
+		}
+	}
+	_ = os.Remove(jp)
+	return n, nil
+}
+
+// RemoveJournal 删除 pid 的 journal（进程被正常关闭或主动结束时）。
+func RemoveJournal(storePath string, pid int) {
+	_ = os.Remove(JournalPath(storePath, pid))
+}
+
+// CloseJournal 删除本进程的 journal（daemon 正常退出时）。
+func (s *Store) CloseJournal() {
+	if s == nil {
+		return
+	}
+	RemoveJournal(s.path, os.Getpid())
+}
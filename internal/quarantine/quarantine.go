@@ -0,0 +1,268 @@
+package quarantine
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 隔离列表：使提取子进程超出内存上限、反复超时或使 daemon（子进程）崩溃的文件，在之后若干次查询中直接跳过，
+// 避免一个坏文件让某个根目录的每次搜索都不可用。条目以路径 + 大小 + 修改时间为键，文件改动后自动失效。
+// 多个 daemon（每个根目录一个）共用同一个文件，Save 时与磁盘内容合并。
+
+const (
+	ReasonTimeout = "timeout"
+	ReasonMemory  = "memory"
+	ReasonCrash   = "crash"
+)
+
+type Entry struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"` // UnixNano
+	Reason  string `json:"reason"`
+	// Strikes 为累计记录次数；超时需达到 OFIND_QUARANTINE_TIMEOUTS 次才隔离
+	Strikes int `json:"strikes"`
+	// SkipRuns 为剩余跳过的查询次数；0 表示当前未隔离（仅保留记录）
+	SkipRuns int       `json:"skipRuns"`
+	At       time.Time `json:"at"`
+	// LastRun 为最近一次消耗 SkipRuns 的查询；同一查询（多个关键词、多个 daemon）只消耗一次
+	LastRun string `json:"lastRun,omitempty"`
+}
+
+// Quarantined 表示该文件当前处于隔离中。
+func (e Entry) Quarantined() bool { return e.SkipRuns > 0 }
+
+type Store struct {
+	path     string
+	runs     int
+	timeouts int
+
+	mu       sync.Mutex
+	entries  map[string]*Entry
+	dirty    map[string]bool // 本进程改动（含删除）过的路径，Save 时覆盖磁盘内容
+	inflight map[string]time.Time
+	journal  bool // 在处理的文件集合有变化，需要重写 journal
+}
+
+// Enabled 判断是否启用隔离列表（OFIND_QUARANTINE=0 关闭）。
+func Enabled() bool {
+	return strings.TrimSpace(os.Getenv("OFIND_QUARANTINE")) != "0"
+}
+
+// DefaultPath 返回隔离列表文件：OFIND_QUARANTINE_FILE，默认用户缓存目录下 office_find_item/quarantine.json。
+func DefaultPath() string {
+	if p := strings.TrimSpace(os.Getenv("OFIND_QUARANTINE_FILE")); p != "" {
+		return p
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "office_find_item", "quarantine.json")
+}
+
+func envInt(key string, def int) int {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return def
+}
+
+// Open 读取隔离列表；文件不存在时返回空列表。
+// 记录、检查与 journal 相关方法允许 nil *Store（未启用隔离时），此时什么也不做。
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:     path,
+		runs:     envInt("OFIND_QUARANTINE_RUNS", 20),
+		timeouts: envInt("OFIND_QUARANTINE_TIMEOUTS", 2),
+		dirty:    map[string]bool{},
+		inflight: map[string]time.Time{},
+	}
+	entries, err := readEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	s.entries = entries
+	return s, nil
+}
+
+func readEntries(path string) (map[string]*Entry, error) {
+	out := map[string]*Entry{}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return out, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Entry
+	if err := json.Unmarshal(b, &list); err != nil {
+		return out, nil // 损坏的列表视为空，下次 Save 覆盖
+	}
+	for i := range list {
+		e := list[i]
+		out[e.Path] = &e
+	}
+	return out, nil
+}
+
+// Entries 返回全部条目（按路径排序）。
+func (s *Store) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+func fileKey(path string) (int64, int64, bool) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	return st.Size(), st.ModTime().UnixNano(), true
+}
+
+// Check 判断查询 run 是否应跳过 path；每个 run 第一次跳过时消耗一次 SkipRuns，同一 run 再次检查
+// 仍跳过但不再消耗（run 为空时每次检查都消耗）。文件已改动或已删除时丢弃条目。
+// 没有条目的路径不做 stat，开销只有一次 map 查找。
+func (s *Store) Check(path string, run string) (Entry, bool) {
+	if s == nil {
+		return Entry{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entries[path]
+	counted := e != nil && run != "" && e.LastRun == run
+	if e == nil || (!e.Quarantined() && !counted) {
+		return Entry{}, false
+	}
+	size, mtime, ok := fileKey(path)
+	if !ok || size != e.Size || mtime != e.ModTime {
+		delete(s.entries, path)
+		s.dirty[path] = true
+		return Entry{}, false
+	}
+	if !counted {
+		e.SkipRuns--
+		e.LastRun = run
+		s.dirty[path] = true
+	}
+	return *e, true
+}
+
+// Record 记录 path 一次超时/内存超限/崩溃；内存与崩溃立即隔离，超时累计到阈值才隔离。
+func (s *Store) Record(path string, reason string) {
+	if s == nil {
+		return
+	}
+	size, mtime, ok := fileKey(path)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entries[path]
+	if e == nil || e.Size != size || e.ModTime != mtime {
+		e = &Entry{Path: path, Size: size, ModTime: mtime}
+		s.entries[path] = e
+	}
+	e.Strikes++
+	e.Reason = reason
+	e.LastRun = ""
+	e.At = time.Now()
+	if reason != ReasonTimeout || e.Strikes >= s.timeouts {
+		e.SkipRuns = s.runs
+	}
+	s.dirty[path] = true
+}
+
+// Clear 移除 path 的条目；path 为空时清空全部。返回移除的条目数。
+func (s *Store) Clear(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if path != "" {
+		if _, ok := s.entries[path]; !ok {
+			return 0
+		}
+		delete(s.entries, path)
+		s.dirty[path] = true
+		return 1
+	}
+	n := len(s.entries)
+	for p := range s.entries {
+		s.dirty[p] = true
+	}
+	s.entries = map[string]*Entry{}
+	return n
+}
+
+// Save 把本进程的改动合并到磁盘上的列表（其它 daemon 的改动保留），以临时文件 + 改名写入。
+func (s *Store) Save() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.dirty) == 0 {
+		return nil
+	}
+	disk, err := readEntries(s.path)
+	if err != nil {
+		return err
+	}
+	for p := range s.dirty {
+		if e := s.entries[p]; e != nil {
+			disk[p] = e
+		} else {
+			delete(disk, p)
+		}
+	}
+	for p, e := range disk {
+		if s.entries[p] == nil && !s.dirty[p] {
+			s.entries[p] = e
+		}
+	}
+	list := make([]Entry, 0, len(disk))
+	for _, e := range disk {
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, b); err != nil {
+		return err
+	}
+	s.dirty = map[string]bool{}
+	return nil
+}
+
+func writeFileAtomic(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp" + strconv.Itoa(os.Getpid())
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package quarantine
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStore_RecordCheckAndExpire(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("OFIND_QUARANTINE_RUNS", "2")
+	t.Setenv("OFIND_QUARANTINE_TIMEOUTS", "2")
+	bad := filepath.Join(dir, "bad.pdf")
+	slow := filepath.Join(dir, "slow.pdf")
+	for _, p := range []string{bad, slow} {
+		if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	listPath := filepath.Join(dir, "q.json")
+	s, err := Open(listPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Record(bad, ReasonMemory)
+	s.Record(slow, ReasonTimeout)
+	if _, skip := s.Check(slow, "r0"); skip {
+		t.Fatal("one timeout should not quarantine")
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 另一个进程（重新打开）看得到记录，且跳过次数按 Check 消耗
+	s2, err := Open(listPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		run := "r" + strconv.Itoa(i+1)
+		// 同一次查询内多次检查（多个关键词）只消耗一次
+		for j := 0; j < 3; j++ {
+			if e, skip := s2.Check(bad, run); !skip || e.Reason != ReasonMemory {
+				t.Fatalf("run %d/%d: skip=%v entry=%+v", i, j, skip, e)
+			}
+		}
+	}
+	if _, skip := s2.Check(bad, "r3"); skip {
+		t.Fatal("quarantine should expire after OFIND_QUARANTINE_RUNS checks")
+	}
+	s2.Record(slow, ReasonTimeout)
+	if _, skip := s2.Check(slow, "r3"); !skip {
+		t.Fatal("second timeout should quarantine")
+	}
+
+	// 文件改动后条目失效
+	s2.Record(bad, ReasonCrash)
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(bad, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, skip := s2.Check(bad, "r4"); skip {
+		t.Fatal("modified file should not be skipped")
+	}
+	if n := s2.Clear(""); n != 1 {
+		t.Fatalf("Clear removed %d, want 1 (slow)", n)
+	}
+}
+
+func TestStore_JournalAdoptedAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "crash.doc")
+	if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	listPath := filepath.Join(dir, "q.json")
+	daemon, _ := Open(listPath)
+	daemon.Begin(p)
+	if err := daemon.WriteJournal(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(JournalPath(listPath, os.Getpid())); err != nil {
+		t.Fatalf("journal not written: %v", err)
+	}
+
+	parent, _ := Open(listPath)
+	if n, err := parent.AdoptJournal(os.Getpid()); n != 1 || err != nil {
+		t.Fatalf("AdoptJournal = %d, %v", n, err)
+	}
+	if e, skip := parent.Check(p, "r1"); !skip || e.Reason != ReasonCrash {
+		t.Fatalf("skip=%v entry=%+v", skip, e)
+	}
+	if _, err := os.Stat(JournalPath(listPath, os.Getpid())); !os.IsNotExist(err) {
+		t.Fatalf("journal not removed: %v", err)
+	}
+}
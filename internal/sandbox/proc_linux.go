@@ -0,0 +1,20 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func prepareCmd(cmd *exec.Cmd) {
+	// 父进程退出时子进程随之结束
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+}
+
+// setMemLimit 用 RLIMIT_DATA 限制可写私有映射（Go 堆）的总量；留出余量给运行时本身。
+// 超出时内存分配失败，运行时以 "out of memory" 退出，父进程据此报告超出内存上限。
+func setMemLimit(limit int64) {
+	lim := uint64(limit) + 256<<20
+	_ = syscall.Setrlimit(syscall.RLIMIT_DATA, &syscall.Rlimit{Cur: lim, Max: lim})
+}
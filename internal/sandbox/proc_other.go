@@ -0,0 +1,9 @@
+//go:build !windows && !linux
+
+package sandbox
+
+import "os/exec"
+
+func prepareCmd(cmd *exec.Cmd) {}
+
+func setMemLimit(limit int64) {}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package sandbox
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// prepareCmd 隐藏子进程窗口（GUI 子系统的 ofind.exe 启动子进程时不弹黑框）。
+func prepareCmd(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+}
+
+// setMemLimit 在 Windows 上不设置硬限制，依赖 watchMemory 看门狗。
+func setMemLimit(limit int64) {}
@@ -0,0 +1,424 @@
+package sandbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"office_find_item/internal/extract"
+)
+
+// 提取沙箱：高风险格式（如纯 Go PDF 解析可能吃掉数 GB 内存）交给常驻的提取子进程（ofind -extract-worker）处理，
+// 每个子进程有自己的内存上限（Linux 上为 RLIMIT_DATA，另有自检看门狗），超限、超时或崩溃时只损失这一个子进程，
+// 池会在下次需要时自动重启，查询本身继续。
+//
+// 协议：父进程向子进程 stdin 写一行 JSON 请求，子进程处理完在 stdout 写一行 JSON 响应；每个子进程同一时间只处理一个文件。
+
+// exitMemory 为子进程看门狗发现内存超限时的退出码。
+const exitMemory = 75
+
+type request struct {
+	ID          uint64 `json:"id"`
+	Path        string `json:"path"`
+	Query       string `json:"query"`
+	ContextLen  int    `json:"contextLen"`
+	PDFBackends string `json:"pdfBackends,omitempty"`
+	// Format 为父进程已解析出的格式（extract.FileFormat），子进程不再读文件头；空表示由子进程判断
+	Format string `json:"format,omitempty"`
+}
+
+type response struct {
+	ID        uint64         `json:"id"`
+	Found     bool           `json:"found,omitempty"`
+	Snippet   string         `json:"snippet,omitempty"`
+	Status    extract.Status `json:"status,omitempty"`
+	Err       string         `json:"err,omitempty"`
+	Format    string         `json:"format,omitempty"`
+	Backend   string         `json:"backend,omitempty"`
+	Truncated string         `json:"truncated,omitempty"`
+	Encrypted bool           `json:"encrypted,omitempty"`
+}
+
+// MemLimitMB 返回每个提取子进程的内存上限（OFIND_SANDBOX_MEM_MB），默认 64 位 1024 MiB、32 位 512 MiB。
+func MemLimitMB() int64 {
+	if v := strings.TrimSpace(os.Getenv("OFIND_SANDBOX_MEM_MB")); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	if runtime.GOARCH == "386" {
+		return 512
+	}
+	return 1024
+}
+
+// Serve 为 -extract-worker 子进程的主循环：逐行读取请求并提取，stdin 关闭时返回。
+func Serve(r io.Reader, w io.Writer) error {
+	limit := MemLimitMB() * 1024 * 1024
+	setMemLimit(limit)
+	debug.SetMemoryLimit(limit * 3 / 4)
+	go watchMemory(uint64(limit))
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+	enc := json.NewEncoder(w)
+	for {
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		ctx, trace := extract.WithTrace(extract.WithPDFBackends(context.Background(), req.PDFBackends))
+		var (
+			found   bool
+			snippet string
+			err     error
+		)
+		if req.Format != "" {
+			found, snippet, err = extract.FileFindFirstAs(ctx, req.Format, req.Path, req.Query, req.ContextLen)
+		} else {
+			found, snippet, err = extract.FileFindFirst(ctx, req.Path, req.Query, req.ContextLen)
+		}
+		resp := response{
+			ID:        req.ID,
+			Found:     found,
+			Snippet:   snippet,
+			Format:    trace.Format,
+			Backend:   trace.Backend,
+			Truncated: trace.Truncated,
+			Encrypted: trace.Encrypted,
+		}
+		if err != nil {
+			resp.Status, resp.Err = extract.StatusOf(err), err.Error()
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+		debug.FreeOSMemory()
+	}
+}
+
+// watchMemory 在堆超过上限时直接退出（rlimit 之外的第二道防线，也覆盖没有 rlimit 的 Windows）。
+func watchMemory(limit uint64) {
+	for range time.Tick(200 * time.Millisecond) {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		if m.HeapAlloc > limit {
+			fmt.Fprintf(os.Stderr, "extract-worker: heap %d MiB > limit %d MiB\n", m.HeapAlloc>>20, limit>>20)
+			os.Exit(exitMemory)
+		}
+	}
+}
+
+// ErrHelperCrashed 表示提取子进程在处理文件时异常退出（多为文件触发了解析器缺陷）。
+var ErrHelperCrashed = fmt.Errorf("%w（沙箱）", extract.ErrCrashed)
+
+var errHelperStart = errors.New("无法启动提取子进程")
+
+// ErrHelperMemory 表示提取子进程超出内存上限被结束。
+var ErrHelperMemory = fmt.Errorf("%w（OFIND_SANDBOX_MEM_MB）", extract.ErrOutOfMemory)
+
+type helper struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	dec    *json.Decoder
+	stderr *tailBuffer
+	exited chan struct{}
+	nextID uint64
+}
+
+func startHelper(exe string) (*helper, error) {
+	cmd := exec.Command(exe, "-extract-worker")
+	cmd.Env = os.Environ()
+	prepareCmd(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		_ = stdin.Close()
+		return nil, err
+	}
+	h := &helper{cmd: cmd, stdin: stdin, stderr: &tailBuffer{max: 64 << 10}, exited: make(chan struct{})}
+	cmd.Stderr = h.stderr
+	if err := cmd.Start(); err != nil {
+		_ = stdin.Close()
+		return nil, err
+	}
+	h.dec = json.NewDecoder(bufio.NewReader(stdout))
+	go func() {
+		_ = cmd.Wait()
+		close(h.exited)
+	}()
+	return h, nil
+}
+
+func (h *helper) kill() {
+	_ = h.stdin.Close()
+	_ = h.cmd.Process.Kill()
+	<-h.exited
+}
+
+// exitErr 把子进程的退出归为内存超限或崩溃。
+func (h *helper) exitErr() error {
+	code := -1
+	if h.cmd.ProcessState != nil {
+		code = h.cmd.ProcessState.ExitCode()
+	}
+	tail := h.stderr.String()
+	if code == exitMemory || strings.Contains(tail, "out of memory") {
+		return ErrHelperMemory
+	}
+	if line := panicLine(tail); line != "" {
+		return fmt.Errorf("%w（退出码 %d）: %s", ErrHelperCrashed, code, line)
+	}
+	return fmt.Errorf("%w（退出码 %d）", ErrHelperCrashed, code)
+}
+
+// call 发送一个请求并等待响应；ok 为 false 时子进程已不可用（超时被杀、崩溃），调用方应丢弃它。
+func (h *helper) call(ctx context.Context, req request) (resp response, err error, ok bool) {
+	h.nextID++
+	req.ID = h.nextID
+	b, _ := json.Marshal(req)
+	if _, err := h.stdin.Write(append(b, '\n')); err != nil {
+		h.kill()
+		return response{}, h.exitErr(), false
+	}
+	type result struct {
+		resp response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var r response
+		err := h.dec.Decode(&r)
+		done <- result{r, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil || r.resp.ID != req.ID {
+			select {
+			case <-h.exited:
+			case <-time.After(2 * time.Second):
+				h.kill()
+			}
+			return response{}, h.exitErr(), false
+		}
+		return r.resp, nil, true
+	case <-ctx.Done():
+		h.kill()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return response{}, extract.ErrTimeout, false
+		}
+		return response{}, ctx.Err(), false
+	}
+}
+
+// Pool 为提取子进程池：最多 size 个子进程，按需启动，出问题的子进程被丢弃后下次自动补上。
+type Pool struct {
+	exe  string
+	idle chan *helper
+	sem  chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewPool 创建子进程池；exe 为 ofind 可执行文件（需支持 -extract-worker）。
+func NewPool(exe string, size int) *Pool {
+	if size <= 0 {
+		size = 1
+	}
+	return &Pool{exe: exe, idle: make(chan *helper, size), sem: make(chan struct{}, size)}
+}
+
+func (p *Pool) acquire(ctx context.Context) (*helper, error) {
+	select {
+	case h := <-p.idle:
+		return h, nil
+	default:
+	}
+	select {
+	case h := <-p.idle:
+		return h, nil
+	case p.sem <- struct{}{}:
+		h, err := startHelper(p.exe)
+		if err != nil {
+			<-p.sem
+			return nil, fmt.Errorf("%w: %v", errHelperStart, err)
+		}
+		return h, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *Pool) release(h *helper, ok bool) {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if ok && !closed {
+		p.idle <- h
+		return
+	}
+	if ok {
+		h.kill()
+	}
+	<-p.sem
+}
+
+// FindFirst 在子进程中执行 extract.FileFindFirst；ctx 到期时结束该子进程并返回 extract.ErrTimeout。
+// ctx 携带的 Trace 会按子进程的结果回填。
+func (p *Pool) FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	return p.FindFirstAs(ctx, "", path, query, contextLen)
+}
+
+// FindFirstAs 与 FindFirst 相同，但把已解析出的格式一并交给子进程（format 为空时由子进程判断）。
+func (p *Pool) FindFirstAs(ctx context.Context, format string, path string, query string, contextLen int) (bool, string, error) {
+	h, err := p.acquire(ctx)
+	if err != nil {
+		return false, "", err
+	}
+	resp, err, ok := h.call(ctx, request{Path: path, Query: query, ContextLen: contextLen, PDFBackends: extract.PDFBackendsFrom(ctx), Format: format})
+	p.release(h, ok)
+	if err != nil {
+		return false, "", err
+	}
+	if t := extract.TraceFrom(ctx); t != nil {
+		t.Format, t.Backend, t.Truncated, t.Encrypted = resp.Format, resp.Backend, resp.Truncated, resp.Encrypted
+	}
+	if resp.Status != "" && resp.Status != extract.StatusOK {
+		return resp.Found, resp.Snippet, extract.ErrorFromStatus(resp.Status, resp.Err)
+	}
+	return resp.Found, resp.Snippet, nil
+}
+
+// Close 结束所有空闲子进程；正在使用的子进程在归还时结束。
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	for {
+		select {
+		case h := <-p.idle:
+			h.kill()
+			<-p.sem
+		default:
+			return
+		}
+	}
+}
+
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = append([]byte(nil), b.buf[len(b.buf)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+// panicLine 从子进程 stderr 末尾找出 panic/fatal error 行，找不到时返回最后一个非空行。
+func panicLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); strings.HasPrefix(l, "panic:") || strings.HasPrefix(l, "fatal error:") {
+			return l
+		}
+	}
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// FormatsFromEnv 返回交给沙箱的格式名集合（OFIND_SANDBOX_FORMATS，如 "pdf"、"pdf,ooxml"，"all" 表示全部），
+// 默认为空，即不启用沙箱。
+func FormatsFromEnv() map[string]bool {
+	out := map[string]bool{}
+	for _, f := range strings.Split(os.Getenv("OFIND_SANDBOX_FORMATS"), ",") {
+		if f = strings.ToLower(strings.TrimSpace(f)); f != "" {
+			out[f] = true
+		}
+	}
+	return out
+}
+
+func workersFromEnv() int {
+	if v := strings.TrimSpace(os.Getenv("OFIND_SANDBOX_WORKERS")); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 2
+}
+
+var (
+	defaultOnce    sync.Once
+	defaultPool    *Pool
+	defaultFormats map[string]bool
+)
+
+func defaultRouting() (*Pool, map[string]bool) {
+	defaultOnce.Do(func() {
+		defaultFormats = FormatsFromEnv()
+		if len(defaultFormats) == 0 {
+			return
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			return
+		}
+		defaultPool = NewPool(exe, workersFromEnv())
+	})
+	return defaultPool, defaultFormats
+}
+
+// Routed 判断 format 的文件是否交给默认子进程池提取。这些文件的内存开销在子进程内，
+// 由 OFIND_SANDBOX_MEM_MB 约束，不计入本进程的内存预算（internal/budget）。
+func Routed(format string) bool {
+	pool, formats := defaultRouting()
+	return pool != nil && (formats["all"] || formats[format])
+}
+
+// FindFirst 把 OFIND_SANDBOX_FORMATS 指定格式的文件交给默认子进程池，其它文件在本进程内用
+// extract.FileFindFirstWithin 提取；子进程无法启动时同样退回本进程。
+func FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	return FindFirstAs(ctx, extract.FileFormat(path), path, query, contextLen)
+}
+
+// FindFirstAs 与 FindFirst 相同，但使用调用方已解析出的格式（extract.FileFormat）：同一文件的路由、
+// 预算估算与各个关键词的提取共用一次解析，不再重复读文件头。
+func FindFirstAs(ctx context.Context, format string, path string, query string, contextLen int) (bool, string, error) {
+	pool, formats := defaultRouting()
+	if pool != nil && (formats["all"] || formats[format]) {
+		found, snippet, err := pool.FindFirstAs(ctx, format, path, query, contextLen)
+		if !errors.Is(err, errHelperStart) {
+			return found, snippet, err
+		}
+	}
+	return extract.FileFindFirstAsWithin(ctx, format, path, query, contextLen)
+}
@@ -0,0 +1,103 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"office_find_item/internal/extract"
+)
+
+// 测试二进制自身充当提取子进程：带 -extract-worker 启动时注册两个模拟格式后进入 Serve。
+//   - .hang：永不返回（模拟卡住的后端）
+//   - .boom：panic（模拟解析器缺陷导致的崩溃）
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == "-extract-worker" {
+		extract.Default.Register(extract.Format{Name: "hang", Extensions: []string{".hang"}, TrustExtension: true, Chain: []extract.Extractor{fakeExtractor{hang: true}}})
+		extract.Default.Register(extract.Format{Name: "boom", Extensions: []string{".boom"}, TrustExtension: true, Chain: []extract.Extractor{fakeExtractor{}}})
+		if err := Serve(os.Stdin, os.Stdout); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+type fakeExtractor struct{ hang bool }
+
+func (e fakeExtractor) Name() string { return "fake" }
+
+func (e fakeExtractor) FindFirst(ctx context.Context, path string, query string, contextLen int) (bool, string, error) {
+	if e.hang {
+		select {}
+	}
+	panic("fake parser bug")
+}
+
+func (e fakeExtractor) FindSnippets(ctx context.Context, path string, query string, contextLen int, maxSnippets int) ([]string, error) {
+	return nil, extract.ErrUnsupported
+}
+
+func (e fakeExtractor) ExtractText(ctx context.Context, path string, maxBytes int64) (string, error) {
+	return "", extract.ErrUnsupported
+}
+
+func (e fakeExtractor) Stream(ctx context.Context, path string) (extract.TextStream, error) {
+	return nil, extract.ErrUnsupported
+}
+
+func writeFiles(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, n := range names {
+		if err := os.WriteFile(filepath.Join(dir, n), []byte("合同编号：A-001"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestPool_FindFirstInHelper(t *testing.T) {
+	dir := writeFiles(t, "a.txt")
+	p := NewPool(os.Args[0], 1)
+	defer p.Close()
+
+	ctx, trace := extract.WithTrace(context.Background())
+	found, snip, err := p.FindFirst(ctx, filepath.Join(dir, "a.txt"), "A-001", 5)
+	if err != nil || !found || snip == "" {
+		t.Fatalf("FindFirst = %v, %q, %v", found, snip, err)
+	}
+	if trace.Format != "text" {
+		t.Fatalf("trace = %+v, want format text", trace)
+	}
+	_, _, err = p.FindFirst(context.Background(), filepath.Join(dir, "missing.txt"), "x", 5)
+	if st := extract.StatusOf(err); st != extract.StatusNotFound {
+		t.Fatalf("status = %s (%v), want %s", st, err, extract.StatusNotFound)
+	}
+}
+
+func TestPool_TimeoutAndCrashRestartHelper(t *testing.T) {
+	dir := writeFiles(t, "a.txt", "b.hang", "c.boom")
+	p := NewPool(os.Args[0], 1)
+	defer p.Close()
+
+	ctx, cancel := extract.WithFileTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if _, _, err := p.FindFirst(ctx, filepath.Join(dir, "b.hang"), "x", 5); !errors.Is(err, extract.ErrTimeout) {
+		t.Fatalf("hang: err = %v, want ErrTimeout", err)
+	}
+	if found, _, err := p.FindFirst(context.Background(), filepath.Join(dir, "a.txt"), "A-001", 5); err != nil || !found {
+		t.Fatalf("after timeout: %v, %v", found, err)
+	}
+
+	_, _, err := p.FindFirst(context.Background(), filepath.Join(dir, "c.boom"), "x", 5)
+	if !errors.Is(err, ErrHelperCrashed) || extract.StatusOf(err) != extract.StatusCrashed {
+		t.Fatalf("boom: err = %v, want ErrHelperCrashed", err)
+	}
+	if found, _, err := p.FindFirst(context.Background(), filepath.Join(dir, "a.txt"), "A-001", 5); err != nil || !found {
+		t.Fatalf("after crash: %v, %v", found, err)
+	}
+}
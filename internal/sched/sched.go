@@ -0,0 +1,258 @@
+package sched
+
+import (
+	"container/heap"
+	"context"
+	"io/fs"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"office_find_item/internal/budget"
+	"office_find_item/internal/extract"
+)
+
+// 调度：遍历器按目录字典序产出文件，树前部的一个 200MB PDF 会让本可立即出现的结果一起等待。
+// Run 位于遍历器与 worker 之间，在有界的预读窗口内按策略挑出优先的文件交给 worker，内存占用与窗口大小成正比。
+
+// 排序依据（Policy.Keys 的取值）。
+const (
+	// KeyPinned：位于置顶目录（OFIND_PINNED_DIRS）下的文件优先
+	KeyPinned = "pinned"
+	// KeyCheap：按格式与大小估算的提取开销小的优先（按 2 的幂分档）
+	KeyCheap = "cheap"
+	// KeyRecent：最近修改的优先（按 1 天、1 周、1 月、1 年分档）
+	KeyRecent = "recent"
+)
+
+type Policy struct {
+	// Keys 为排序依据，依次比较；为空表示保持遍历顺序
+	Keys []string
+	// Pinned 为优先处理的目录
+	Pinned []string
+	// Lookahead 为预读窗口（等待排序的最多文件数）
+	Lookahead int
+}
+
+// PolicyFromEnv 读取调度策略：
+//   - OFIND_SCHEDULE：逗号分隔的 pinned/cheap/recent，默认（空或 "walk"）按遍历顺序，不重新排序
+//   - OFIND_PINNED_DIRS：置顶目录，多个用 ; 分隔
+//   - OFIND_SCHEDULE_LOOKAHEAD：预读窗口，默认 512
+func PolicyFromEnv() Policy {
+	p := Policy{Lookahead: 512, Keys: ParseKeys(os.Getenv("OFIND_SCHEDULE"))}
+	for _, d := range strings.Split(os.Getenv("OFIND_PINNED_DIRS"), ";") {
+		if d = strings.TrimSpace(d); d != "" {
+			p.Pinned = append(p.Pinned, filepath.Clean(d))
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("OFIND_SCHEDULE_LOOKAHEAD")); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			p.Lookahead = n
+		}
+	}
+	return p
+}
+
+// ParseKeys 解析逗号分隔的排序依据（pinned/cheap/recent），忽略无法识别的项；空或 "walk" 返回 nil（按遍历顺序）。
+func ParseKeys(s string) []string {
+	var keys []string
+	for _, k := range strings.Split(s, ",") {
+		switch k = strings.ToLower(strings.TrimSpace(k)); k {
+		case KeyPinned, KeyCheap, KeyRecent:
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Job 为遍历得到的一个待处理文件。
+type Job struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+func (p Policy) needsInfo() bool {
+	for _, k := range p.Keys {
+		if k == KeyCheap || k == KeyRecent {
+			return true
+		}
+	}
+	return false
+}
+
+// NewJob 由遍历得到的目录项生成 Job；只有策略需要时才读取大小与修改时间。
+func (p Policy) NewJob(path string, d fs.DirEntry) Job {
+	j := Job{Path: path}
+	if p.needsInfo() {
+		if info, err := d.Info(); err == nil {
+			j.Size, j.ModTime = info.Size(), info.ModTime()
+		}
+	}
+	return j
+}
+
+func (p Policy) pinned(path string) bool {
+	for _, dir := range p.Pinned {
+		if hasPathPrefix(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPathPrefix(path, dir string) bool {
+	if len(path) < len(dir) {
+		return false
+	}
+	head := path[:len(dir)]
+	if runtime.GOOS == "windows" {
+		if !strings.EqualFold(head, dir) {
+			return false
+		}
+	} else if head != dir {
+		return false
+	}
+	return len(path) == len(dir) || os.IsPathSeparator(path[len(dir)]) || os.IsPathSeparator(dir[len(dir)-1])
+}
+
+func ageBucket(now, mod time.Time) int64 {
+	if mod.IsZero() {
+		return 5
+	}
+	age := now.Sub(mod)
+	for i, limit := range []time.Duration{24 * time.Hour, 7 * 24 * time.Hour, 30 * 24 * time.Hour, 365 * 24 * time.Hour} {
+		if age < limit {
+			return int64(i)
+		}
+	}
+	return 4
+}
+
+// rank 计算 Job 在各排序依据上的档位（越小越优先）。
+func (p Policy) rank(j Job, now time.Time) [3]int64 {
+	var r [3]int64
+	for i, k := range p.Keys {
+		if i >= len(r) {
+			break
+		}
+		switch k {
+		case KeyPinned:
+			if !p.pinned(j.Path) {
+				r[i] = 1
+			}
+		case KeyCheap:
+			ext := strings.ToLower(filepath.Ext(j.Path))
+			r[i] = int64(bits.Len64(uint64(budget.Estimate(extract.ExtFormat(ext), j.Size))))
+		case KeyRecent:
+			r[i] = ageBucket(now, j.ModTime)
+		}
+	}
+	return r
+}
+
+type item struct {
+	path string
+	rank [3]int64
+	seq  uint64
+}
+
+type jobHeap []item
+
+func (h jobHeap) Len() int { return len(h) }
+func (h jobHeap) Less(i, j int) bool {
+	for k := range h[i].rank {
+		if h[i].rank[k] != h[j].rank[k] {
+			return h[i].rank[k] < h[j].rank[k]
+		}
+	}
+	return h[i].seq < h[j].seq
+}
+func (h jobHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *jobHeap) Push(x any)   { *h = append(*h, x.(item)) }
+func (h *jobHeap) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
+
+// Run 从 in 读取 Job，按策略排序后把路径写入 out；in 关闭且全部发出，或 ctx 结束时关闭 out 并返回。
+// worker 空闲时优先发出窗口内最优的文件；窗口满时暂停读取 in（遍历器随之阻塞），内存保持平稳。
+func Run(ctx context.Context, p Policy, in <-chan Job, out chan<- string) {
+	defer close(out)
+	if len(p.Keys) == 0 {
+		for j := range in {
+			select {
+			case out <- j.Path:
+			case <-ctx.Done():
+				return
+			}
+		}
+		return
+	}
+	lookahead := p.Lookahead
+	if lookahead <= 0 {
+		lookahead = 1
+	}
+	now := time.Now()
+	h := &jobHeap{}
+	var seq uint64
+	push := func(j Job) {
+		seq++
+		heap.Push(h, item{path: j.Path, rank: p.rank(j, now), seq: seq})
+	}
+	for in != nil || h.Len() > 0 {
+		// 先收下遍历器已经产出的文件，让排序有足够的候选
+	drain:
+		for in != nil && h.Len() < lookahead {
+			select {
+			case j, ok := <-in:
+				if !ok {
+					in = nil
+					break drain
+				}
+				push(j)
+			default:
+				break drain
+			}
+		}
+
+		var recv <-chan Job
+		if in != nil && h.Len() < lookahead {
+			recv = in
+		}
+		if h.Len() == 0 {
+			if recv == nil {
+				return
+			}
+			select {
+			case j, ok := <-recv:
+				if !ok {
+					in = nil
+					continue
+				}
+				push(j)
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		select {
+		case out <- (*h)[0].path:
+			heap.Pop(h)
+		case j, ok := <-recv:
+			if !ok {
+				in = nil
+				continue
+			}
+			push(j)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
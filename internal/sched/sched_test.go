@@ -0,0 +1,65 @@
+package sched
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func collect(t *testing.T, p Policy, jobs []Job) []string {
+	t.Helper()
+	in := make(chan Job, len(jobs))
+	for _, j := range jobs {
+		in <- j
+	}
+	close(in)
+	out := make(chan string)
+	go Run(context.Background(), p, in, out)
+	var got []string
+	for path := range out {
+		got = append(got, filepath.Base(path))
+	}
+	return got
+}
+
+func TestRun_Order(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-2 * 365 * 24 * time.Hour)
+	pinned := filepath.Join("root", "pinned")
+	jobs := []Job{
+		{Path: filepath.Join("root", "a", "big.pdf"), Size: 200 << 20, ModTime: old},
+		{Path: filepath.Join("root", "a", "old.txt"), Size: 10, ModTime: old},
+		{Path: filepath.Join("root", "b", "new.txt"), Size: 10, ModTime: now},
+		{Path: filepath.Join(pinned, "x.docx"), Size: 1 << 20, ModTime: old},
+	}
+
+	p := Policy{Keys: []string{KeyPinned, KeyCheap, KeyRecent}, Pinned: []string{pinned}, Lookahead: 16}
+	if got, want := collect(t, p, jobs), []string{"x.docx", "new.txt", "old.txt", "big.pdf"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("pinned,cheap,recent: got %v, want %v", got, want)
+	}
+
+	if got, want := collect(t, Policy{}, jobs), []string{"big.pdf", "old.txt", "new.txt", "x.docx"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("walk order: got %v, want %v", got, want)
+	}
+}
+
+func TestPolicyFromEnv(t *testing.T) {
+	t.Setenv("OFIND_SCHEDULE", "recent, bogus")
+	t.Setenv("OFIND_PINNED_DIRS", " D:\\Work ; ")
+	t.Setenv("OFIND_SCHEDULE_LOOKAHEAD", "8")
+	p := PolicyFromEnv()
+	if !reflect.DeepEqual(p.Keys, []string{KeyRecent}) || len(p.Pinned) != 1 || p.Lookahead != 8 {
+		t.Fatalf("PolicyFromEnv = %+v", p)
+	}
+	t.Setenv("OFIND_SCHEDULE", "walk")
+	if p := PolicyFromEnv(); len(p.Keys) != 0 {
+		t.Fatalf("walk: keys = %v", p.Keys)
+	}
+	// 未设置时按遍历顺序，不悄悄改变结果顺序
+	t.Setenv("OFIND_SCHEDULE", "")
+	if p := PolicyFromEnv(); len(p.Keys) != 0 {
+		t.Fatalf("default: keys = %v", p.Keys)
+	}
+}
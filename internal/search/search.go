@@ -11,16 +11,64 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"office_find_item/internal/budget"
 	"office_find_item/internal/extract"
+	"office_find_item/internal/fswalk"
+	"office_find_item/internal/sandbox"
+	"office_find_item/internal/sched"
+	"office_find_item/internal/throttle"
 )
 
 type Config struct {
-	Roots   []string
+	Roots []string
+	// Files 非空时不遍历 Roots，直接处理它给出的文件（如 git ls-files、find 的输出，见 fswalk.ReadPaths）；
+	// 扩展名、元数据与包含/排除过滤照常生效
+	Files   fswalk.Paths
 	Query   string
 	Workers int
 	// ContextLen 表示命中后输出的上下文字符数（左右各多少 rune）
 	ContextLen int
+	// PDFBackends 为本次查询的 PDF 后端链（如 "pdftotext,purego"），空表示使用环境变量/默认顺序
+	PDFBackends string
+	// FileTimeout 为单个文件的处理时限；0 表示按 OFIND_FILE_TIMEOUT（默认 60 秒），负数表示不限时
+	FileTimeout time.Duration
+	// ReportErrors 为 true 时，无法读取的文件（超时、加密、损坏等）与目录也通过 onResult 回调，
+	// Status 为原因、Snippet 为空；默认只回调命中
+	ReportErrors bool
+	// OnSkip 在文件超时或提取出错（含加密、超出安全预算）时回调，可能被多个 worker 并发调用；可为 nil。
+	// 为兼容保留：设置 ReportErrors 时不再调用，无法读取的文件只通过 onResult（Result.Status）报告
+	OnSkip SkipFn
+	// Schedule 为文件处理顺序策略；nil 表示按环境变量（sched.PolicyFromEnv）
+	Schedule *sched.Policy
+	// Walk 为目录遍历选项（并发读取数、是否按固定顺序）；nil 表示按环境变量（fswalk.OptionsFromEnv）
+	Walk *fswalk.Options
+	// Meta 为文件元数据条件（扩展名、大小、修改时间），遍历时即过滤，不满足的文件不做提取
+	Meta fswalk.Meta
+	// Background 为 true 时按后台模式限速（throttle.LimitsFromEnv），并降低整个进程的 CPU/IO 优先级
+	Background bool
+}
+
+func (c Config) fileTimeout() time.Duration {
+	if c.FileTimeout == 0 {
+		return extract.FileTimeout()
+	}
+	return c.FileTimeout
+}
+
+func (c Config) schedule() sched.Policy {
+	if c.Schedule == nil {
+		return sched.PolicyFromEnv()
+	}
+	return *c.Schedule
+}
+
+func (c Config) walkOptions() fswalk.Options {
+	if c.Walk == nil {
+		return fswalk.OptionsFromEnv()
+	}
+	return *c.Walk
 }
 
 func (c Config) WorkerCount() int {
@@ -40,37 +88,54 @@ type Result struct {
 	ModTime   int64
 	// Snippet 为命中上下文（已包含对 query 的“标记高亮”）
 	Snippet string
+	// Backend 为产生正文的提取后端（如 "ooxml"、"pdftotext"）
+	Backend string
+	// Encrypted 表示文件是加密的，已用空口令或口令列表解密后搜索
+	Encrypted bool
+	// Truncated 非空表示只搜索了部分内容（如超出 zip/XML 安全预算、页数上限），内容为原因
+	Truncated string
+	// Status 为 extract.StatusOK 表示命中；其它值（仅 Config.ReportErrors 时出现）表示文件无法读取及原因
+	Status extract.Status
+	// Err 为无法读取时的具体错误信息
+	Err string
+}
+
+// Skipped 记录没有搜索完成的文件，避免与“没有命中”混淆。
+type Skipped struct {
+	Path string
+	// Reason 为 "timeout"、"encrypted"、"refused" 或 "error"
+	Reason string
+	// Err 为具体错误信息
+	Err string
+}
+
+type SkipFn func(Skipped)
+
+// SkipReason 把提取错误归为 Skipped.Reason（extract.StatusOf 的粗粒度版本）。
+func SkipReason(err error) string {
+	switch extract.StatusOf(err) {
+	case extract.StatusTimeout:
+		return "timeout"
+	case extract.StatusEncrypted:
+		return "encrypted"
+	case extract.StatusRefused:
+		return "refused"
+	default:
+		return "error"
+	}
 }
 
 type Progress struct {
 	FilesScanned uint64
 	Matches      uint64
+	// Deferred 为因内存预算不足而延后处理的文件数（见 internal/budget）
+	Deferred uint64
 }
 
 type ProgressFn func(Progress)
 
 type ResultFn func(Result)
 
-var supportedExt = map[string]struct{}{
-	".txt":  {},
-	".md":   {},
-	".log":  {},
-	".csv":  {},
-	".json": {},
-	".xml":  {},
-	".ini":  {},
-	".yaml": {},
-	".yml":  {},
-	".doc":  {},
-	".docx": {},
-	".xls":  {},
-	".xlsx": {},
-	".ppt":  {},
-	".pptx": {},
-	".pdf":  {},
-	".vsdx": {},
-}
-
 func Find(cfg Config, onProgress ProgressFn) ([]Result, error) {
 	ch, _, err := FindAsync(cfg, onProgress)
 	if err != nil {
@@ -84,7 +149,7 @@ func FindAsync(cfg Config, onProgress ProgressFn) (<-chan []Result, func(), erro
 	if q == "" {
 		return nil, nil, errors.New("query 为空")
 	}
-	if len(cfg.Roots) == 0 {
+	if len(cfg.Roots) == 0 && cfg.Files == nil {
 		return nil, nil, errors.New("roots 为空")
 	}
 
@@ -108,7 +173,7 @@ func Search(cfg Config, onProgress ProgressFn, onResult ResultFn) error {
 	if q == "" {
 		return errors.New("query 为空")
 	}
-	if len(cfg.Roots) == 0 {
+	if len(cfg.Roots) == 0 && cfg.Files == nil {
 		return errors.New("roots 为空")
 	}
 	ctx := context.Background()
@@ -130,11 +195,20 @@ func findWithContext(ctx context.Context, cfg Config, onProgress ProgressFn) []R
 
 func searchWithContext(ctx context.Context, cfg Config, onProgress ProgressFn, onResult ResultFn) {
 	workers := cfg.WorkerCount()
+	ctx = extract.WithPDFBackends(ctx, cfg.PDFBackends)
+	fileTimeout := cfg.fileTimeout()
 
-	jobs := make(chan string, workers*4)
+	jobs := make(chan string)
 
 	var scanned uint64
 	var matches uint64
+	var deferred uint64
+	mem := budget.Default()
+	var thr *throttle.Throttle
+	if cfg.Background {
+		_ = throttle.LowerPriority()
+		thr = throttle.New(throttle.LimitsFromEnv())
+	}
 
 	resCh := make(chan Result, workers*2)
 
@@ -147,26 +221,73 @@ func searchWithContext(ctx context.Context, cfg Config, onProgress ProgressFn, o
 				if ctx.Err() != nil {
 					return
 				}
+				if err := thr.Wait(ctx); err != nil {
+					return
+				}
 				atomic.AddUint64(&scanned, 1)
 				if onProgress != nil {
-					onProgress(Progress{FilesScanned: atomic.LoadUint64(&scanned), Matches: atomic.LoadUint64(&matches)})
+					onProgress(Progress{FilesScanned: atomic.LoadUint64(&scanned), Matches: atomic.LoadUint64(&matches), Deferred: atomic.LoadUint64(&deferred)})
+				}
+
+				var (
+					size    int64
+					modTime int64
+				)
+				if st, err := os.Stat(path); err == nil {
+					size = st.Size()
+					modTime = st.ModTime().Unix()
+				}
+				// 按估算开销申请内存预算；放不下时等待（不计入单文件时限），让便宜的文件先处理
+				// 格式只解析一次，预算估算、沙箱路由与提取共用
+				format := extract.FileFormat(path)
+				if ext := strings.ToLower(filepath.Ext(path)); !extract.SupportedExt(ext) && cfg.Meta.HasExt(ext) {
+					format = extract.SniffFormat(path)
+				}
+				cost := budget.Estimate(format, size)
+				if sandbox.Routed(format) {
+					cost = 0 // 在子进程内提取，内存由子进程上限约束
+				}
+				free, waited, err := mem.Acquire(ctx, cost)
+				if err != nil {
+					return
+				}
+				if waited {
+					atomic.AddUint64(&deferred, 1)
 				}
+				// 超时后提取可能仍在后台运行、占着内存：预算等它结束才归还
+				rctx, release := extract.WithRelease(ctx, free)
 
-				found, snippet, _ := extract.FileFindFirst(ctx, path, cfg.Query, cfg.ContextLen)
+				tctx, tcancel := extract.WithFileTimeout(rctx, fileTimeout)
+				fctx, trace := extract.WithTrace(tctx)
+				found, snippet, err := sandbox.FindFirstAs(fctx, format, path, cfg.Query, cfg.ContextLen)
+				tcancel()
+				release()
+				if err != nil && !found && ctx.Err() == nil && !cfg.ReportErrors && cfg.OnSkip != nil {
+					cfg.OnSkip(Skipped{Path: path, Reason: SkipReason(err), Err: err.Error()})
+				}
+				if err != nil && !found && ctx.Err() == nil && cfg.ReportErrors {
+					select {
+					case resCh <- Result{
+						Path:      path,
+						Extension: strings.ToLower(filepath.Ext(path)),
+						Status:    extract.StatusOf(err),
+						Err:       err.Error(),
+					}:
+					case <-ctx.Done():
+						return
+					}
+					continue
+				}
 				if found {
 					atomic.AddUint64(&matches, 1)
-					var (
-						size    int64
-						modTime int64
-					)
-					if st, err := os.Stat(path); err == nil {
-						size = st.Size()
-						modTime = st.ModTime().Unix()
-					}
 					select {
 					case resCh <- Result{
 						Path:      path,
 						Snippet:   snippet,
+						Backend:   trace.Backend,
+						Encrypted: trace.Encrypted,
+						Truncated: trace.Truncated,
+						Status:    extract.StatusOK,
 						Extension: strings.ToLower(filepath.Ext(path)),
 						Size:      size,
 						ModTime:   modTime,
@@ -179,45 +300,55 @@ func searchWithContext(ctx context.Context, cfg Config, onProgress ProgressFn, o
 		}()
 	}
 
+	// 开启内容识别时，未知扩展名（含无扩展名）的文件也交给提取器按文件头判断格式。
+	sniffUnknown := extract.SniffUnknownEnabled()
+
+	// 遍历结果经调度器按策略排序后交给 worker（小文件、最近修改、置顶目录优先）
+	policy := cfg.schedule()
+	walked := make(chan sched.Job, workers*4)
+	go sched.Run(ctx, policy, walked, jobs)
+
 	walkDone := make(chan struct{})
 	go func() {
 		defer close(walkDone)
-		for _, root := range cfg.Roots {
-			root = strings.TrimSpace(root)
-			if root == "" {
-				continue
-			}
-			_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-				if err != nil {
-					return nil
-				}
-				if ctx.Err() != nil {
-					return context.Canceled
-				}
-				if d.IsDir() {
-					return nil
-				}
-				ext := strings.ToLower(filepath.Ext(d.Name()))
-				if _, ok := supportedExt[ext]; !ok {
-					return nil
-				}
-
+		defer close(walked)
+		walkOpts := cfg.walkOptions()
+		// 重叠的根（D:\ 与 D:\Docs）只遍历一次
+		roots := fswalk.CanonicalRoots(cfg.Roots, walkOpts)
+		if cfg.ReportErrors {
+			// 无法读取的目录与无法读取的文件一样报告
+			walkOpts.OnDirError = func(dir string, err error) {
 				select {
-				case jobs <- path:
+				case resCh <- Result{Path: dir, Status: extract.StatusOf(err), Err: err.Error()}:
 				case <-ctx.Done():
-					return context.Canceled
 				}
+			}
+		}
+		visit := func(path string, d fs.DirEntry) error {
+			ext := strings.ToLower(filepath.Ext(d.Name()))
+			// -ext 显式指定的扩展名即使未注册也交给提取器（按文件头识别）
+			if !extract.SupportedExt(ext) && !sniffUnknown && !cfg.Meta.HasExt(ext) {
+				return nil
+			}
+			if !cfg.Meta.Match(d) {
 				return nil
-			})
-			if ctx.Err() != nil {
-				return
 			}
+			select {
+			case walked <- policy.NewJob(path, d):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return nil
+		}
+		if cfg.Files != nil {
+			_ = fswalk.WalkPaths(ctx, cfg.Files, walkOpts, visit)
+			return
 		}
+		_ = fswalk.Walk(ctx, roots, walkOpts, visit)
 	}()
 
 	go func() {
 		<-walkDone
-		close(jobs)
 		wg.Wait()
 		close(resCh)
 	}()
@@ -0,0 +1,71 @@
+//go:build linux
+
+package throttle
+
+import (
+	"bufio"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassIdle  = 3
+	ioprioClassShift = 13
+)
+
+// lowerPriority 对本进程的每个线程设置 nice 10 与 IO 空闲类（Linux 上两者都是按线程的，之后新建的线程继承）。
+func lowerPriority() error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, t := range tasks {
+		tid, err := strconv.Atoi(t.Name())
+		if err != nil {
+			continue
+		}
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, 10); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if _, _, e := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), ioprioClassIdle<<ioprioClassShift); e != 0 && firstErr == nil {
+			firstErr = e
+		}
+	}
+	return firstErr
+}
+
+// processReadBytes 返回 /proc/self/io 的 rchar（含页缓存与网络文件系统的读取）。
+func processReadBytes() uint64 {
+	f, err := os.Open("/proc/self/io")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if v, ok := strings.CutPrefix(sc.Text(), "rchar:"); ok {
+			n, _ := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+// foregroundActive 以 1 分钟平均负载超过 CPU 数作为“系统忙”的信号。
+func foregroundActive() bool {
+	b, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return false
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	return err == nil && load > float64(runtime.NumCPU())
+}
@@ -0,0 +1,9 @@
+//go:build !windows && !linux
+
+package throttle
+
+func lowerPriority() error { return nil }
+
+func processReadBytes() uint64 { return 0 }
+
+func foregroundActive() bool { return false }
@@ -0,0 +1,61 @@
+//go:build windows
+
+package throttle
+
+import (
+	"syscall"
+	"unsafe"
+
+	"office_find_item/internal/winutil"
+)
+
+const (
+	processModeBackgroundBegin = 0x00100000
+	belowNormalPriorityClass   = 0x00004000
+)
+
+var (
+	modKernel32          = syscall.NewLazyDLL("kernel32.dll")
+	procSetPriorityClass = modKernel32.NewProc("SetPriorityClass")
+	procGetTickCount     = modKernel32.NewProc("GetTickCount")
+	modUser32            = syscall.NewLazyDLL("user32.dll")
+	procGetLastInputInfo = modUser32.NewProc("GetLastInputInfo")
+)
+
+func lowerPriority() error {
+	h, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return err
+	}
+	if ok, _, _ := procSetPriorityClass.Call(uintptr(h), processModeBackgroundBegin); ok != 0 {
+		return nil
+	}
+	// 后台模式不可用时（如已处于该模式）退回低于正常
+	if ok, _, e := procSetPriorityClass.Call(uintptr(h), belowNormalPriorityClass); ok == 0 {
+		return e
+	}
+	return nil
+}
+
+func processReadBytes() uint64 {
+	c, err := winutil.GetProcessIOCounters()
+	if err != nil {
+		return 0
+	}
+	return c.ReadBytes
+}
+
+type lastInputInfo struct {
+	cbSize uint32
+	dwTime uint32
+}
+
+// foregroundActive 判断最近 10 秒内是否有键盘/鼠标输入。
+func foregroundActive() bool {
+	info := lastInputInfo{cbSize: uint32(unsafe.Sizeof(lastInputInfo{}))}
+	if ok, _, _ := procGetLastInputInfo.Call(uintptr(unsafe.Pointer(&info))); ok == 0 {
+		return false
+	}
+	now, _, _ := procGetTickCount.Call()
+	return uint32(now)-info.dwTime < 10*1000
+}
@@ -0,0 +1,131 @@
+package throttle
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 后台模式：用于整盘/文件服务器的索引与预热，限制每秒处理的文件数与读取量，降低进程 CPU/IO 优先级，
+// 并在检测到前台活动（Windows：最近有键盘鼠标输入；Linux：系统负载高于 CPU 数）时进一步放慢，
+// 使扫描不会在工作时间拖慢用户电脑或 NAS。
+
+type Limits struct {
+	// ReadMBps 为本进程读取速率上限（MiB/s），0 表示不限
+	ReadMBps float64
+	// FilesPerSec 为每秒开始处理的文件数上限，0 表示不限
+	FilesPerSec float64
+	// ForegroundFactor 为检测到前台活动时的速率系数（如 0.25 表示降到四分之一）
+	ForegroundFactor float64
+}
+
+// Enabled 判断是否以后台模式运行（OFIND_BACKGROUND=1，CLI 的 -background 会设置它）。
+func Enabled() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("OFIND_BACKGROUND"))) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+func envFloat(key string, def float64) float64 {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 {
+			return f
+		}
+	}
+	return def
+}
+
+// LimitsFromEnv 读取后台模式的限速：OFIND_BG_READ_MBPS（默认 8）、OFIND_BG_FILES_PER_SEC（默认 20）、
+// OFIND_BG_FOREGROUND_FACTOR（默认 0.25）。
+func LimitsFromEnv() Limits {
+	l := Limits{
+		ReadMBps:         envFloat("OFIND_BG_READ_MBPS", 8),
+		FilesPerSec:      envFloat("OFIND_BG_FILES_PER_SEC", 20),
+		ForegroundFactor: envFloat("OFIND_BG_FOREGROUND_FACTOR", 0.25),
+	}
+	if l.ForegroundFactor <= 0 || l.ForegroundFactor > 1 {
+		l.ForegroundFactor = 0.25
+	}
+	return l
+}
+
+// window 为计速窗口：超过后重新起算，避免长时间空闲积攒的额度造成突发。
+const window = 10 * time.Second
+
+// Throttle 在每个文件开始处理前限速；可被多个 worker 共用。nil *Throttle 不限速。
+type Throttle struct {
+	limits Limits
+	// readBytes 返回本进程累计读取字节数；foreground 判断是否有前台活动（便于测试替换）
+	readBytes  func() uint64
+	foreground func() bool
+
+	mu        sync.Mutex
+	winStart  time.Time
+	winFiles  int
+	winRead   uint64
+	fg        bool
+	fgChecked time.Time
+}
+
+func New(l Limits) *Throttle {
+	return &Throttle{limits: l, readBytes: processReadBytes, foreground: foregroundActive}
+}
+
+// Wait 阻塞到按限速可以开始处理下一个文件；ctx 结束时返回 ctx.Err()。
+func (t *Throttle) Wait(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for {
+		now := time.Now()
+		if t.winStart.IsZero() || now.Sub(t.winStart) > window {
+			t.winStart, t.winFiles, t.winRead = now, 0, t.readBytes()
+		}
+		if now.Sub(t.fgChecked) >= time.Second {
+			t.fg, t.fgChecked = t.foreground(), now
+		}
+		scale := 1.0
+		if t.fg {
+			scale = t.limits.ForegroundFactor
+		}
+		var need time.Duration
+		if fps := t.limits.FilesPerSec * scale; fps > 0 {
+			need = time.Duration(float64(t.winFiles+1) / fps * float64(time.Second))
+		}
+		if mbps := t.limits.ReadMBps * scale; mbps > 0 {
+			read := float64(t.readBytes() - t.winRead)
+			if d := time.Duration(read / (mbps * 1024 * 1024) * float64(time.Second)); d > need {
+				need = d
+			}
+		}
+		elapsed := now.Sub(t.winStart)
+		if elapsed >= need {
+			t.winFiles++
+			return nil
+		}
+		sleep := need - elapsed
+		if sleep > 500*time.Millisecond {
+			sleep = 500 * time.Millisecond
+		}
+		timer := time.NewTimer(sleep)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// LowerPriority 把本进程降为后台优先级（Windows：PROCESS_MODE_BACKGROUND_BEGIN，CPU/IO/内存优先级都降低；
+// Linux：nice 10 + IO 空闲类）。
+func LowerPriority() error {
+	return lowerPriority()
+}
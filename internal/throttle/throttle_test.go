@@ -0,0 +1,59 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTest(l Limits, read *uint64, fg *bool) *Throttle {
+	t := New(l)
+	t.readBytes = func() uint64 { return *read }
+	t.foreground = func() bool { return *fg }
+	return t
+}
+
+func TestThrottle_FilesPerSecond(t *testing.T) {
+	var read uint64
+	fg := false
+	thr := newTest(Limits{FilesPerSec: 100, ForegroundFactor: 0.25}, &read, &fg)
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := thr.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Fatalf("10 files at 100/s took %v", d)
+	}
+
+	// 前台活跃时降到四分之一
+	fg = true
+	thr = newTest(Limits{FilesPerSec: 100, ForegroundFactor: 0.25}, &read, &fg)
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		_ = thr.Wait(context.Background())
+	}
+	if d := time.Since(start); d < 110*time.Millisecond {
+		t.Fatalf("3 files at 25/s took %v", d)
+	}
+}
+
+func TestThrottle_ReadRateAndCancel(t *testing.T) {
+	var read uint64
+	fg := false
+	thr := newTest(Limits{ReadMBps: 1, ForegroundFactor: 1}, &read, &fg)
+	if err := thr.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	read += 5 << 20 // 读了 5MiB，按 1MiB/s 需要等约 5 秒
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := thr.Wait(ctx); err == nil {
+		t.Fatal("Wait should block until the read budget recovers")
+	}
+	var nilThr *Throttle
+	if err := nilThr.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
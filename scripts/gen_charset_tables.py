@@ -0,0 +1,76 @@
+# -*- coding: utf-8 -*-
+"""生成 internal/extract/charset_tables/*.bin（GB18030/Big5/Shift-JIS 解码表）。
+
+表直接取自 Python 内置 codecs（gb18030 / cp950 / cp932），无需联网下载。
+修改后重新运行：
+
+    python scripts/gen_charset_tables.py
+
+双字节表布局（三者相同）：lead 0x81..0xFE × trail 0x40..0xFE，
+每项 uint16 大端，值为 Unicode 码位，0 表示未定义。
+
+gb18030_ranges.bin：GB18030 四字节区（BMP 部分）的区间起点，
+每项 (线性序号 uint32, 码位 uint32) 大端，按序号升序。
+"""
+
+import os
+import struct
+
+LEAD_MIN, LEAD_MAX = 0x81, 0xFE
+TRAIL_MIN, TRAIL_MAX = 0x40, 0xFE
+
+OUT_DIR = os.path.join(os.path.dirname(os.path.abspath(__file__)),
+                       "..", "internal", "extract", "charset_tables")
+
+
+def double_byte_table(codec):
+    out = bytearray()
+    for lead in range(LEAD_MIN, LEAD_MAX + 1):
+        for trail in range(TRAIL_MIN, TRAIL_MAX + 1):
+            cp = 0
+            try:
+                s = bytes([lead, trail]).decode(codec)
+                if len(s) == 1 and ord(s) <= 0xFFFF and ord(s) != 0xFFFD:
+                    cp = ord(s)
+            except UnicodeDecodeError:
+                pass
+            out += struct.pack(">H", cp)
+    return bytes(out)
+
+
+def gb18030_linear(b):
+    return (((b[0] - 0x81) * 10 + (b[1] - 0x30)) * 126 + (b[2] - 0x81)) * 10 + (b[3] - 0x30)
+
+
+def gb18030_ranges():
+    out = bytearray()
+    prev_idx, prev_cp = -2, -2
+    for cp in range(0x80, 0x10000):
+        if 0xD800 <= cp <= 0xDFFF:
+            continue
+        b = chr(cp).encode("gb18030")
+        if len(b) != 4:
+            continue
+        idx = gb18030_linear(b)
+        if idx != prev_idx + 1 or cp != prev_cp + 1:
+            out += struct.pack(">II", idx, cp)
+        prev_idx, prev_cp = idx, cp
+    return bytes(out)
+
+
+def main():
+    os.makedirs(OUT_DIR, exist_ok=True)
+    files = {
+        "gb18030.bin": double_byte_table("gb18030"),
+        "big5.bin": double_byte_table("cp950"),
+        "sjis.bin": double_byte_table("cp932"),
+        "gb18030_ranges.bin": gb18030_ranges(),
+    }
+    for name, data in files.items():
+        with open(os.path.join(OUT_DIR, name), "wb") as f:
+            f.write(data)
+        print("%s: %d bytes" % (name, len(data)))
+
+
+if __name__ == "__main__":
+    main()